@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"storage/internal/repository"
+	"storage/internal/service"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// apikey issues, lists and revokes the api keys, it is how the first key is issued
+//
+//	apikey issue -name <client> -roles admin
+//	apikey list
+//	apikey revoke -id <id>
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		return
+	}
+
+	// config
+	cfg := mysql.Config{
+		User:      "root",
+		Passwd:    "localhost",
+		Addr:      "localhost:3306",
+		Net:       "tcp",
+		DBName:    "my_db",
+		ParseTime: true,
+	}
+
+	// open connection to db
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	sv := service.NewAPIKeyDefault(repository.NewAPIKeyMysql(db))
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	name := flags.String("name", "", "name of the client the key is issued to")
+	roles := flags.String("roles", "", "comma separated roles granted to the key, e.g. admin or editor")
+	id := flags.Int("id", 0, "id of the key to revoke")
+	flags.Parse(os.Args[2:])
+
+	switch os.Args[1] {
+	case "issue":
+		key, secret, err := sv.Issue(*name, strings.Split(*roles, ","))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("issued key %d for %s, it is shown only once:\n%s\n", key.ID, key.Name, secret)
+	case "list":
+		keys, err := sv.FindAll()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, key := range keys {
+			status := "active"
+			if key.RevokedAt != nil {
+				status = "revoked " + key.RevokedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\n", key.ID, key.Prefix, key.Name, strings.Join(key.Roles, ","), status)
+		}
+	case "revoke":
+		if err := sv.Revoke(*id); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("revoked key %d\n", *id)
+	default:
+		usage()
+	}
+}
+
+// usage prints the commands
+func usage() {
+	fmt.Println("usage: apikey issue -name <client> -roles <a,b> | apikey list | apikey revoke -id <id>")
+}
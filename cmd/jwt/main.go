@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"storage/internal/jwt"
+	"strings"
+	"time"
+)
+
+// jwt generates keys and signs tokens locally, to run the server with JWT_JWKS without an identity provider
+//
+//	jwt keygen -alg ES256 -kid k1 -key k1.pem -jwks jwks.json
+//	jwt sign -key k1.pem -kid k1 -sub catalog-sync -iss https://issuer -aud storage -roles editor -ttl 1h
+//
+// keygen adds the public key to the JWKS file, so keys are rotated by generating a new one and removing the old later
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		return
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	alg := flags.String("alg", "ES256", "algorithm of the key: RS256, ES256 or HS256")
+	kid := flags.String("kid", "", "id of the key")
+	keyPath := flags.String("key", "key.pem", "file of the private key")
+	jwksPath := flags.String("jwks", "jwks.json", "file of the key set")
+	sub := flags.String("sub", "", "subject of the token")
+	iss := flags.String("iss", "", "issuer of the token")
+	aud := flags.String("aud", "", "audience of the token")
+	roles := flags.String("roles", "", "comma separated roles of the token")
+	ttl := flags.Duration("ttl", time.Hour, "validity of the token")
+	flags.Parse(os.Args[2:])
+
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(*alg, *kid, *keyPath, *jwksPath)
+	case "sign":
+		var token string
+		token, err = sign(*keyPath, *kid, *sub, *iss, *aud, *roles, *ttl)
+		if err == nil {
+			fmt.Println(token)
+		}
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// keygen generates a private key and adds its public key to the key set
+func keygen(alg, kid, keyPath, jwksPath string) (err error) {
+	// generate the key and encode it as PEM
+	var key any
+	var block *pem.Block
+	switch alg {
+	case "RS256":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "HS256":
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		key = secret
+		block = &pem.Block{Type: "SECRET KEY", Bytes: secret}
+	default:
+		err = fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		return
+	}
+	if block == nil {
+		var der []byte
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	if err = os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		return
+	}
+
+	// add the public key to the set
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	data, err := os.ReadFile(jwksPath)
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(data, &set); err != nil {
+			return
+		}
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		return
+	}
+	jwk, err := jwt.PublicJWK(kid, key)
+	if err != nil {
+		return
+	}
+	set.Keys = append(set.Keys, jwk)
+	data, err = json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return
+	}
+	// the set holds secrets when HS256 keys are used
+	err = os.WriteFile(jwksPath, data, 0o600)
+	return
+}
+
+// sign signs a token with the private key
+func sign(keyPath, kid, sub, iss, aud, roles string, ttl time.Duration) (token string, err error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		err = fmt.Errorf("%s is not a PEM file", keyPath)
+		return
+	}
+	var key any
+	switch block.Type {
+	case "SECRET KEY":
+		key = block.Bytes
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return
+		}
+	}
+
+	now := time.Now()
+	claims := jwt.Claims{
+		"sub": sub,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	if iss != "" {
+		claims["iss"] = iss
+	}
+	if aud != "" {
+		claims["aud"] = aud
+	}
+	if roles != "" {
+		claims["roles"] = strings.Split(roles, ",")
+	}
+	token, err = jwt.Sign(claims, kid, key)
+	return
+}
+
+// usage prints the commands
+func usage() {
+	fmt.Println("usage: jwt keygen -alg <RS256|ES256|HS256> -kid <kid> -key <pem> -jwks <json> | jwt sign -key <pem> -kid <kid> -sub <subject> [-iss <issuer>] [-aud <audience>] [-roles <a,b>] [-ttl 1h]")
+}
@@ -1,12 +1,26 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"storage/internal"
+	"storage/internal/barcode"
+	"storage/internal/cache"
 	"storage/internal/handler"
+	"storage/internal/jwt"
+	"storage/internal/middleware"
+	"storage/internal/rbac"
 	"storage/internal/repository"
+	"storage/internal/scheduler"
 	"storage/internal/service"
+	"storage/internal/sink"
+	"storage/internal/stream"
+	"storage/internal/webhook"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-sql-driver/mysql"
@@ -22,6 +36,8 @@ func main() {
 		Net:       "tcp",
 		DBName:    "my_db",
 		ParseTime: true,
+		// the timestamps set by the database are in UTC, like the ones set by the application
+		Params: map[string]string{"time_zone": "'+00:00'"},
 	}
 
 	// open connection to db
@@ -41,27 +57,462 @@ func main() {
 
 	router := chi.NewRouter()
 
-	rp := repository.NewProductMysql(db)
+	// identify every request, the id is recorded in the audit log of the mutations it performs
+	router.Use(middleware.RequestID)
 
+	// authenticate every request, the keys are issued with cmd/apikey or /api/v1/admin/api-keys
+	svAPIKey := service.NewAPIKeyDefault(repository.NewAPIKeyMysql(db))
+	hdAPIKey := handler.NewAPIKeyDefault(svAPIKey)
+	auth := middleware.NewAuth()
+	auth.Register("ApiKey", svAPIKey.Authenticate)
+	// accept bearer JWTs verified by the keys of JWT_JWKS (a file or url) when set, issued by JWT_ISSUER for JWT_AUDIENCE
+	if location := os.Getenv("JWT_JWKS"); location != "" {
+		issuer, audience := os.Getenv("JWT_ISSUER"), os.Getenv("JWT_AUDIENCE")
+		if issuer == "" || audience == "" {
+			fmt.Println("JWT_ISSUER and JWT_AUDIENCE are required with JWT_JWKS")
+			return
+		}
+		jwks, err := jwt.NewJWKS(location, 5*time.Minute, 30*time.Second)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		verifier := &jwt.Verifier{
+			Keys:     jwks,
+			Issuer:   issuer,
+			Audience: audience,
+			Leeway:   30 * time.Second,
+		}
+		rolesClaim := os.Getenv("JWT_ROLES_CLAIM")
+		if rolesClaim == "" {
+			rolesClaim = "roles"
+		}
+		auth.Register("Bearer", middleware.JWTAuthenticate(verifier, rolesClaim))
+	}
+	router.Use(auth.Handler)
+
+	// cache the products read by id, PRODUCTS_CACHE_TTL (e.g. 30s) sets how long and 0 disables it
+	var rp internal.ProductRepository = repository.NewProductMysql(db)
+	caches := make(map[string]func() cache.Stats)
+	cacheTTL := time.Minute
+	if value := os.Getenv("PRODUCTS_CACHE_TTL"); value != "" {
+		cacheTTL, err = time.ParseDuration(value)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	var rpCache *repository.ProductCache
+	if cacheTTL > 0 {
+		rpCache = repository.NewProductCache(rp, 10000, cacheTTL)
+		caches["products"] = rpCache.Stats
+		rp = rpCache
+	}
+
+	// reject publishing or selling expired products when enabled
+	blockExpired := os.Getenv("PRODUCTS_BLOCK_EXPIRED") == "true"
 	sv := service.NewProductDefault(rp)
+	sv.SetBlockExpired(blockExpired)
+	// accept only the configured code formats, e.g. PRODUCTS_CODE_FORMATS=ean13,upca,ndc,other
+	if list := os.Getenv("PRODUCTS_CODE_FORMATS"); list != "" {
+		formats, err := barcode.ParseFormats(list)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		sv.SetCodeFormats(formats...)
+	}
 
 	hd := handler.NewProductDefault(sv)
+	// the caching policy of the product reads, e.g. PRODUCTS_CACHE_CONTROL="private, max-age=60"
+	if cacheControl := os.Getenv("PRODUCTS_CACHE_CONTROL"); cacheControl != "" {
+		hd.SetCacheControl(cacheControl)
+	}
+	// the QR codes encode the product urls under the public url, the one of the request when not set
+	hdBarcode := handler.NewBarcodeDefault(sv, os.Getenv("PUBLIC_BASE_URL"))
+
+	// the repositories writing the products outside of the product one report them to its cache
+	rpMovement := repository.NewStockMovementMysql(db)
+	rpReservation := repository.NewReservationMysql(db)
+	rpWarehouse := repository.NewWarehouseMysql(db)
+	rpLot := repository.NewLotMysql(db)
+	rpPrice := repository.NewPriceMysql(db)
+	rpMovement.SetBlockExpired(blockExpired)
+	rpReservation.SetBlockExpired(blockExpired)
+	if rpCache != nil {
+		rpMovement.SetInvalidator(rpCache)
+		rpReservation.SetInvalidator(rpCache)
+		rpWarehouse.SetInvalidator(rpCache)
+		rpLot.SetInvalidator(rpCache)
+		rpPrice.SetInvalidator(rpCache)
+	}
+
+	hdMovement := handler.NewStockMovementDefault(service.NewStockMovementDefault(rpMovement))
+
+	svReservation := service.NewReservationDefault(rpReservation)
+	hdReservation := handler.NewReservationDefault(svReservation)
+
+	hdWarehouse := handler.NewWarehouseDefault(service.NewWarehouseDefault(rpWarehouse))
+
+	svCategory := service.NewCategoryDefault(repository.NewCategoryMysql(db))
+	hdCategory := handler.NewCategoryDefault(svCategory)
+
+	hdSupplier := handler.NewSupplierDefault(service.NewSupplierDefault(repository.NewSupplierMysql(db)))
+
+	hdLot := handler.NewLotDefault(service.NewLotDefault(rpLot))
+
+	svPrice := service.NewPriceDefault(rpPrice)
+	hdPrice := handler.NewPriceDefault(svPrice)
+	hdLabel := handler.NewLabelDefault(sv, svPrice)
+
+	hdAudit := handler.NewAuditDefault(service.NewAuditDefault(repository.NewAuditMysql(db)))
+
+	// scheduler: jobs run in a single replica thanks to the database lease
+	hostname, _ := os.Hostname()
+	sc := scheduler.NewScheduler(repository.NewJobLeaseMysql(db), fmt.Sprintf("%s-%d", hostname, os.Getpid()))
+
+	err = sc.Register("unpublish-expired-products", "@daily", time.Minute, func(ctx context.Context) (err error) {
+		_, err = sv.UnpublishExpired()
+		return
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	err = sc.Register("expire-reservations", "@every 1m", 30*time.Second, func(ctx context.Context) (err error) {
+		_, err = svReservation.ExpireStale()
+		return
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	err = sc.Register("apply-scheduled-prices", "@every 1m", 30*time.Second, func(ctx context.Context) (err error) {
+		_, err = svPrice.ApplyDue()
+		return
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// relay the events of the outbox to the sinks, EVENTS_LOG=stdout writes them as JSON lines
+	relay := service.NewEventRelay(repository.NewOutboxMysql(db))
+	if os.Getenv("EVENTS_LOG") == "stdout" {
+		relay.AddSink(sink.NewLog(os.Stdout))
+	}
+
+	// webhooks: the relay fans the events out into deliveries, posted signed and retried by their own job
+	svWebhook := service.NewWebhookDefault(repository.NewWebhookMysql(db), webhook.NewSender(nil))
+	hdWebhook := handler.NewWebhookDefault(svWebhook)
+	relay.AddSink(svWebhook)
+	err = sc.Register("relay-events", "@every 2s", time.Minute, func(ctx context.Context) (err error) {
+		_, err = relay.Relay(ctx)
+		return
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// delete the events dispatched longer than OUTBOX_RETENTION ago, 7 days by default, 0 keeps them
+	retention := 7 * 24 * time.Hour
+	if value := os.Getenv("OUTBOX_RETENTION"); value != "" {
+		retention, err = time.ParseDuration(value)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if retention > 0 {
+		err = sc.Register("prune-events", "@hourly", 10*time.Minute, func(ctx context.Context) (err error) {
+			_, err = relay.Prune(ctx, retention)
+			return
+		})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	err = sc.Register("deliver-webhooks", "@every 2s", 5*time.Minute, func(ctx context.Context) (err error) {
+		_, err = svWebhook.Deliver(ctx)
+		return
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sc.Start(ctx, time.Second)
+
+	hdJob := handler.NewJobDefault(sc)
+
+	// stream the product changes tailing the outbox, every instance runs its own hub
+	hub := stream.NewHub(repository.NewOutboxMysql(db), 500*time.Millisecond, 5*time.Second)
+	go hub.Start(ctx)
+	hdStream := handler.NewStreamDefault(hub, sv, svCategory, 15*time.Second)
+	hdCache := handler.NewCacheDefault(caches)
+
+	// invalidate the cached products changed by the other repositories and instances
+	if rpCache != nil {
+		go func() {
+			for ctx.Err() == nil {
+				err := hub.Stream(ctx, -1, func(internal.Event) bool { return true }, time.Minute, func(event internal.Event) error {
+					rpCache.Invalidate(event.ProductID)
+					return nil
+				}, func() error { return nil }, nil)
+				// the events missed while dropped may have changed any product
+				if errors.Is(err, stream.ErrSlowSubscriber) {
+					rpCache.Purge()
+				}
+			}
+		}()
+	}
+	hdInventorySocket := handler.NewInventorySocketDefault(hub, sv, 15*time.Second)
+
+	// authorize every route with the permission it needs, RBAC_POLICY is the file granting the permissions to the roles
+	policy := rbac.DefaultPolicy()
+	if path := os.Getenv("RBAC_POLICY"); path != "" {
+		policy, err = rbac.LoadPolicy(path)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	hd.SetAuthorizer(policy)
+	read := middleware.Require(policy, internal.PermissionProductsRead)
+	write := middleware.Require(policy, internal.PermissionProductsWrite)
+	deleteProducts := middleware.Require(policy, internal.PermissionProductsDelete)
+	writePrices := middleware.Require(policy, internal.PermissionPricesWrite)
+	writeStock := middleware.Require(policy, internal.PermissionStockWrite)
+	writeCatalog := middleware.Require(policy, internal.PermissionCatalogWrite)
+	admin := middleware.Require(policy, internal.PermissionAdmin)
 
 	router.Route("/api/v1/products", func(r chi.Router) {
 		// Get all
-		r.Get("/", hd.GetAll())
+		r.With(read).Get("/", hd.GetAll())
+
+		// Search
+		r.With(read).Get("/search", hd.Search())
+
+		// Stream of changes
+		r.With(read).Get("/stream", hdStream.GetEvents())
+
+		// WebSocket of the quantity and price changes
+		r.With(read).Get("/ws", hdInventorySocket.GetSocket())
+
+		// Expiration reports
+		r.With(read).Get("/expiring", hd.GetExpiring())
+		r.With(read).Get("/expired", hd.GetExpired())
+		r.With(read).Get("/expiration-summary", hd.GetExpirationSummary())
+
+		// Get by id
+		r.With(read).Get("/{id}", hd.GetByID())
+
+		// Validate and normalize a code value
+		r.With(read).Get("/code/validate", hd.ValidateCode())
+
+		// Get by code value
+		r.With(read).Get("/code/{code}", hd.GetByCodeValue())
+
+		// Get by a batch of code values
+		r.With(read).Post("/code/batch", hd.GetByCodeValues())
+
+		// Delete
+		r.With(deleteProducts).Delete("/{id}", hd.Delete())
+
+		// Create
+		r.With(write).Post("/", hd.Create())
+
+		// Update
+		r.With(write).Patch("/{id}", hd.Update())
+
+		// Upsert by code value
+		r.With(write).Put("/by-code/{code_value}", hd.UpsertByCodeValue())
+
+		// Stock movements
+		r.With(read).Get("/{id}/movements", hdMovement.GetByProductID())
+		r.With(writeStock).Post("/{id}/movements", hdMovement.Create())
+
+		// Availability
+		r.With(read).Get("/{id}/availability", hdReservation.GetAvailability())
+
+		// Stock per warehouse
+		r.With(read).Get("/{id}/stock", hdWarehouse.GetProductStock())
+		r.With(writeStock).Put("/{id}/stock/{warehouse_id}", hdWarehouse.SetProductStock())
+		r.With(writeStock).Post("/{id}/stock/transfer", hdWarehouse.TransferProductStock())
+
+		// Categories
+		r.With(read).Get("/{id}/categories", hdCategory.GetProductCategories())
+		r.With(writeCatalog).Put("/{id}/categories", hdCategory.SetProductCategories())
+
+		// Suppliers
+		r.With(read).Get("/{id}/suppliers", hdSupplier.GetProductSuppliers())
+		r.With(writeCatalog).Put("/{id}/suppliers/{supplier_id}", hdSupplier.SetProductSupplier())
+		r.With(writeCatalog).Delete("/{id}/suppliers/{supplier_id}", hdSupplier.DeleteProductSupplier())
+
+		// Margins
+		r.With(read).Get("/margins", hdSupplier.GetMargins())
+		r.With(read).Get("/{id}/margin", hdSupplier.GetProductMargin())
+
+		// Prices
+		r.With(read).Get("/{id}/prices", hdPrice.GetByProductID())
+		r.With(writePrices).Post("/{id}/prices", hdPrice.Schedule())
+
+		// Label sheets
+		r.With(read).Post("/labels", hdLabel.Create())
+
+		// Barcode image
+		r.With(read).Get("/{id}/barcode", hdBarcode.GetByProductID())
+
+		// Lots
+		r.With(read).Get("/{id}/lots", hdLot.GetByProductID())
+		r.With(writeStock).Post("/{id}/lots", hdLot.Receive())
+
+		// History of changes
+		r.With(read).Get("/{id}/history", hdAudit.GetByProductID())
+	})
+
+	router.Route("/api/v1/lots", func(r chi.Router) {
+		// Stock by lot
+		r.With(read).Get("/", hdLot.GetStockReport())
+	})
+
+	router.Route("/api/v1/suppliers", func(r chi.Router) {
+		// Get all
+		r.With(read).Get("/", hdSupplier.GetAll())
+
+		// Get by id
+		r.With(read).Get("/{id}", hdSupplier.GetByID())
+
+		// Create
+		r.With(writeCatalog).Post("/", hdSupplier.Create())
+
+		// Update
+		r.With(writeCatalog).Patch("/{id}", hdSupplier.Update())
+
+		// Delete
+		r.With(writeCatalog).Delete("/{id}", hdSupplier.Delete())
+	})
+
+	router.Route("/api/v1/categories", func(r chi.Router) {
+		// Get all
+		r.With(read).Get("/", hdCategory.GetAll())
+
+		// Get the tree with the amount of products
+		r.With(read).Get("/tree", hdCategory.GetTree())
 
 		// Get by id
-		r.Get("/{id}", hd.GetByID())
+		r.With(read).Get("/{id}", hdCategory.GetByID())
+
+		// Create
+		r.With(writeCatalog).Post("/", hdCategory.Create())
+
+		// Update
+		r.With(writeCatalog).Patch("/{id}", hdCategory.Update())
 
 		// Delete
-		r.Delete("/{id}", hd.Delete())
+		r.With(writeCatalog).Delete("/{id}", hdCategory.Delete())
+	})
+
+	router.Route("/api/v1/warehouses", func(r chi.Router) {
+		// Get all
+		r.With(read).Get("/", hdWarehouse.GetAll())
+
+		// Get by id
+		r.With(read).Get("/{id}", hdWarehouse.GetByID())
 
 		// Create
-		r.Post("/", hd.Create())
+		r.With(writeCatalog).Post("/", hdWarehouse.Create())
 
 		// Update
-		r.Patch("/{id}", hd.Update())
+		r.With(writeCatalog).Patch("/{id}", hdWarehouse.Update())
+
+		// Delete
+		r.With(writeCatalog).Delete("/{id}", hdWarehouse.Delete())
+
+		// Stock
+		r.With(read).Get("/{id}/stock", hdWarehouse.GetStock())
+	})
+
+	router.Route("/api/v1/reservations", func(r chi.Router) {
+		// Create
+		r.With(writeStock).Post("/", hdReservation.Create())
+
+		// Get by id
+		r.With(read).Get("/{id}", hdReservation.GetByID())
+
+		// Confirm
+		r.With(writeStock).Post("/{id}/confirm", hdReservation.Confirm())
+
+		// Release
+		r.With(writeStock).Post("/{id}/release", hdReservation.Release())
+	})
+
+	router.Route("/api/v1/webhooks", func(r chi.Router) {
+		r.Use(admin)
+
+		// Get all
+		r.Get("/", hdWebhook.GetAll())
+
+		// Get by id
+		r.Get("/{id}", hdWebhook.GetByID())
+
+		// Create
+		r.Post("/", hdWebhook.Create())
+
+		// Update
+		r.Patch("/{id}", hdWebhook.Update())
+
+		// Delete
+		r.Delete("/{id}", hdWebhook.Delete())
+
+		// Deliveries
+		r.Get("/{id}/deliveries", hdWebhook.GetDeliveries())
+		r.Get("/{id}/deliveries/{delivery_id}", hdWebhook.GetDelivery())
+		r.Post("/{id}/deliveries/{delivery_id}/replay", hdWebhook.Replay())
+	})
+
+	router.Route("/api/v1/admin/api-keys", func(r chi.Router) {
+		r.Use(admin)
+
+		// Get all
+		r.Get("/", hdAPIKey.GetAll())
+
+		// Issue
+		r.Post("/", hdAPIKey.Issue())
+
+		// Revoke
+		r.Delete("/{id}", hdAPIKey.Revoke())
+	})
+
+	router.Route("/api/v1/admin/audit", func(r chi.Router) {
+		r.Use(admin)
+
+		// Get all, filtered
+		r.Get("/", hdAudit.GetAll())
+	})
+
+	router.Route("/api/v1/admin/jobs", func(r chi.Router) {
+		r.Use(admin)
+
+		// Get all
+		r.Get("/", hdJob.GetAll())
+
+		// Get by name
+		r.Get("/{name}", hdJob.GetByName())
+	})
+
+	router.Route("/api/v1/admin/cache", func(r chi.Router) {
+		r.Use(admin)
+
+		// Get the counters
+		r.Get("/", hdCache.GetStats())
 	})
 
 	err = http.ListenAndServe(":8080", router)
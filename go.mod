@@ -3,8 +3,7 @@ module storage
 go 1.21.5
 
 require (
-	github.com/bootcamp-go/web v1.0.0 // indirect
-	github.com/go-chi/chi/v5 v5.0.11 // indirect
-	github.com/go-sql-driver/mysql v1.7.1 // indirect
-//github.com/go-sql-driver/mysql v1.7.1 // indirect
+	github.com/bootcamp-go/web v1.0.0
+	github.com/go-chi/chi/v5 v5.0.11
+	github.com/go-sql-driver/mysql v1.7.1
 )
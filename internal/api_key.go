@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+// APIKey is a struct that contains an API key, its secret is only stored hashed
+type APIKey struct {
+	// ID is the unique identifier of the key
+	ID int
+	// Name is the name of the client the key was issued to
+	Name string
+	// Prefix is the public part of the key used to look it up
+	Prefix string
+	// Hash is the SHA-256 hash of the whole key, hex encoded
+	Hash string
+	// Roles are the roles granted to the client
+	Roles []string
+	// CreatedAt is the moment the key was issued
+	CreatedAt time.Time
+	// LastUsedAt is the moment the key was last used, nil if it was never used
+	LastUsedAt *time.Time
+	// RevokedAt is the moment the key was revoked, nil if it is active
+	RevokedAt *time.Time
+}
+
+var (
+	// ErrAPIKeyRepositoryNotFound is the error returned when the key is not found
+	ErrAPIKeyRepositoryNotFound = errors.New("repository: api key not found")
+	// ErrAPIKeyServiceInvalidField is the error returned when the key has an invalid field
+	ErrAPIKeyServiceInvalidField = errors.New("service: invalid field")
+)
+
+// APIKeyRepository is an interface that contains the methods that the api key repository should support
+type APIKeyRepository interface {
+	// FindAll returns all the keys
+	FindAll() ([]APIKey, error)
+	// FindByPrefix returns the key with the given prefix
+	FindByPrefix(prefix string) (APIKey, error)
+	// Create creates a new key
+	Create(key *APIKey) error
+	// Revoke revokes the key with the given ID, revoking it again keeps the first moment
+	Revoke(id int, at time.Time) error
+	// Touch sets the moment the key was last used
+	Touch(id int, at time.Time) error
+}
+
+// APIKeyService is an interface that contains the methods that the api key service should support
+type APIKeyService interface {
+	// FindAll returns all the keys
+	FindAll() ([]APIKey, error)
+	// Issue creates a key for the client and returns it with its secret, which is not stored and can not be recovered
+	Issue(name string, roles []string) (key APIKey, secret string, err error)
+	// Revoke revokes the key with the given ID
+	Revoke(id int) error
+	// Authenticate returns the principal of the given secret
+	Authenticate(secret string) (Principal, error)
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// AuditAction is the kind of mutation recorded by an audit entry
+type AuditAction string
+
+const (
+	// AuditActionCreate is the creation of a product
+	AuditActionCreate AuditAction = "create"
+	// AuditActionUpdate is the update of a product
+	AuditActionUpdate AuditAction = "update"
+	// AuditActionDelete is the deletion of a product
+	AuditActionDelete AuditAction = "delete"
+)
+
+// Actor is a struct that contains who performs a mutation and within which request
+type Actor struct {
+	// Subject is the principal performing the mutation, e.g. api-key:12, or system when there is none
+	Subject string
+	// Method is how the principal authenticated, empty for the system
+	Method string
+	// RequestID is the id of the request performing the mutation, empty for the system
+	RequestID string
+}
+
+// SystemActor is the actor of the mutations performed without a request, e.g. by jobs
+var SystemActor = Actor{Subject: "system"}
+
+// AuditChange is a struct that contains the values of a field before and after a mutation
+type AuditChange struct {
+	// Before is the value before the mutation, nil on creation
+	Before any `json:"before"`
+	// After is the value after the mutation, nil on deletion
+	After any `json:"after"`
+}
+
+// AuditEntry is a struct that contains a mutation of a product
+type AuditEntry struct {
+	// ID is the unique identifier of the entry
+	ID int
+	// ProductID is the product mutated
+	ProductID int
+	// Action is the kind of mutation
+	Action AuditAction
+	// Actor is who performed the mutation
+	Actor Actor
+	// Changes are the fields changed by the mutation, by field name
+	Changes map[string]AuditChange
+	// CreatedAt is when the mutation was performed
+	CreatedAt time.Time
+}
+
+// AuditQuery is a struct that contains the filters of an audit search, the zero values do not filter
+type AuditQuery struct {
+	// ProductID filters the entries of a product
+	ProductID int
+	// Action filters the entries of a kind of mutation
+	Action AuditAction
+	// Subject filters the entries of an actor
+	Subject string
+	// RequestID filters the entries of a request
+	RequestID string
+	// From filters the entries created at or after it
+	From time.Time
+	// To filters the entries created before it
+	To time.Time
+	// Page is the page of results, starting at 1
+	Page int
+	// PageSize is the amount of results per page
+	PageSize int
+}
+
+var (
+	// ErrAuditServiceInvalidField is the error returned when the audit query has an invalid field
+	ErrAuditServiceInvalidField = errors.New("service: invalid field")
+)
+
+// AuditRepository is an interface that contains the methods that the audit repository should support
+type AuditRepository interface {
+	// Find returns the page of entries matching the query, the newest first, and the total of matches
+	Find(query AuditQuery) (entries []AuditEntry, total int, err error)
+}
+
+// AuditService is an interface that contains the methods that the audit service should support
+type AuditService interface {
+	// Find returns the page of entries matching the query, the newest first, and the total of matches
+	// - the page and page size of the query are normalized to the values used
+	Find(query *AuditQuery) (entries []AuditEntry, total int, err error)
+}
+
+// requestIDKey is the key of the request id in the request context
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of the context carrying the request id
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request id carried by the context, empty when there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// ActorFromContext returns the actor of the principal and request id carried by the context
+func ActorFromContext(ctx context.Context) (actor Actor) {
+	actor = SystemActor
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		actor = Actor{Subject: principal.Subject, Method: principal.Method}
+	}
+	actor.RequestID = RequestIDFromContext(ctx)
+	return
+}
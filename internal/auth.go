@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"context"
+	"errors"
+)
+
+// Principal is a struct that contains the identity of an authenticated client
+type Principal struct {
+	// Subject is the unique identifier of the client, e.g. api-key:12
+	Subject string
+	// Name is the readable name of the client
+	Name string
+	// Method is how the client authenticated, e.g. api_key or jwt
+	Method string
+	// Roles are the roles granted to the client
+	Roles []string
+}
+
+// HasRole reports whether the principal was granted the role
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// ErrUnauthenticated is the error returned when the credentials are missing, unknown or revoked
+	ErrUnauthenticated = errors.New("auth: unauthenticated")
+)
+
+// principalKey is the key of the principal in the request context
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of the context carrying the principal
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by the context, ok is false when there is none
+func PrincipalFromContext(ctx context.Context) (principal Principal, ok bool) {
+	principal, ok = ctx.Value(principalKey{}).(Principal)
+	return
+}
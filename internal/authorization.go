@@ -0,0 +1,25 @@
+package internal
+
+// Permissions checked by the routes and handlers
+const (
+	// PermissionProductsRead allows reading the catalog: products, stock, lots, prices, categories, suppliers and warehouses
+	PermissionProductsRead = "products:read"
+	// PermissionProductsWrite allows creating and updating products
+	PermissionProductsWrite = "products:write"
+	// PermissionProductsDelete allows deleting products
+	PermissionProductsDelete = "products:delete"
+	// PermissionPricesWrite allows changing and scheduling prices
+	PermissionPricesWrite = "prices:write"
+	// PermissionStockWrite allows moving, receiving, reserving and allocating stock
+	PermissionStockWrite = "stock:write"
+	// PermissionCatalogWrite allows managing categories, suppliers and warehouses
+	PermissionCatalogWrite = "catalog:write"
+	// PermissionAdmin allows managing api keys and jobs
+	PermissionAdmin = "admin"
+)
+
+// Authorizer is an interface that contains the methods that the authorization policy should support
+type Authorizer interface {
+	// Allowed reports whether any of the roles of the principal grants the permission
+	Allowed(principal Principal, permission string) bool
+}
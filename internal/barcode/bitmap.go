@@ -0,0 +1,38 @@
+package barcode
+
+// Bitmap is the grid of modules of a symbol, a module is dark or light
+type Bitmap struct {
+	// Width is the amount of modules per row
+	Width int
+	// Height is the amount of rows, 1 for linear symbols
+	Height int
+	// QuietZone is the amount of light modules required around the symbol
+	QuietZone int
+	// modules are the modules row by row, true is dark
+	modules []bool
+}
+
+// newBitmap creates a light bitmap of the given size
+func newBitmap(width, height, quietZone int) *Bitmap {
+	return &Bitmap{
+		Width:     width,
+		Height:    height,
+		QuietZone: quietZone,
+		modules:   make([]bool, width*height),
+	}
+}
+
+// Linear reports whether the symbol is a row of bars
+func (b *Bitmap) Linear() bool {
+	return b.Height == 1
+}
+
+// At reports whether the module at column x and row y is dark
+func (b *Bitmap) At(x, y int) bool {
+	return b.modules[y*b.Width+x]
+}
+
+// set sets the module at column x and row y
+func (b *Bitmap) set(x, y int, dark bool) {
+	b.modules[y*b.Width+x] = dark
+}
@@ -0,0 +1,190 @@
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Format is a kind of product code
+type Format string
+
+const (
+	// FormatEAN13 is the 13 digit European (International) Article Number
+	FormatEAN13 Format = "ean13"
+	// FormatUPCA is the 12 digit Universal Product Code
+	FormatUPCA Format = "upca"
+	// FormatGTIN14 is the 14 digit Global Trade Item Number used on trade units
+	FormatGTIN14 Format = "gtin14"
+	// FormatNDC is the National Drug Code, as labeler-product or labeler-product-package
+	FormatNDC Format = "ndc"
+	// FormatOther is any other non-empty code, accepted as is
+	FormatOther Format = "other"
+)
+
+// KnownFormats are the formats recognized and validated
+var KnownFormats = []Format{FormatEAN13, FormatUPCA, FormatGTIN14, FormatNDC}
+
+var (
+	// ErrEmpty is the error returned when the code is empty
+	ErrEmpty = errors.New("barcode: empty code")
+	// ErrUnknownFormat is the error returned when the code does not match any known format
+	ErrUnknownFormat = errors.New("barcode: unknown format")
+	// ErrCheckDigit is the error returned when the check digit of the code is wrong
+	ErrCheckDigit = errors.New("barcode: invalid check digit")
+	// ErrFormatNotAccepted is the error returned when the format of the code is not accepted
+	ErrFormatNotAccepted = errors.New("barcode: format not accepted")
+)
+
+// Code is a product code recognized and normalized
+type Code struct {
+	// Format is the format the code was given in
+	Format Format
+	// Value is the canonical form of the code
+	// - EAN-13 for UPC-A, EAN-13 and GTIN-14 with a zero indicator digit
+	// - zero padded 5-4 or 5-4-2 for NDC
+	Value string
+}
+
+// ParseFormats parses a comma separated list of formats
+func ParseFormats(list string) (formats []Format, err error) {
+	for _, name := range strings.Split(list, ",") {
+		format := Format(strings.ToLower(strings.TrimSpace(name)))
+		switch format {
+		case FormatEAN13, FormatUPCA, FormatGTIN14, FormatNDC, FormatOther:
+			formats = append(formats, format)
+		case "":
+		default:
+			err = fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+			return
+		}
+	}
+	return
+}
+
+// NewValidator creates a new validator accepting the given formats
+func NewValidator(formats ...Format) *Validator {
+	accepted := make(map[Format]bool, len(formats))
+	for _, format := range formats {
+		accepted[format] = true
+	}
+	return &Validator{accepted: accepted}
+}
+
+// Validator recognizes codes, validates them and normalizes them to their canonical form
+type Validator struct {
+	// accepted are the formats accepted
+	accepted map[Format]bool
+}
+
+// Normalize recognizes the format of the code, validates it and returns its canonical form
+// - codes of an unknown format are accepted trimmed when FormatOther is accepted
+func (v *Validator) Normalize(raw string) (code Code, err error) {
+	code, err = Recognize(raw)
+	if errors.Is(err, ErrUnknownFormat) && v.accepted[FormatOther] {
+		code, err = Code{Format: FormatOther, Value: strings.TrimSpace(raw)}, nil
+	}
+	if err != nil {
+		return
+	}
+
+	if !v.accepted[code.Format] {
+		err = fmt.Errorf("%w: %s", ErrFormatNotAccepted, code.Format)
+		return
+	}
+	return
+}
+
+var (
+	// ndcPattern matches the hyphenated NDC layouts: 4-4, 5-3 and 5-4 products, 4-4-2, 5-3-2 and 5-4-1 packages
+	ndcPattern = regexp.MustCompile(`^(\d{4,5})-(\d{3,4})(?:-(\d{1,2}))?$`)
+	// digitsPattern matches a code of digits only
+	digitsPattern = regexp.MustCompile(`^\d+$`)
+)
+
+// Recognize recognizes the format of the code, validates it and returns its canonical form
+func Recognize(raw string) (code Code, err error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		err = ErrEmpty
+		return
+	}
+
+	// hyphenated national drug codes
+	if m := ndcPattern.FindStringSubmatch(value); m != nil {
+		return normalizeNDC(m[1], m[2], m[3])
+	}
+
+	// numeric codes, separators between groups of digits are ignored
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(value)
+	if !digitsPattern.MatchString(digits) {
+		err = fmt.Errorf("%w: %q", ErrUnknownFormat, raw)
+		return
+	}
+	switch len(digits) {
+	case 11:
+		// the unhyphenated NDC is only unambiguous in its 5-4-2 form
+		if digits != value {
+			err = fmt.Errorf("%w: %q", ErrUnknownFormat, raw)
+			return
+		}
+		code = Code{Format: FormatNDC, Value: digits[:5] + "-" + digits[5:9] + "-" + digits[9:]}
+		return
+	case 12:
+		code.Format = FormatUPCA
+	case 13:
+		code.Format = FormatEAN13
+	case 14:
+		code.Format = FormatGTIN14
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnknownFormat, raw)
+		return
+	}
+	if !ValidCheckDigit(digits) {
+		err = fmt.Errorf("%w: %q", ErrCheckDigit, raw)
+		return
+	}
+
+	// the canonical form is EAN-13 whenever the code fits in it
+	digits = strings.Repeat("0", max(13-len(digits), 0)) + digits
+	if len(digits) == 14 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+	code.Value = digits
+	return
+}
+
+// normalizeNDC pads the segments of an NDC to the 5-4 (product) or 5-4-2 (package) form
+func normalizeNDC(labeler, product, pack string) (code Code, err error) {
+	// only the 4-4, 5-3 and 5-4 layouts exist, a package segment completes them to 10 digits
+	if len(labeler)+len(product) < 8 || (pack != "" && len(labeler)+len(product)+len(pack) != 10) {
+		err = fmt.Errorf("%w: %s-%s-%s", ErrUnknownFormat, labeler, product, pack)
+		return
+	}
+
+	code.Format = FormatNDC
+	code.Value = fmt.Sprintf("%05s-%04s", labeler, product)
+	if pack != "" {
+		code.Value += fmt.Sprintf("-%02s", pack)
+	}
+	return
+}
+
+// ValidCheckDigit validates the GS1 check digit, the last digit of the code
+func ValidCheckDigit(digits string) bool {
+	if len(digits) < 2 {
+		return false
+	}
+
+	// from the right, excluding the check digit, the digits are weighted 3, 1, 3, 1...
+	sum := 0
+	for i := len(digits) - 2; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return int(digits[len(digits)-1]-'0') == (10-sum%10)%10
+}
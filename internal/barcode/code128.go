@@ -0,0 +1,86 @@
+package barcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidData is the error returned when the data can not be encoded in the symbology
+var ErrInvalidData = errors.New("barcode: invalid data")
+
+// code128Patterns are the bar and space widths of the Code 128 symbols by value, the last one is the stop
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	// code128CodeB switches from code set C to code set B
+	code128CodeB = 100
+	// code128StartB starts the symbol in code set B
+	code128StartB = 104
+	// code128StartC starts the symbol in code set C
+	code128StartC = 105
+	// code128Stop ends the symbol
+	code128Stop = 106
+)
+
+// EncodeCode128 encodes printable ASCII data as a Code 128 symbol
+// - digits only data uses code set C, which packs two digits per symbol, any other data code set B
+func EncodeCode128(data string) (bitmap *Bitmap, err error) {
+	if data == "" {
+		err = fmt.Errorf("%w: empty data", ErrInvalidData)
+		return
+	}
+
+	// translate the data to symbol values
+	var values []int
+	if digitsPattern.MatchString(data) && len(data) >= 2 {
+		values = append(values, code128StartC)
+		for i := 0; i+1 < len(data); i += 2 {
+			values = append(values, int(data[i]-'0')*10+int(data[i+1]-'0'))
+		}
+		// an odd digit left is encoded in code set B
+		if len(data)%2 == 1 {
+			values = append(values, code128CodeB, int(data[len(data)-1])-32)
+		}
+	} else {
+		values = append(values, code128StartB)
+		for i := 0; i < len(data); i++ {
+			if data[i] < 32 || data[i] > 126 {
+				err = fmt.Errorf("%w: %q is not printable ASCII", ErrInvalidData, data[i])
+				return
+			}
+			values = append(values, int(data[i])-32)
+		}
+	}
+
+	// the check symbol is the weighted sum of the values modulo 103, the start symbol weighs 1 as the first one
+	checksum := values[0]
+	for i, value := range values[1:] {
+		checksum += (i + 1) * value
+	}
+	values = append(values, checksum%103, code128Stop)
+
+	// draw the bars, every symbol alternates bars and spaces starting with a bar
+	var modules []bool
+	for _, value := range values {
+		for i, width := range code128Patterns[value] {
+			for j := 0; j < int(width-'0'); j++ {
+				modules = append(modules, i%2 == 0)
+			}
+		}
+	}
+	bitmap = newBitmap(len(modules), 1, 10)
+	copy(bitmap.modules, modules)
+	return
+}
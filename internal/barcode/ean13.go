@@ -0,0 +1,83 @@
+package barcode
+
+import "fmt"
+
+var (
+	// ean13LCodes are the odd parity patterns of the digits, the right half uses their complement
+	// and the even parity patterns of the left half their reversed complement
+	ean13LCodes = [10]string{
+		"0001101", "0011001", "0010011", "0111101", "0100011",
+		"0110001", "0101111", "0111011", "0110111", "0001011",
+	}
+	// ean13Parities are the parities of the left half by the first digit, G marks even parity
+	ean13Parities = [10]string{
+		"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+		"LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+	}
+)
+
+// EncodeEAN13 encodes 13 digits, or 12 to which the check digit is added, as an EAN-13 symbol
+func EncodeEAN13(data string) (bitmap *Bitmap, err error) {
+	if !digitsPattern.MatchString(data) || (len(data) != 12 && len(data) != 13) {
+		err = fmt.Errorf("%w: EAN-13 needs 12 or 13 digits", ErrInvalidData)
+		return
+	}
+
+	// add or validate the check digit
+	if len(data) == 12 {
+		for check := '0'; check <= '9'; check++ {
+			if ValidCheckDigit(data + string(check)) {
+				data += string(check)
+				break
+			}
+		}
+	} else if !ValidCheckDigit(data) {
+		err = fmt.Errorf("%w: %q", ErrCheckDigit, data)
+		return
+	}
+
+	// the first digit is encoded in the parities of the left half
+	pattern := "101"
+	parities := ean13Parities[data[0]-'0']
+	for i := 1; i <= 6; i++ {
+		code := ean13LCodes[data[i]-'0']
+		if parities[i-1] == 'G' {
+			code = reverse(complement(code))
+		}
+		pattern += code
+	}
+	pattern += "01010"
+	for i := 7; i <= 12; i++ {
+		pattern += complement(ean13LCodes[data[i]-'0'])
+	}
+	pattern += "101"
+
+	// draw the bars
+	bitmap = newBitmap(len(pattern), 1, 11)
+	for x := range pattern {
+		bitmap.set(x, 0, pattern[x] == '1')
+	}
+	return
+}
+
+// complement swaps the dark and light modules of the pattern
+func complement(pattern string) string {
+	b := []byte(pattern)
+	for i := range b {
+		if b[i] == '0' {
+			b[i] = '1'
+		} else {
+			b[i] = '0'
+		}
+	}
+	return string(b)
+}
+
+// reverse reverses the modules of the pattern
+func reverse(pattern string) string {
+	b := []byte(pattern)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
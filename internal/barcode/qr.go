@@ -0,0 +1,448 @@
+package barcode
+
+import "fmt"
+
+// qrVersion are the sizes of a QR code version at error correction level M
+type qrVersion struct {
+	// blocks are the amount of data codewords of every block
+	blocks []int
+	// ecc is the amount of error correction codewords per block
+	ecc int
+	// alignments are the centers of the alignment patterns
+	alignments []int
+}
+
+// qrVersions are the versions 1 to 10 at error correction level M
+var qrVersions = []qrVersion{
+	{blocks: []int{16}, ecc: 10},
+	{blocks: []int{28}, ecc: 16, alignments: []int{6, 18}},
+	{blocks: []int{44}, ecc: 26, alignments: []int{6, 22}},
+	{blocks: []int{32, 32}, ecc: 18, alignments: []int{6, 26}},
+	{blocks: []int{43, 43}, ecc: 24, alignments: []int{6, 30}},
+	{blocks: []int{27, 27, 27, 27}, ecc: 16, alignments: []int{6, 34}},
+	{blocks: []int{31, 31, 31, 31}, ecc: 18, alignments: []int{6, 22, 38}},
+	{blocks: []int{38, 38, 39, 39}, ecc: 22, alignments: []int{6, 24, 42}},
+	{blocks: []int{36, 36, 36, 37, 37}, ecc: 22, alignments: []int{6, 26, 46}},
+	{blocks: []int{43, 43, 43, 43, 44}, ecc: 26, alignments: []int{6, 28, 50}},
+}
+
+// dataCodewords returns the amount of data codewords of the version
+func (v qrVersion) dataCodewords() (n int) {
+	for _, block := range v.blocks {
+		n += block
+	}
+	return
+}
+
+// EncodeQR encodes the data in byte mode as a QR code at error correction level M
+// - the smallest version from 1 to 10 that fits the data is used, up to 213 bytes
+func EncodeQR(data []byte) (bitmap *Bitmap, err error) {
+	// choose the smallest version that fits the data
+	number := 0
+	for i, v := range qrVersions {
+		// mode (4 bits), character count (8 bits, 16 from version 10) and data
+		countBits := 8
+		if i+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*v.dataCodewords() {
+			number = i + 1
+			break
+		}
+	}
+	if number == 0 {
+		err = fmt.Errorf("%w: %d bytes do not fit in a QR code", ErrInvalidData, len(data))
+		return
+	}
+	version := qrVersions[number-1]
+
+	// encode the data and add the error correction
+	codewords := qrInterleave(version, qrDataCodewords(number, version, data))
+
+	// draw the function patterns, then the codewords
+	q := newQRSymbol(number, version)
+	q.drawCodewords(codewords)
+
+	// apply the mask with the lowest penalty
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if penalty := q.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		// masks are their own inverse
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+
+	bitmap = q.bitmap
+	return
+}
+
+// qrDataCodewords encodes the data in byte mode and pads it to the capacity of the version
+func qrDataCodewords(number int, version qrVersion, data []byte) (codewords []byte) {
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 == 1)
+		}
+	}
+
+	// mode, character count and data
+	appendBits(0b0100, 4)
+	if number >= 10 {
+		appendBits(len(data), 16)
+	} else {
+		appendBits(len(data), 8)
+	}
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	// terminator and padding to a whole byte
+	capacity := 8 * version.dataCodewords()
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+
+	// pack the bits and fill the capacity with the pad codewords
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << (7 - j)
+			}
+		}
+		codewords = append(codewords, b)
+	}
+	for pad := byte(0xEC); len(codewords) < version.dataCodewords(); pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return
+}
+
+// qrInterleave splits the data in blocks, adds their error correction and interleaves them
+func qrInterleave(version qrVersion, data []byte) (codewords []byte) {
+	var blocks, eccs [][]byte
+	offset := 0
+	for _, size := range version.blocks {
+		block := data[offset : offset+size]
+		offset += size
+		blocks = append(blocks, block)
+		eccs = append(eccs, reedSolomon(block, version.ecc))
+	}
+
+	// the data codewords are taken one of every block in turn, then the error correction ones
+	longest := version.blocks[len(version.blocks)-1]
+	for i := 0; i < longest; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				codewords = append(codewords, block[i])
+			}
+		}
+	}
+	for i := 0; i < version.ecc; i++ {
+		for _, ecc := range eccs {
+			codewords = append(codewords, ecc[i])
+		}
+	}
+	return
+}
+
+// gfMultiply multiplies in GF(256) with the QR code polynomial x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(a, b byte) (product byte) {
+	for i := 7; i >= 0; i-- {
+		carry := product >> 7
+		product = product<<1 ^ carry*0x1D
+		product ^= ((b >> i) & 1) * a
+	}
+	return
+}
+
+// reedSolomon returns the n error correction codewords of the data
+func reedSolomon(data []byte, n int) []byte {
+	// the generator polynomial is the product of (x - 2^i) for i in 0..n-1, its leading 1 omitted
+	generator := make([]byte, n)
+	generator[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			generator[j] = gfMultiply(generator[j], root)
+			if j+1 < n {
+				generator[j] ^= generator[j+1]
+			}
+		}
+		root = gfMultiply(root, 2)
+	}
+
+	// the remainder of the division of the data by the generator
+	remainder := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[n-1] = 0
+		for i := range remainder {
+			remainder[i] ^= gfMultiply(generator[i], factor)
+		}
+	}
+	return remainder
+}
+
+// qrSymbol is a QR code being drawn
+type qrSymbol struct {
+	// size is the amount of modules per side
+	size int
+	// bitmap are the modules of the symbol
+	bitmap *Bitmap
+	// function marks the modules of the function patterns, which are not masked
+	function []bool
+}
+
+// newQRSymbol creates a symbol of the version with its function patterns drawn
+func newQRSymbol(number int, version qrVersion) (q *qrSymbol) {
+	size := 17 + 4*number
+	q = &qrSymbol{
+		size:     size,
+		bitmap:   newBitmap(size, size, 4),
+		function: make([]bool, size*size),
+	}
+
+	// timing patterns
+	for i := 0; i < size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+
+	// finder patterns with their separators
+	for _, center := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x < 0 || x >= size || y < 0 || y >= size {
+					continue
+				}
+				distance := max(abs(dx), abs(dy))
+				q.setFunction(x, y, distance != 2 && distance != 4)
+			}
+		}
+	}
+
+	// alignment patterns, except the ones overlapping the finder patterns
+	last := len(version.alignments) - 1
+	for i, cx := range version.alignments {
+		for j, cy := range version.alignments {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format information and draw the version information
+	q.drawFormat(0)
+	if number >= 7 {
+		remainder := number
+		for i := 0; i < 12; i++ {
+			remainder = remainder<<1 ^ (remainder>>11)*0x1F25
+		}
+		bits := number<<12 | remainder
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			a, b := size-11+i%3, i/3
+			q.setFunction(a, b, dark)
+			q.setFunction(b, a, dark)
+		}
+	}
+	return
+}
+
+// setFunction draws a module of a function pattern
+func (q *qrSymbol) setFunction(x, y int, dark bool) {
+	q.bitmap.set(x, y, dark)
+	q.function[y*q.size+x] = true
+}
+
+// drawFormat draws both copies of the format information, level M and the mask
+func (q *qrSymbol) drawFormat(mask int) {
+	// level M is 00, so the data are the mask bits
+	data := mask
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = remainder<<1 ^ (remainder>>9)*0x537
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool {
+		return (bits>>i)&1 == 1
+	}
+
+	// around the top left finder
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+
+	// next to the top right and bottom left finders
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.size-15+i, bit(i))
+	}
+	q.setFunction(8, q.size-8, true)
+}
+
+// drawCodewords places the codewords in the zigzag of two module columns, from the bottom right corner
+func (q *qrSymbol) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		// the vertical timing pattern is skipped
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vertical := 0; vertical < q.size; vertical++ {
+			y := vertical
+			if upward {
+				y = q.size - 1 - vertical
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if q.function[y*q.size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				q.bitmap.set(x, y, (codewords[i>>3]>>(7-i&7))&1 == 1)
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the modules out of the function patterns selected by the mask
+func (q *qrSymbol) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.function[y*q.size+x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				q.bitmap.set(x, y, !q.bitmap.At(x, y))
+			}
+		}
+	}
+}
+
+// penalty scores the symbol by the rules of the standard, a lower penalty is easier to read
+func (q *qrSymbol) penalty() (penalty int) {
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return q.bitmap.At(y, x)
+		}
+		return q.bitmap.At(x, y)
+	}
+
+	finderLike := []bool{true, false, true, true, true, false, true}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < q.size; y++ {
+			// runs of five or more modules of the same color
+			run := 1
+			for x := 1; x <= q.size; x++ {
+				if x < q.size && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += 3 + run - 5
+				}
+				run = 1
+			}
+
+			// patterns like the finder with four light modules on a side
+			for x := 0; x+7 <= q.size; x++ {
+				matches := true
+				for k, dark := range finderLike {
+					if at(x+k, y, vertical) != dark {
+						matches = false
+						break
+					}
+				}
+				if !matches {
+					continue
+				}
+				if q.light(x-4, x, y, vertical) || q.light(x+7, x+11, y, vertical) {
+					penalty += 40
+				}
+			}
+		}
+	}
+
+	// blocks of 2x2 modules of the same color
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.bitmap.At(x, y) {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				c := q.bitmap.At(x, y)
+				if c == q.bitmap.At(x+1, y) && c == q.bitmap.At(x, y+1) && c == q.bitmap.At(x+1, y+1) {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	// balance of dark and light modules, 10 points per 5% away from half
+	total := q.size * q.size
+	penalty += 10 * (abs(dark*100/total-50) / 5)
+	return
+}
+
+// light reports whether the modules from a to b (excluded) of the line are light, the ones out of the symbol are
+func (q *qrSymbol) light(a, b, line int, vertical bool) bool {
+	for i := a; i < b; i++ {
+		if i < 0 || i >= q.size {
+			continue
+		}
+		if vertical && q.bitmap.At(line, i) || !vertical && q.bitmap.At(i, line) {
+			return false
+		}
+	}
+	return true
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package barcode
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// RenderOptions are the sizes used to render a symbol
+type RenderOptions struct {
+	// Scale is the size in pixels of a module
+	Scale int
+	// BarHeight is the height in pixels of the bars of linear symbols
+	BarHeight int
+}
+
+// dimensions returns the size in pixels of the rendered symbol and of its margin
+func (o RenderOptions) dimensions(b *Bitmap) (width, height, margin int) {
+	margin = b.QuietZone * o.Scale
+	width = b.Width*o.Scale + 2*margin
+	if b.Linear() {
+		// linear symbols only need the quiet zone on their sides
+		height = o.BarHeight + 2*o.Scale
+		return
+	}
+	height = b.Height*o.Scale + 2*margin
+	return
+}
+
+// module returns the rectangle in pixels of the module at column x and row y
+func (o RenderOptions) module(b *Bitmap, x, y int) image.Rectangle {
+	_, height, margin := o.dimensions(b)
+	left := margin + x*o.Scale
+	if b.Linear() {
+		return image.Rect(left, o.Scale, left+o.Scale, height-o.Scale)
+	}
+	top := margin + y*o.Scale
+	return image.Rect(left, top, left+o.Scale, top+o.Scale)
+}
+
+// WritePNG writes the symbol as a black on white PNG image
+func WritePNG(w io.Writer, b *Bitmap, opts RenderOptions) (err error) {
+	width, height, _ := opts.dimensions(b)
+	img := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.White, color.Black})
+
+	// the image starts white, index 0 of the palette
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			if !b.At(x, y) {
+				continue
+			}
+			r := opts.module(b, x, y)
+			for py := r.Min.Y; py < r.Max.Y; py++ {
+				for px := r.Min.X; px < r.Max.X; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+
+	err = png.Encode(w, img)
+	return
+}
+
+// WriteSVG writes the symbol as a black on white SVG image
+// - contiguous dark modules of a row are drawn as a single rectangle
+func WriteSVG(w io.Writer, b *Bitmap, opts RenderOptions) (err error) {
+	width, height, _ := opts.dimensions(b)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, width, height, width, height)
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" fill="#fff"/><g fill="#000">`, width, height)
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			if !b.At(x, y) {
+				continue
+			}
+			start := opts.module(b, x, y)
+			for x+1 < b.Width && b.At(x+1, y) {
+				x++
+			}
+			end := opts.module(b, x, y)
+			fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d"/>`, start.Min.X, start.Min.Y, end.Max.X-start.Min.X, start.Dy())
+		}
+	}
+	fmt.Fprint(bw, `</g></svg>`)
+	err = bw.Flush()
+	return
+}
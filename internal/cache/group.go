@@ -0,0 +1,53 @@
+package cache
+
+import "sync"
+
+// Group collapses the concurrent calls for the same key into a single one, e.g. the loads of a missing entry
+type Group[K comparable, V any] struct {
+	// mu guards calls
+	mu sync.Mutex
+	// calls are the calls in flight by key
+	calls map[K]*groupCall[V]
+}
+
+// groupCall is a call in flight
+type groupCall[V any] struct {
+	// done is closed when the call returns
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Do calls fn unless a call for the key is in flight, in which case it waits for that one and shares its result
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (value V, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*groupCall[V])
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.value, call.err, true
+	}
+	call := &groupCall[V]{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err, false
+}
+
+// Forget makes the next calls for the key not wait for the one in flight, e.g. when its result became stale
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.calls, key)
+}
@@ -0,0 +1,122 @@
+// Package cache contains the building blocks of the read-through caches: a bounded LRU with TTL,
+// a group collapsing concurrent loads of the same key and the interface of a remote cache shared by the instances
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// NewLRU creates a cache of up to capacity entries, each one expiring ttl after it was set
+func NewLRU[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// LRU is an in-process cache evicting the least recently used entry when full, safe for concurrent use
+type LRU[K comparable, V any] struct {
+	// capacity is the maximum amount of entries
+	capacity int
+	// ttl is how long an entry is valid after it was set
+	ttl time.Duration
+	// now returns the current time
+	now func() time.Time
+
+	// mu guards the fields below
+	mu sync.Mutex
+	// items are the elements of the order by key
+	items map[K]*list.Element
+	// order holds the entries from the most to the least recently used
+	order *list.List
+	// evictions is the amount of entries evicted to make room
+	evictions uint64
+}
+
+// lruEntry is an entry of the cache
+type lruEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// Get returns the value of the key, found is false when it is missing or expired
+func (c *LRU[K, V]) Get(key K) (value V, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return
+	}
+	entry := element.Value.(*lruEntry[K, V])
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return
+	}
+	c.order.MoveToFront(element)
+	value, found = entry.value, true
+	return
+}
+
+// Set stores the value of the key, evicting the least recently used entry when full
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(c.ttl)
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*lruEntry[K, V])
+		entry.value, entry.expiresAt = value, expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+		c.evictions++
+	}
+}
+
+// Delete removes the key
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.order.Remove(element)
+		delete(c.items, key)
+	}
+}
+
+// Purge removes all the entries
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+// Len returns the amount of entries, including the expired ones not yet removed
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Evictions returns the amount of entries evicted to make room
+func (c *LRU[K, V]) Evictions() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evictions
+}
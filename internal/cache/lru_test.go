@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRU_Evicts(t *testing.T) {
+	lru := NewLRU[int, string](2, time.Minute)
+	lru.Set(1, "one")
+	lru.Set(2, "two")
+
+	// reading 1 makes 2 the least recently used
+	if _, found := lru.Get(1); !found {
+		t.Fatal("expected 1")
+	}
+	lru.Set(3, "three")
+
+	if _, found := lru.Get(2); found {
+		t.Fatal("expected 2 evicted")
+	}
+	if value, found := lru.Get(1); !found || value != "one" {
+		t.Fatalf("expected one, got %q", value)
+	}
+	if value, found := lru.Get(3); !found || value != "three" {
+		t.Fatalf("expected three, got %q", value)
+	}
+	if lru.Len() != 2 || lru.Evictions() != 1 {
+		t.Fatalf("expected 2 entries and 1 eviction, got %d and %d", lru.Len(), lru.Evictions())
+	}
+}
+
+func TestLRU_Expires(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	lru := NewLRU[int, string](2, time.Minute)
+	lru.now = func() time.Time { return now }
+	lru.Set(1, "one")
+
+	now = now.Add(59 * time.Second)
+	if _, found := lru.Get(1); !found {
+		t.Fatal("expected 1 before the ttl")
+	}
+	now = now.Add(time.Second)
+	if _, found := lru.Get(1); found {
+		t.Fatal("expected 1 expired after the ttl")
+	}
+	if lru.Len() != 0 {
+		t.Fatalf("expected the expired entry removed, got %d entries", lru.Len())
+	}
+}
+
+func TestGroup_Do(t *testing.T) {
+	var g Group[int, int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	// the first call holds the key until released
+	go func() {
+		g.Do(1, func() (int, error) {
+			calls.Add(1)
+			close(started)
+			<-release
+			return 42, nil
+		})
+	}()
+	<-started
+
+	var wg sync.WaitGroup
+	results := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, _, _ := g.Do(1, func() (int, error) {
+				calls.Add(1)
+				return 0, nil
+			})
+			results <- value
+		}()
+	}
+
+	// give the callers time to join the call in flight
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+	for value := range results {
+		if value != 42 {
+			t.Fatalf("expected the shared value 42, got %d", value)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 call, got %d", calls.Load())
+	}
+}
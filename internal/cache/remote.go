@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Remote is an interface that contains the methods that a cache shared by the instances should support, e.g. Redis
+// - the errors are not fatal for the callers, which fall back to the source
+type Remote interface {
+	// Get returns the value of the key, found is false when it is missing
+	Get(key string) (value []byte, found bool, err error)
+	// Set stores the value of the key for ttl
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes the key
+	Delete(key string) error
+}
+
+// NewMemory creates an in-memory remote cache
+func NewMemory() *Memory {
+	return &Memory{
+		items: make(map[string]memoryItem),
+		now:   time.Now,
+	}
+}
+
+// Memory is an in-memory implementation of the remote cache, to run without one and in tests
+type Memory struct {
+	// now returns the current time
+	now func() time.Time
+
+	// mu guards items
+	mu sync.Mutex
+	// items are the values by key
+	items map[string]memoryItem
+}
+
+// memoryItem is a value of the memory cache
+type memoryItem struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// Get returns the value of the key, found is false when it is missing or expired
+func (m *Memory) Get(key string) (value []byte, found bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.items[key]
+	if !ok {
+		return
+	}
+	if !m.now().Before(item.expiresAt) {
+		delete(m.items, key)
+		return
+	}
+	value, found = append([]byte(nil), item.value...), true
+	return
+}
+
+// Set stores a copy of the value of the key for ttl
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items[key] = memoryItem{value: append([]byte(nil), value...), expiresAt: m.now().Add(ttl)}
+	return
+}
+
+// Delete removes the key
+func (m *Memory) Delete(key string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.items, key)
+	return
+}
@@ -0,0 +1,21 @@
+package cache
+
+// Stats is a struct that contains the counters of a read-through cache
+type Stats struct {
+	// Hits is the amount of reads served by the in-process cache
+	Hits uint64 `json:"hits"`
+	// RemoteHits is the amount of reads missed in process and served by the remote cache
+	RemoteHits uint64 `json:"remote_hits"`
+	// Misses is the amount of reads missed in process
+	Misses uint64 `json:"misses"`
+	// Loads is the amount of reads of the wrapped repository, concurrent misses of the same entry share one
+	Loads uint64 `json:"loads"`
+	// Invalidations is the amount of entries invalidated
+	Invalidations uint64 `json:"invalidations"`
+	// Evictions is the amount of entries evicted from the in-process cache to make room
+	Evictions uint64 `json:"evictions"`
+	// RemoteErrors is the amount of failed operations of the remote cache
+	RemoteErrors uint64 `json:"remote_errors"`
+	// Size is the amount of entries in the in-process cache
+	Size int `json:"size"`
+}
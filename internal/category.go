@@ -0,0 +1,92 @@
+package internal
+
+import "errors"
+
+// Category is a struct that contains the category's information
+type Category struct {
+	// ID is the unique identifier of the category
+	ID int
+	// Name is the name of the category
+	Name string
+	// ParentID is the identifier of the parent category, 0 for root categories
+	ParentID int
+}
+
+// CategoryCount is a struct that contains the amount of products of a category
+type CategoryCount struct {
+	// CategoryID is the identifier of the category
+	CategoryID int
+	// Products is the amount of products assigned directly to the category
+	Products int
+	// TotalProducts is the amount of distinct products assigned to the category or its descendants
+	TotalProducts int
+}
+
+// CategoryNode is a struct that contains a category of the tree with its counts and children
+type CategoryNode struct {
+	Category
+	// Products is the amount of products assigned directly to the category
+	Products int
+	// TotalProducts is the amount of distinct products assigned to the category or its descendants
+	TotalProducts int
+	// Children are the subcategories
+	Children []CategoryNode
+}
+
+var (
+	// ErrCategoryRepositoryNotFound is the error returned when the category is not found
+	ErrCategoryRepositoryNotFound = errors.New("repository: category not found")
+	// ErrCategoryRepositoryDuplicated is the error returned when the category already exists under the same parent
+	ErrCategoryRepositoryDuplicated = errors.New("repository: category already exists")
+	// ErrCategoryRepositoryHasChildren is the error returned when deleting a category with subcategories
+	ErrCategoryRepositoryHasChildren = errors.New("repository: category has subcategories")
+	// ErrCategoryRepositoryInvalidParent is the error returned when the parent does not exist or is the category or one of its descendants
+	ErrCategoryRepositoryInvalidParent = errors.New("repository: invalid parent category")
+	// ErrCategoryServiceInvalidField is the error returned when the category has an invalid field
+	ErrCategoryServiceInvalidField = errors.New("service: invalid field")
+)
+
+// CategoryRepository is an interface that contains the methods that the category repository should support
+type CategoryRepository interface {
+	// FindAll returns all the categories
+	FindAll() ([]Category, error)
+	// FindByID returns the category with the given ID
+	FindByID(id int) (Category, error)
+	// Create creates a new category
+	Create(category *Category) error
+	// Update updates the category with the given ID
+	// - the parent can not be the category or one of its descendants, checked with the ancestors locked
+	Update(category *Category) error
+	// Delete deletes the category with the given ID, it must not have subcategories
+	Delete(id int) error
+	// CountProducts returns the amount of products of every category
+	CountProducts() ([]CategoryCount, error)
+	// FindByProductID returns the categories assigned to the product
+	FindByProductID(productID int) ([]Category, error)
+	// ContainsProduct reports whether the product is assigned to any of the categories or their descendants
+	ContainsProduct(categoryIDs []int, productID int) (bool, error)
+	// SetProductCategories replaces the categories assigned to the product
+	SetProductCategories(productID int, categoryIDs []int) error
+}
+
+// CategoryService is an interface that contains the methods that the category service should support
+type CategoryService interface {
+	// FindAll returns all the categories
+	FindAll() ([]Category, error)
+	// FindByID returns the category with the given ID
+	FindByID(id int) (Category, error)
+	// Tree returns the categories as a tree with the amount of products
+	Tree() ([]CategoryNode, error)
+	// Create creates a new category
+	Create(category *Category) error
+	// Update updates the category with the given ID
+	Update(category *Category) error
+	// Delete deletes the category with the given ID
+	Delete(id int) error
+	// FindByProductID returns the categories assigned to the product
+	FindByProductID(productID int) ([]Category, error)
+	// ContainsProduct reports whether the product is assigned to any of the categories or their descendants
+	ContainsProduct(categoryIDs []int, productID int) (bool, error)
+	// SetProductCategories replaces the categories assigned to the product
+	SetProductCategories(productID int, categoryIDs []int) error
+}
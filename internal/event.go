@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// EventType is the kind of a domain event
+type EventType string
+
+const (
+	// EventProductCreated is emitted when a product is created
+	EventProductCreated EventType = "product.created"
+	// EventProductUpdated is emitted when fields of a product change
+	EventProductUpdated EventType = "product.updated"
+	// EventProductDeleted is emitted when a product is deleted
+	EventProductDeleted EventType = "product.deleted"
+	// EventStockChanged is emitted when the quantity of a product changes
+	EventStockChanged EventType = "stock.changed"
+)
+
+// EventTypes are all the kinds of domain events
+var EventTypes = []EventType{EventProductCreated, EventProductUpdated, EventProductDeleted, EventStockChanged}
+
+// Event is a struct that contains a domain event stored in the outbox
+type Event struct {
+	// ID is the unique identifier of the event, increasing in the order the events were emitted
+	ID int
+	// Type is the kind of event
+	Type EventType
+	// ProductID is the product the event is about
+	ProductID int
+	// Payload is the JSON body of the event, a ProductEventPayload or a StockChangedPayload
+	Payload json.RawMessage
+	// OccurredAt is when the change was committed
+	OccurredAt time.Time
+	// Attempts is the amount of failed relays of the event
+	Attempts int
+	// LastError is the error of the last failed relay, empty if none
+	LastError string
+	// NextAttemptAt is when the event is relayed again after a failure
+	NextAttemptAt time.Time
+	// DispatchedAt is when the event was delivered to all the sinks, nil while pending
+	DispatchedAt *time.Time
+}
+
+// ProductSnapshot is a struct that contains the fields of a product carried by the events
+type ProductSnapshot struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Quantity    int     `json:"quantity"`
+	CodeValue   string  `json:"code_value"`
+	IsPublished string  `json:"is_published"`
+	Expiration  string  `json:"expiration"`
+	Price       float64 `json:"price"`
+}
+
+// ProductEventPayload is the payload of the product created, updated and deleted events
+type ProductEventPayload struct {
+	// Product is the product after the change, or before it when deleted
+	Product ProductSnapshot `json:"product"`
+	// Changes are the changed fields by name, only on update
+	Changes map[string]AuditChange `json:"changes,omitempty"`
+	// Actor is the subject that performed the change
+	Actor string `json:"actor"`
+	// RequestID is the id of the request that performed the change, empty for the system
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// StockChangedPayload is the payload of the stock changed events
+type StockChangedPayload struct {
+	// ProductID is the product whose stock changed
+	ProductID int `json:"product_id"`
+	// MovementID is the stock movement recording the change
+	MovementID int `json:"movement_id"`
+	// MovementType is the kind of the stock movement
+	MovementType StockMovementType `json:"movement_type"`
+	// Delta is the signed amount of units added or removed
+	Delta int `json:"delta"`
+	// Quantity is the quantity of the product after the change
+	Quantity int `json:"quantity"`
+	// Reason is the reason of the movement
+	Reason string `json:"reason"`
+}
+
+// EventSink is an interface that contains the methods that the destinations of the events should support
+// - the delivery is at least once: an event may be published again after an error, in this or another sink
+type EventSink interface {
+	// Name identifies the sink in the relay errors
+	Name() string
+	// Publish delivers the event, an error makes the relay retry it later
+	Publish(ctx context.Context, event Event) error
+}
+
+// OutboxRepository is an interface that contains the methods that the outbox repository should support
+// - the events are written in the transactions of the changes by the other repositories
+type OutboxRepository interface {
+	// FindPending returns up to limit events not dispatched whose next attempt is due, in emission order
+	// - an event is not returned while an earlier one of its product is not dispatched, so the products keep their order
+	FindPending(now time.Time, limit int) ([]Event, error)
+	// MarkDispatched marks the event as delivered to all the sinks
+	MarkDispatched(id int, at time.Time) error
+	// MarkFailed records a failed relay of the event and when to attempt it again
+	MarkFailed(id int, lastError string, nextAttemptAt time.Time) error
+	// FindAfter returns up to limit events with an ID greater than the given one, dispatched or not, in emission order
+	FindAfter(id int, limit int) ([]Event, error)
+	// LastID returns the ID of the latest event, 0 when there is none
+	LastID() (int, error)
+	// FirstID returns the ID of the oldest event retained, 0 when there is none
+	FirstID() (int, error)
+	// DeleteDispatchedBefore deletes up to limit events dispatched that occurred before the given time and returns the amount deleted
+	// - only the events before the oldest one not dispatched are deleted, so the retained ones are always the latest ones
+	DeleteDispatchedBefore(before time.Time, limit int) (deleted int, err error)
+}
+
+// EventRelay is an interface that contains the methods that the outbox relay should support
+type EventRelay interface {
+	// Relay delivers the pending events to the sinks and returns the amount dispatched
+	Relay(ctx context.Context) (dispatched int, err error)
+	// Prune deletes the events dispatched longer than retention ago and returns the amount deleted
+	Prune(ctx context.Context, retention time.Duration) (deleted int, err error)
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+	"time"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type APIKeyJSON struct {
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Roles      []string   `json:"roles"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type APIKeyIssuedJSON struct {
+	APIKeyJSON
+	// Key is the whole key, it is only returned when issued
+	Key string `json:"key"`
+}
+
+type BodyRequestAPIKeyJSON struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// NewAPIKeyDefault creates a new instance of the api key handler
+func NewAPIKeyDefault(sv internal.APIKeyService) *APIKeyDefault {
+	return &APIKeyDefault{
+		sv: sv,
+	}
+}
+
+type APIKeyDefault struct {
+	// sv is the service used by the handler
+	sv internal.APIKeyService
+}
+
+// GetAll returns all the keys, without their secrets
+func (h *APIKeyDefault) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the keys from the service
+		keys, err := h.sv.FindAll()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		keysJSON := make([]APIKeyJSON, 0)
+		for _, key := range keys {
+			keysJSON = append(keysJSON, apiKeyToJSON(key))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": keysJSON,
+		})
+	}
+}
+
+// Issue issues a new key, its secret is only returned in this response
+func (h *APIKeyDefault) Issue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		//get the body of the request
+		var body BodyRequestAPIKeyJSON
+		err := request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// issue the key in the service
+		key, secret, err := h.sv.Issue(body.Name, body.Roles)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrAPIKeyServiceInvalidField):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"data": APIKeyIssuedJSON{
+				APIKeyJSON: apiKeyToJSON(key),
+				Key:        secret,
+			},
+		})
+	}
+}
+
+// Revoke revokes a key
+func (h *APIKeyDefault) Revoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// revoke the key in the service
+		err = h.sv.Revoke(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrAPIKeyRepositoryNotFound):
+				response.Error(w, http.StatusNotFound, "api key not found")
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "api key revoked successfully",
+			"data":    nil,
+		})
+	}
+}
+
+// apiKeyToJSON serializes a key without its hash
+func apiKeyToJSON(key internal.APIKey) APIKeyJSON {
+	return APIKeyJSON{
+		Id:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Roles:      key.Roles,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
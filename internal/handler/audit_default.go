@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+	"time"
+
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AuditEntryJSON struct {
+	Id         int                             `json:"id"`
+	ProductId  int                             `json:"product_id"`
+	Action     string                          `json:"action"`
+	Actor      string                          `json:"actor"`
+	AuthMethod string                          `json:"auth_method,omitempty"`
+	RequestId  string                          `json:"request_id,omitempty"`
+	Changes    map[string]internal.AuditChange `json:"changes"`
+	CreatedAt  time.Time                       `json:"created_at"`
+}
+
+type ResponseAuditJSON struct {
+	Data     []AuditEntryJSON `json:"data"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+	Total    int              `json:"total"`
+}
+
+// NewAuditDefault creates a new instance of the audit handler
+func NewAuditDefault(sv internal.AuditService) *AuditDefault {
+	return &AuditDefault{
+		sv: sv,
+	}
+}
+
+type AuditDefault struct {
+	// sv is the service used by the handler
+	sv internal.AuditService
+}
+
+// GetByProductID returns the history of changes of a product, the newest first
+func (h *AuditDefault) GetByProductID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the query parameters, the product is the one of the url
+		query, ok := auditQuery(w, r)
+		if !ok {
+			return
+		}
+		query.ProductID = id
+
+		h.find(w, &query)
+	}
+}
+
+// GetAll returns the audit entries filtered by the product_id, action, actor, request_id, from and to query parameters
+func (h *AuditDefault) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the query parameters
+		query, ok := auditQuery(w, r)
+		if !ok {
+			return
+		}
+		if value := r.URL.Query().Get("product_id"); value != "" {
+			var err error
+			query.ProductID, err = strconv.Atoi(value)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "failed to convert product_id to int")
+				return
+			}
+		}
+
+		h.find(w, &query)
+	}
+}
+
+// find responds with the page of audit entries matching the query
+func (h *AuditDefault) find(w http.ResponseWriter, query *internal.AuditQuery) {
+
+	// get the entries from the service
+	entries, total, err := h.sv.Find(query)
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrAuditServiceInvalidField):
+			response.Error(w, http.StatusBadRequest, err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	// serealize to json
+	entriesJSON := make([]AuditEntryJSON, 0)
+	for _, entry := range entries {
+		entriesJSON = append(entriesJSON, AuditEntryJSON{
+			Id:         entry.ID,
+			ProductId:  entry.ProductID,
+			Action:     string(entry.Action),
+			Actor:      entry.Actor.Subject,
+			AuthMethod: entry.Actor.Method,
+			RequestId:  entry.Actor.RequestID,
+			Changes:    entry.Changes,
+			CreatedAt:  entry.CreatedAt,
+		})
+	}
+
+	//return response
+	response.JSON(w, http.StatusOK, ResponseAuditJSON{
+		Data:     entriesJSON,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Total:    total,
+	})
+}
+
+// auditQuery reads the filters and pagination of an audit query, responding 400 when one is invalid
+// - from and to are RFC 3339 timestamps or YYYY-MM-DD dates
+func auditQuery(w http.ResponseWriter, r *http.Request) (query internal.AuditQuery, ok bool) {
+	values := r.URL.Query()
+	query.Action = internal.AuditAction(values.Get("action"))
+	query.Subject = values.Get("actor")
+	query.RequestID = values.Get("request_id")
+
+	var err error
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"page", &query.Page}, {"page_size", &query.PageSize}} {
+		if value := values.Get(param.name); value != "" {
+			*param.value, err = strconv.Atoi(value)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "failed to convert "+param.name+" to int")
+				return
+			}
+		}
+	}
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		if value := values.Get(param.name); value != "" {
+			*param.value, err = time.Parse(time.RFC3339, value)
+			if err != nil {
+				*param.value, err = time.Parse("2006-01-02", value)
+			}
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "invalid "+param.name+", expected RFC 3339 or YYYY-MM-DD")
+				return
+			}
+		}
+	}
+
+	ok = true
+	return
+}
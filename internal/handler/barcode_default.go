@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"storage/internal"
+	"storage/internal/barcode"
+	"strconv"
+	"strings"
+
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// NewBarcodeDefault creates a new instance of the barcode handler
+// - baseURL is the public url of the api encoded in the QR codes, the one of the request when empty
+func NewBarcodeDefault(sv internal.ProductService, baseURL string) *BarcodeDefault {
+	return &BarcodeDefault{
+		sv:      sv,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+type BarcodeDefault struct {
+	// sv is the service used by the handler
+	sv internal.ProductService
+	// baseURL is the public url of the api
+	baseURL string
+}
+
+const (
+	// MaxBarcodeScale is the maximum size in pixels of a module
+	MaxBarcodeScale = 20
+	// MaxBarcodeHeight is the maximum height in pixels of the bars
+	MaxBarcodeHeight = 1000
+)
+
+// GetByProductID renders the code value of a product as a barcode image
+// - type is code128 (default), ean13 or qr, the QR code encodes the url of the product
+// - format is png (default) or svg, scale the pixels per module and height the pixels of the bars
+func (h *BarcodeDefault) GetByProductID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the rendering options from the query
+		query := r.URL.Query()
+		symbology := query.Get("type")
+		if symbology == "" {
+			symbology = "code128"
+		}
+		format := query.Get("format")
+		if format == "" {
+			format = "png"
+		}
+		if format != "png" && format != "svg" {
+			response.Error(w, http.StatusBadRequest, "format must be png or svg")
+			return
+		}
+		opts := barcode.RenderOptions{Scale: 2, BarHeight: 80}
+		if symbology == "qr" {
+			opts.Scale = 4
+		}
+		if opts.Scale, err = intQuery(query.Get("scale"), opts.Scale, 1, MaxBarcodeScale); err != nil {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("scale must be between 1 and %d", MaxBarcodeScale))
+			return
+		}
+		if opts.BarHeight, err = intQuery(query.Get("height"), opts.BarHeight, 10, MaxBarcodeHeight); err != nil {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("height must be between 10 and %d", MaxBarcodeHeight))
+			return
+		}
+
+		// get the product from the service
+		product, err := h.sv.FindByID(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductRepositoryNotFound):
+				response.Error(w, http.StatusNotFound, "product not found")
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// encode the symbol
+		var bitmap *barcode.Bitmap
+		switch symbology {
+		case "code128":
+			bitmap, err = barcode.EncodeCode128(product.CodeValue)
+		case "ean13":
+			bitmap, err = barcode.EncodeEAN13(product.CodeValue)
+		case "qr":
+			bitmap, err = barcode.EncodeQR([]byte(h.productURL(r, product.ID)))
+		default:
+			response.Error(w, http.StatusBadRequest, "type must be code128, ean13 or qr")
+			return
+		}
+		if err != nil {
+			response.Error(w, http.StatusUnprocessableEntity, fmt.Sprintf("code value can not be encoded as %s: %s", symbology, err.Error()))
+			return
+		}
+
+		// render the image before writing, so a failure is still reported as an error response
+		var buf bytes.Buffer
+		contentType := "image/png"
+		if format == "svg" {
+			contentType = "image/svg+xml"
+			err = barcode.WriteSVG(&buf, bitmap, opts)
+		} else {
+			err = barcode.WritePNG(&buf, bitmap, opts)
+		}
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// return response
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
+
+// productURL returns the public url of the product
+func (h *BarcodeDefault) productURL(r *http.Request, id int) string {
+	baseURL := h.baseURL
+	if baseURL == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + r.Host
+	}
+	return fmt.Sprintf("%s/api/v1/products/%d", baseURL, id)
+}
+
+// intQuery parses an integer query parameter within bounds, the default is used when it is empty
+func intQuery(value string, def, lower, upper int) (n int, err error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err = strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	if n < lower || n > upper {
+		err = fmt.Errorf("%d out of range", n)
+		return
+	}
+	return
+}
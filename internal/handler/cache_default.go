@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"storage/internal/cache"
+
+	"github.com/bootcamp-go/web/response"
+)
+
+// NewCacheDefault creates a new instance of the cache handler reporting the counters of the caches by name
+func NewCacheDefault(caches map[string]func() cache.Stats) *CacheDefault {
+	return &CacheDefault{
+		caches: caches,
+	}
+}
+
+type CacheDefault struct {
+	// caches return the counters of the caches by name
+	caches map[string]func() cache.Stats
+}
+
+// GetStats returns the hits, misses and size of the caches
+func (h *CacheDefault) GetStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the counters of every cache
+		stats := make(map[string]cache.Stats)
+		for name, fn := range h.caches {
+			stats[name] = fn()
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": stats,
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CategoryJSON struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentId *int   `json:"parent_id"`
+}
+
+type BodyRequestCategoryJSON struct {
+	Name     string `json:"name"`
+	ParentId *int   `json:"parent_id"`
+}
+
+type CategoryNodeJSON struct {
+	CategoryJSON
+	Products      int                `json:"products"`
+	TotalProducts int                `json:"total_products"`
+	Children      []CategoryNodeJSON `json:"children"`
+}
+
+type BodyRequestProductCategoriesJSON struct {
+	CategoryIds []int `json:"category_ids"`
+}
+
+// NewCategoryDefault creates a new instance of the category handler
+func NewCategoryDefault(sv internal.CategoryService) *CategoryDefault {
+	return &CategoryDefault{
+		sv: sv,
+	}
+}
+
+type CategoryDefault struct {
+	// sv is the service used by the handler
+	sv internal.CategoryService
+}
+
+// GetAll returns all categories
+func (h *CategoryDefault) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the categories from the service
+		categories, err := h.sv.FindAll()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		categoriesJSON := make([]CategoryJSON, 0)
+		for _, category := range categories {
+			categoriesJSON = append(categoriesJSON, categoryToJSON(category))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": categoriesJSON,
+		})
+	}
+}
+
+// GetTree returns the categories hierarchy with the amount of products per category
+func (h *CategoryDefault) GetTree() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the tree from the service
+		roots, err := h.sv.Tree()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": categoryNodesToJSON(roots),
+		})
+	}
+}
+
+// GetByID returns a category
+func (h *CategoryDefault) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the category from the service
+		category, err := h.sv.FindByID(id)
+		if err != nil {
+			categoryErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": categoryToJSON(category),
+		})
+	}
+}
+
+// Create creates a category
+func (h *CategoryDefault) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		//get the body of the request
+		var body BodyRequestCategoryJSON
+		err := request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// create the category in the service
+		category := internal.Category{
+			Name: body.Name,
+		}
+		if body.ParentId != nil {
+			category.ParentID = *body.ParentId
+		}
+		err = h.sv.Create(&category)
+		if err != nil {
+			categoryErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"data": categoryToJSON(category),
+		})
+	}
+}
+
+// Update updates a category
+func (h *CategoryDefault) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestCategoryJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// update the category in the service
+		category := internal.Category{
+			ID:   id,
+			Name: body.Name,
+		}
+		if body.ParentId != nil {
+			category.ParentID = *body.ParentId
+		}
+		err = h.sv.Update(&category)
+		if err != nil {
+			categoryErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": categoryToJSON(category),
+		})
+	}
+}
+
+// Delete deletes a category
+func (h *CategoryDefault) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// delete the category in the service
+		err = h.sv.Delete(id)
+		if err != nil {
+			categoryErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "category deleted successfully",
+			"data":    nil,
+		})
+	}
+}
+
+// GetProductCategories returns the categories of a product
+func (h *CategoryDefault) GetProductCategories() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the categories from the service
+		categories, err := h.sv.FindByProductID(id)
+		if err != nil {
+			categoryErrorResponse(w, err)
+			return
+		}
+
+		// serealize to json
+		categoriesJSON := make([]CategoryJSON, 0)
+		for _, category := range categories {
+			categoriesJSON = append(categoriesJSON, categoryToJSON(category))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": categoriesJSON,
+		})
+	}
+}
+
+// SetProductCategories replaces the categories of a product
+func (h *CategoryDefault) SetProductCategories() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestProductCategoriesJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// set the categories in the service
+		err = h.sv.SetProductCategories(id, body.CategoryIds)
+		if err != nil {
+			categoryErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "product categories updated successfully",
+			"data":    nil,
+		})
+	}
+}
+
+// categoryErrorResponse writes the response of a category error
+func categoryErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, internal.ErrCategoryServiceInvalidField):
+		response.Error(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, internal.ErrCategoryRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "category not found")
+	case errors.Is(err, internal.ErrProductRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "product not found")
+	case errors.Is(err, internal.ErrCategoryRepositoryDuplicated):
+		response.Error(w, http.StatusConflict, "category already exists")
+	case errors.Is(err, internal.ErrCategoryRepositoryHasChildren):
+		response.Error(w, http.StatusConflict, "category has subcategories")
+	default:
+		response.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+// categoryToJSON serializes a category, root categories have a null parent
+func categoryToJSON(category internal.Category) (data CategoryJSON) {
+	data = CategoryJSON{
+		Id:   category.ID,
+		Name: category.Name,
+	}
+	if category.ParentID != 0 {
+		parentID := category.ParentID
+		data.ParentId = &parentID
+	}
+	return
+}
+
+// categoryNodesToJSON serializes the nodes of the categories tree
+func categoryNodesToJSON(nodes []internal.CategoryNode) (data []CategoryNodeJSON) {
+	data = make([]CategoryNodeJSON, 0, len(nodes))
+	for _, node := range nodes {
+		data = append(data, CategoryNodeJSON{
+			CategoryJSON:  categoryToJSON(node.Category),
+			Products:      node.Products,
+			TotalProducts: node.TotalProducts,
+			Children:      categoryNodesToJSON(node.Children),
+		})
+	}
+	return
+}
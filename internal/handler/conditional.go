@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultCacheControl makes the clients revalidate the product reads every time, cheap thanks to the 304 responses
+const DefaultCacheControl = "no-cache"
+
+// validators are the Cache-Control, ETag and Last-Modified of a response
+type validators struct {
+	// cacheControl is the caching policy of the response
+	cacheControl string
+	// etag identifies the version of the response
+	etag string
+	// lastModified is when the resource last changed, zero if unknown
+	lastModified time.Time
+}
+
+// newETag returns a strong entity tag hashing the parts that identify a version of a resource
+func newETag(parts ...any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(parts...)))
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// notModified sets the validators of the response and reports whether the request is conditional and the client
+// holds the current version, writing the 304 response in that case
+// - If-None-Match takes precedence over If-Modified-Since, which only has a precision of seconds
+func (v validators) notModified(w http.ResponseWriter, r *http.Request) bool {
+	if v.cacheControl != "" {
+		w.Header().Set("Cache-Control", v.cacheControl)
+	}
+	if v.etag != "" {
+		w.Header().Set("ETag", v.etag)
+	}
+	if !v.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	match := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		match = etagMatches(inm, v.etag)
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !v.lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		match = err == nil && !v.lastModified.Truncate(time.Second).After(since)
+	}
+	if !match {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether the If-None-Match header lists the entity tag, comparing weakly as GET requires
+func etagMatches(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, item := range strings.Split(header, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" || strings.TrimPrefix(item, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,422 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"storage/internal"
+	"storage/internal/stream"
+	"storage/internal/websocket"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxSocketSubscriptions is the maximum amount of products a connection may subscribe to
+	MaxSocketSubscriptions = 500
+	// InventorySocketProtocol is the subprotocol of the inventory socket
+	InventorySocketProtocol = "inventory.v1"
+	// socketReadLimit is the maximum size of a message of the client
+	socketReadLimit = 64 << 10
+	// socketWriteTimeout is how long a message may take to be written before the client is considered gone
+	socketWriteTimeout = 10 * time.Second
+	// socketMaxReplies is the amount of replies a client may leave unread before it is closed
+	socketMaxReplies = 32
+	// socketSnapshotAttempts is how many times the snapshot of a product changed while it was read is read again
+	socketSnapshotAttempts = 3
+)
+
+// NewInventorySocketDefault creates a new instance of the inventory WebSocket handler
+func NewInventorySocketDefault(hub *stream.Hub, sv internal.ProductService, heartbeat time.Duration) *InventorySocketDefault {
+	return &InventorySocketDefault{
+		hub:       hub,
+		sv:        sv,
+		heartbeat: heartbeat,
+	}
+}
+
+type InventorySocketDefault struct {
+	// hub broadcasts the events
+	hub *stream.Hub
+	// sv returns the current state of the products subscribed
+	sv internal.ProductService
+	// heartbeat is the interval of the pings without updates
+	heartbeat time.Duration
+}
+
+// InventorySocketRequestJSON is a message of the client
+type InventorySocketRequestJSON struct {
+	// Action is subscribe or unsubscribe
+	Action string `json:"action"`
+	// IDs are the products to subscribe to or unsubscribe from
+	IDs []int `json:"ids"`
+}
+
+// InventoryUpdateJSON is a message with the quantity and price of a product
+// - snapshot carries both when subscribing, update carries the fields changed and deleted none
+type InventoryUpdateJSON struct {
+	Type      string   `json:"type"`
+	ProductID int      `json:"product_id"`
+	Quantity  *int     `json:"quantity,omitempty"`
+	Price     *float64 `json:"price,omitempty"`
+	EventID   int      `json:"event_id,omitempty"`
+}
+
+// GetSocket upgrades the connection to a WebSocket pushing the quantity and price changes of the products subscribed
+// - browsers authenticate with the subprotocols [InventorySocketProtocol, websocket.AuthorizationProtocol + base64url(authorization)]
+// - the client sends {"action":"subscribe"|"unsubscribe","ids":[...]} and gets the subscribed ids back, and a snapshot of the new ones
+// - the changes of a product not yet written to a slow client are merged, so it gets the latest values instead of every change
+func (h *InventorySocketDefault) GetSocket() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// upgrade the connection, the request was authenticated by the middlewares
+		conn, err := websocket.Upgrade(w, r, socketReadLimit, InventorySocketProtocol)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		s := &inventorySession{
+			conn:    conn,
+			ids:     make(map[int]bool),
+			pending: make(map[int]InventoryUpdateJSON),
+			latest:  make(map[int]int),
+			floor:   make(map[int]int),
+			wake:    make(chan struct{}, 1),
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(3)
+		// write the replies and updates
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			s.write(ctx)
+		}()
+		// follow the changes of the products subscribed
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			err := h.hub.Stream(ctx, -1, s.subscribed, h.heartbeat, s.event, s.ping, nil)
+			switch {
+			case errors.Is(err, stream.ErrSlowSubscriber):
+				conn.WriteClose(websocket.CloseTryAgainLater, "too slow")
+			case ctx.Err() == nil:
+				conn.WriteClose(websocket.CloseGoingAway, "")
+			}
+		}()
+		// unblock the read once the session ends
+		go func() {
+			defer wg.Done()
+			<-ctx.Done()
+			conn.Close()
+		}()
+
+		// read the messages of the client
+		for {
+			var data []byte
+			_, data, err = conn.ReadMessage()
+			if err != nil {
+				break
+			}
+			if !h.handle(s, data) {
+				conn.WriteClose(websocket.ClosePolicyViolation, "too many unread replies")
+				break
+			}
+		}
+		cancel()
+		wg.Wait()
+	}
+}
+
+// handle applies a message of the client, it returns false when the client must be closed
+func (h *InventorySocketDefault) handle(s *inventorySession, data []byte) bool {
+	// deserialize the message
+	var req InventorySocketRequestJSON
+	if err := json.Unmarshal(data, &req); err != nil {
+		return s.reply(map[string]any{"type": "error", "message": "invalid message"})
+	}
+	if len(req.IDs) == 0 {
+		return s.reply(map[string]any{"type": "error", "message": "ids is required"})
+	}
+	for _, id := range req.IDs {
+		if id <= 0 {
+			return s.reply(map[string]any{"type": "error", "message": "ids must be positive"})
+		}
+	}
+
+	switch req.Action {
+	case "subscribe":
+		// subscribe before reading the snapshot, so no change after it is missed
+		added, ok := s.subscribe(req.IDs)
+		if !ok {
+			return s.reply(map[string]any{"type": "error", "message": "too many subscriptions"})
+		}
+		if len(added) == 0 {
+			return s.reply(map[string]any{"type": "subscribed", "ids": s.list()})
+		}
+
+		// read the snapshots, again for the products whose events newer than the read position were already merged
+		// - those events may have been committed after the read, so its values may be older than theirs
+		found := make(map[int]bool)
+		for attempt, ids := 0, added; attempt < socketSnapshotAttempts && len(ids) > 0; attempt++ {
+			position := h.hub.Position()
+			products, err := h.sv.FindByIDs(ids)
+			if err != nil {
+				s.unsubscribe(added)
+				return s.reply(map[string]any{"type": "error", "message": "internal server error"})
+			}
+			ids = nil
+			for _, p := range products {
+				if attempt == 0 {
+					found[p.ID] = true
+				}
+				if !s.snapshot(p, position) {
+					ids = append(ids, p.ID)
+				}
+			}
+		}
+
+		// the ids not found are not subscribed
+		notFound := []int{}
+		for _, id := range added {
+			if !found[id] {
+				notFound = append(notFound, id)
+			}
+		}
+		s.unsubscribe(notFound)
+		return s.reply(map[string]any{"type": "subscribed", "ids": s.list(), "not_found": notFound})
+	case "unsubscribe":
+		s.unsubscribe(req.IDs)
+		return s.reply(map[string]any{"type": "subscribed", "ids": s.list()})
+	default:
+		return s.reply(map[string]any{"type": "error", "message": "action must be subscribe or unsubscribe"})
+	}
+}
+
+// inventorySession is the state of a connection
+type inventorySession struct {
+	// conn is the connection of the client
+	conn *websocket.Conn
+
+	// mu guards the fields below
+	mu sync.Mutex
+	// ids are the products subscribed
+	ids map[int]bool
+	// pending are the updates not yet written by product, merged while the client is slow
+	pending map[int]InventoryUpdateJSON
+	// latest is the ID of the last event merged by product
+	latest map[int]int
+	// floor is the position of the hub the snapshot was read at by product, the events up to it are in the snapshot
+	floor map[int]int
+	// replies are the answers to the client messages not yet written
+	replies [][]byte
+	// pingDue tells a ping must be written
+	pingDue bool
+
+	// wake signals the writer there is something to write
+	wake chan struct{}
+}
+
+// subscribed is the filter of the events of the products subscribed
+func (s *inventorySession) subscribed(event internal.Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ids[event.ProductID]
+}
+
+// subscribe adds the ids and returns the ones not subscribed yet, ok is false when it exceeds the limit
+func (s *inventorySession) subscribe(ids []int) (added []int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if !s.ids[id] && !seen[id] {
+			seen[id] = true
+			added = append(added, id)
+		}
+	}
+	if len(s.ids)+len(added) > MaxSocketSubscriptions {
+		return nil, false
+	}
+	for _, id := range added {
+		s.ids[id] = true
+	}
+	ok = true
+	return
+}
+
+// unsubscribe removes the ids and drops their pending updates
+func (s *inventorySession) unsubscribe(ids []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.ids, id)
+		delete(s.pending, id)
+		delete(s.latest, id)
+		delete(s.floor, id)
+	}
+}
+
+// list returns the ids subscribed in order
+func (s *inventorySession) list() (ids []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids = make([]int, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return
+}
+
+// reply queues an answer to the client, it returns false when the client left too many unread
+func (s *inventorySession) reply(body map[string]any) bool {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.replies) >= socketMaxReplies {
+		return false
+	}
+	s.replies = append(s.replies, data)
+	s.signal()
+	return true
+}
+
+// snapshot queues the state of a product read once the hub broadcast up to position, replacing the pending update
+// - it returns false, queuing nothing, when an event after position was merged, the state may be older than it
+func (s *inventorySession) snapshot(p internal.Product, position int) bool {
+	quantity, price := p.Quantity, p.Price
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ids[p.ID] {
+		return true
+	}
+	if s.latest[p.ID] > position {
+		return false
+	}
+	s.floor[p.ID] = position
+	s.pending[p.ID] = InventoryUpdateJSON{Type: "snapshot", ProductID: p.ID, Quantity: &quantity, Price: &price}
+	s.signal()
+	return true
+}
+
+// event merges the change of an event into the pending update of its product
+func (s *inventorySession) event(event internal.Event) (err error) {
+	update := InventoryUpdateJSON{Type: "update", ProductID: event.ProductID, EventID: event.ID}
+	switch event.Type {
+	case internal.EventStockChanged:
+		var payload internal.StockChangedPayload
+		err = json.Unmarshal(event.Payload, &payload)
+		if err != nil {
+			return
+		}
+		update.Quantity = &payload.Quantity
+	case internal.EventProductCreated, internal.EventProductUpdated:
+		var payload internal.ProductEventPayload
+		err = json.Unmarshal(event.Payload, &payload)
+		if err != nil {
+			return
+		}
+		_, quantityChanged := payload.Changes["quantity"]
+		_, priceChanged := payload.Changes["price"]
+		if event.Type == internal.EventProductCreated || quantityChanged {
+			update.Quantity = &payload.Product.Quantity
+		}
+		if event.Type == internal.EventProductCreated || priceChanged {
+			update.Price = &payload.Product.Price
+		}
+		if update.Quantity == nil && update.Price == nil {
+			return
+		}
+	case internal.EventProductDeleted:
+		update.Type = "deleted"
+	default:
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the events up to the snapshot are already in it
+	if !s.ids[event.ProductID] || event.ID <= s.floor[event.ProductID] {
+		return
+	}
+	s.latest[event.ProductID] = event.ID
+	// keep the fields of the pending update that this change does not carry
+	if pending, ok := s.pending[event.ProductID]; ok && pending.Type != "deleted" && update.Type != "deleted" {
+		update.Type = pending.Type
+		if update.Quantity == nil {
+			update.Quantity = pending.Quantity
+		}
+		if update.Price == nil {
+			update.Price = pending.Price
+		}
+	}
+	s.pending[event.ProductID] = update
+	s.signal()
+	return
+}
+
+// ping queues a ping, to detect the clients gone without closing
+func (s *inventorySession) ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pingDue = true
+	s.signal()
+	return nil
+}
+
+// signal wakes the writer, the caller holds mu
+func (s *inventorySession) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// write writes the queued replies, updates and pings until the context is done or a write fails
+func (s *inventorySession) write(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+
+		// take everything queued
+		s.mu.Lock()
+		replies, pending, pingDue := s.replies, s.pending, s.pingDue
+		s.replies, s.pending, s.pingDue = nil, make(map[int]InventoryUpdateJSON), false
+		s.mu.Unlock()
+
+		// write the replies first, then the updates in product order
+		for _, data := range replies {
+			if s.conn.WriteText(data, time.Now().Add(socketWriteTimeout)) != nil {
+				return
+			}
+		}
+		ids := make([]int, 0, len(pending))
+		for id := range pending {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		for _, id := range ids {
+			data, err := json.Marshal(pending[id])
+			if err != nil {
+				return
+			}
+			if s.conn.WriteText(data, time.Now().Add(socketWriteTimeout)) != nil {
+				return
+			}
+		}
+		if pingDue && s.conn.WritePing(time.Now().Add(socketWriteTimeout)) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"time"
+
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type JobStatusJSON struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Running      bool       `json:"running"`
+	LastRun      *time.Time `json:"last_run"`
+	LastDuration string     `json:"last_duration"`
+	LastError    string     `json:"last_error"`
+	NextRun      time.Time  `json:"next_run"`
+	Runs         int        `json:"runs"`
+	Skipped      int        `json:"skipped"`
+}
+
+// NewJobDefault creates a new instance of the job handler
+func NewJobDefault(sc internal.JobScheduler) *JobDefault {
+	return &JobDefault{
+		sc: sc,
+	}
+}
+
+type JobDefault struct {
+	// sc is the scheduler used by the handler
+	sc internal.JobScheduler
+}
+
+// GetAll returns the status of all the jobs
+func (h *JobDefault) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// serealize to json
+		jobsJSON := make([]JobStatusJSON, 0)
+		for _, status := range h.sc.Statuses() {
+			jobsJSON = append(jobsJSON, jobStatusToJSON(status))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": jobsJSON,
+		})
+	}
+}
+
+// GetByName returns the status of a job
+func (h *JobDefault) GetByName() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the status from the scheduler
+		status, err := h.sc.Status(chi.URLParam(r, "name"))
+
+		// check for errors
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrJobNotFound):
+				response.Error(w, http.StatusNotFound, "job not found")
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": jobStatusToJSON(status),
+		})
+	}
+}
+
+// jobStatusToJSON serializes a job status
+func jobStatusToJSON(status internal.JobStatus) (data JobStatusJSON) {
+	data = JobStatusJSON{
+		Name:      status.Name,
+		Schedule:  status.Schedule,
+		Running:   status.Running,
+		LastError: status.LastError,
+		NextRun:   status.NextRun,
+		Runs:      status.Runs,
+		Skipped:   status.Skipped,
+	}
+	if !status.LastRun.IsZero() {
+		data.LastRun = &status.LastRun
+		data.LastDuration = status.LastDuration.String()
+	}
+	return
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"storage/internal"
+	"storage/internal/label"
+	"strings"
+	"time"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+)
+
+type BodyRequestLabelsJSON struct {
+	// Ids are the products to print, one of ids, category_id or price_changed_since is required
+	Ids []int `json:"ids"`
+	// CategoryId prints the products of the category and its descendants
+	CategoryId int `json:"category_id"`
+	// PriceChangedSince prints the products whose price changed since the time (RFC 3339)
+	PriceChangedSince string `json:"price_changed_since"`
+	// Layout is the name of the label layout
+	Layout string `json:"layout"`
+	// Skip is the amount of positions already used on the first sheet
+	Skip int `json:"skip"`
+	// Copies is the amount of labels per product
+	Copies int `json:"copies"`
+}
+
+// NewLabelDefault creates a new instance of the label handler
+func NewLabelDefault(sv internal.ProductService, svPrice internal.PriceService) *LabelDefault {
+	return &LabelDefault{
+		sv:      sv,
+		svPrice: svPrice,
+	}
+}
+
+type LabelDefault struct {
+	// sv is the product service used by the handler
+	sv internal.ProductService
+	// svPrice is the price service used to find the products whose price changed
+	svPrice internal.PriceService
+}
+
+// MaxLabels is the maximum amount of labels of a request
+const MaxLabels = 1000
+
+// Create renders the shelf labels (name, price, expiration and barcode) of the selected products as a PDF
+func (h *LabelDefault) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		//get the body of the request
+		var body BodyRequestLabelsJSON
+		err := request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// validate the options
+		selectors := 0
+		for _, given := range []bool{len(body.Ids) > 0, body.CategoryId > 0, body.PriceChangedSince != ""} {
+			if given {
+				selectors++
+			}
+		}
+		if selectors != 1 {
+			response.Error(w, http.StatusBadRequest, "exactly one of ids, category_id or price_changed_since is required")
+			return
+		}
+		if body.Layout == "" {
+			body.Layout = label.DefaultLayout
+		}
+		layout, ok := label.Layouts[body.Layout]
+		if !ok {
+			response.Error(w, http.StatusBadRequest, "layout must be one of "+strings.Join(label.LayoutNames(), ", "))
+			return
+		}
+		if body.Skip < 0 || body.Skip >= layout.PerSheet() {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("skip must be between 0 and %d", layout.PerSheet()-1))
+			return
+		}
+		if body.Copies == 0 {
+			body.Copies = 1
+		}
+		if body.Copies < 0 {
+			response.Error(w, http.StatusBadRequest, "copies must be positive")
+			return
+		}
+		if len(body.Ids)*body.Copies > MaxLabels || body.Copies > MaxLabels {
+			response.Error(w, http.StatusUnprocessableEntity, fmt.Sprintf("too many labels, max %d", MaxLabels))
+			return
+		}
+
+		// get the products from the services
+		var products []internal.Product
+		switch {
+		case len(body.Ids) > 0:
+			products, err = h.sv.FindByIDs(body.Ids)
+		case body.CategoryId > 0:
+			var count int
+			count, err = h.sv.CountByCategoryID(body.CategoryId)
+			if err == nil && count*body.Copies > MaxLabels {
+				response.Error(w, http.StatusUnprocessableEntity, fmt.Sprintf("too many labels, max %d", MaxLabels))
+				return
+			}
+			if err == nil {
+				products, err = h.sv.FindByCategoryID(body.CategoryId)
+			}
+		default:
+			var since time.Time
+			since, err = time.Parse(time.RFC3339, body.PriceChangedSince)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "failed to parse price_changed_since, expected RFC 3339")
+				return
+			}
+			var ids []int
+			ids, err = h.svPrice.FindProductIDsChangedSince(since)
+			if err == nil && len(ids)*body.Copies > MaxLabels {
+				response.Error(w, http.StatusUnprocessableEntity, fmt.Sprintf("too many labels, max %d", MaxLabels))
+				return
+			}
+			if err == nil {
+				products, err = h.sv.FindByIDs(ids)
+			}
+		}
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if len(products)*body.Copies > MaxLabels {
+			response.Error(w, http.StatusUnprocessableEntity, fmt.Sprintf("too many labels, max %d", MaxLabels))
+			return
+		}
+
+		// build the labels
+		labels := make([]label.Label, 0, len(products)*body.Copies)
+		for _, product := range products {
+			// the expiration may come from the database with a time part
+			expiration := product.Expiration
+			if len(expiration) > 10 {
+				expiration = expiration[:10]
+			}
+			for i := 0; i < body.Copies; i++ {
+				labels = append(labels, label.Label{
+					Name:       product.Name,
+					Price:      product.Price,
+					Expiration: expiration,
+					Code:       product.CodeValue,
+				})
+			}
+		}
+
+		// render the sheets before writing, so a failure is still reported as an error response
+		var buf bytes.Buffer
+		err = label.WriteSheets(&buf, layout, labels, body.Skip)
+		if err != nil {
+			switch {
+			case errors.Is(err, label.ErrInvalidLabel):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// return response
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `inline; filename="labels.pdf"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
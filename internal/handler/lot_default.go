@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type LotJSON struct {
+	Id               int    `json:"id"`
+	ProductId        int    `json:"product_id"`
+	LotNumber        string `json:"lot_number"`
+	Quantity         int    `json:"quantity"`
+	ReceivedQuantity int    `json:"received_quantity"`
+	Expiration       string `json:"expiration"`
+	ReceivedDate     string `json:"received_date"`
+}
+
+type LotStockJSON struct {
+	LotJSON
+	ProductName string  `json:"product_name"`
+	StockValue  float64 `json:"stock_value"`
+}
+
+type LotAllocationJSON struct {
+	LotId     int    `json:"lot_id"`
+	LotNumber string `json:"lot_number"`
+	Quantity  int    `json:"quantity"`
+}
+
+type BodyRequestLotJSON struct {
+	LotNumber    string `json:"lot_number"`
+	Quantity     int    `json:"quantity"`
+	Expiration   string `json:"expiration"`
+	ReceivedDate string `json:"received_date"`
+}
+
+// NewLotDefault creates a new instance of the lot handler
+func NewLotDefault(sv internal.LotService) *LotDefault {
+	return &LotDefault{
+		sv: sv,
+	}
+}
+
+type LotDefault struct {
+	// sv is the service used by the handler
+	sv internal.LotService
+}
+
+// GetByProductID returns the lots of a product, the depleted ones too with ?include_depleted=true
+func (h *LotDefault) GetByProductID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+		includeDepleted := r.URL.Query().Get("include_depleted") == "true"
+
+		// get the lots from the service
+		lots, err := h.sv.FindByProductID(id, includeDepleted)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		lotsJSON := make([]LotJSON, 0)
+		for _, lot := range lots {
+			lotsJSON = append(lotsJSON, lotToJSON(lot))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": lotsJSON,
+		})
+	}
+}
+
+// Receive records a lot received for a product
+func (h *LotDefault) Receive() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestLotJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// serialize the body to a receipt, recorded by the authenticated principal
+		receipt := internal.LotReceipt{
+			Lot: internal.Lot{
+				ProductID:    id,
+				LotNumber:    body.LotNumber,
+				Quantity:     body.Quantity,
+				Expiration:   body.Expiration,
+				ReceivedDate: body.ReceivedDate,
+			},
+			Actor: internal.ActorFromContext(r.Context()).Subject,
+		}
+
+		// receive the lot in the service
+		err = h.sv.Receive(&receipt)
+
+		// check for errors
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrLotServiceInvalidField):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+			case errors.Is(err, internal.ErrProductRepositoryNotFound):
+				response.Error(w, http.StatusNotFound, "product not found")
+			case errors.Is(err, internal.ErrLotRepositoryDuplicated):
+				response.Error(w, http.StatusConflict, "lot already exists")
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"data": lotToJSON(receipt.Lot),
+		})
+	}
+}
+
+// GetStockReport returns the lots in stock ordered by expiration, only the ones expiring before ?before=YYYY-MM-DD when given
+func (h *LotDefault) GetStockReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the report from the service
+		stock, err := h.sv.StockReport(r.URL.Query().Get("before"))
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrLotServiceInvalidField):
+				response.Error(w, http.StatusBadRequest, err.Error())
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// serealize to json
+		stockJSON := make([]LotStockJSON, 0)
+		for _, item := range stock {
+			stockJSON = append(stockJSON, LotStockJSON{
+				LotJSON:     lotToJSON(item.Lot),
+				ProductName: item.ProductName,
+				StockValue:  item.StockValue,
+			})
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": stockJSON,
+		})
+	}
+}
+
+// lotToJSON serializes a lot
+func lotToJSON(lot internal.Lot) LotJSON {
+	return LotJSON{
+		Id:               lot.ID,
+		ProductId:        lot.ProductID,
+		LotNumber:        lot.LotNumber,
+		Quantity:         lot.Quantity,
+		ReceivedQuantity: lot.ReceivedQuantity,
+		Expiration:       lot.Expiration,
+		ReceivedDate:     lot.ReceivedDate,
+	}
+}
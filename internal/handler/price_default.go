@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+	"time"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PriceChangeJSON struct {
+	Id            int       `json:"id"`
+	ProductId     int       `json:"product_id"`
+	Price         float64   `json:"price"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	Applied       bool      `json:"applied"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type BodyRequestPriceChangeJSON struct {
+	Price         float64   `json:"price"`
+	EffectiveFrom time.Time `json:"effective_from"`
+}
+
+// NewPriceDefault creates a new instance of the price handler
+func NewPriceDefault(sv internal.PriceService) *PriceDefault {
+	return &PriceDefault{
+		sv: sv,
+	}
+}
+
+type PriceDefault struct {
+	// sv is the service used by the handler
+	sv internal.PriceService
+}
+
+// GetByProductID returns the price history of a product, or its price at the as_of query parameter (RFC 3339)
+func (h *PriceDefault) GetByProductID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the price at a point in time
+		if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+			at, err := time.Parse(time.RFC3339, asOf)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "failed to parse as_of, expected RFC 3339")
+				return
+			}
+
+			change, err := h.sv.FindAsOf(id, at)
+			if err != nil {
+				switch {
+				case errors.Is(err, internal.ErrPriceRepositoryNotFound):
+					response.Error(w, http.StatusNotFound, "no price recorded at the given time")
+				default:
+					response.Error(w, http.StatusInternalServerError, "internal server error")
+				}
+				return
+			}
+
+			response.JSON(w, http.StatusOK, map[string]any{
+				"data": priceChangeToJSON(change),
+			})
+			return
+		}
+
+		// get the history from the service
+		changes, err := h.sv.FindByProductID(id)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		changesJSON := make([]PriceChangeJSON, 0)
+		for _, change := range changes {
+			changesJSON = append(changesJSON, priceChangeToJSON(change))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": changesJSON,
+		})
+	}
+}
+
+// Schedule records a future price change of a product
+func (h *PriceDefault) Schedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestPriceChangeJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// schedule the change in the service
+		change := internal.PriceChange{
+			ProductID:     id,
+			Price:         body.Price,
+			EffectiveFrom: body.EffectiveFrom,
+		}
+		err = h.sv.Schedule(&change)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrPriceServiceInvalidField):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+			case errors.Is(err, internal.ErrProductRepositoryNotFound):
+				response.Error(w, http.StatusNotFound, "product not found")
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"data": priceChangeToJSON(change),
+		})
+	}
+}
+
+// priceChangeToJSON serializes a price change
+func priceChangeToJSON(change internal.PriceChange) PriceChangeJSON {
+	return PriceChangeJSON{
+		Id:            change.ID,
+		ProductId:     change.ProductID,
+		Price:         change.Price,
+		EffectiveFrom: change.EffectiveFrom,
+		Applied:       change.Applied,
+		CreatedAt:     change.CreatedAt,
+	}
+}
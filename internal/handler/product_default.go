@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"storage/internal"
+	"time"
 
 	"strconv"
 
@@ -31,6 +32,12 @@ type ProductJSON struct {
 	Expiration string `json:"expiration"`
 	// Price is the price of the product
 	Price float64 `json:"price"`
+	// WarehouseQuantity is the stock of the product allocated to warehouses
+	WarehouseQuantity int `json:"warehouse_quantity"`
+	// CreatedAt is when the product was created
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is when a field of the product last changed
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type BodyRequestProductJSON struct {
@@ -60,25 +67,130 @@ type ResponseProductJSON struct {
 	Data []ProductJSON `json:"data"`
 }
 
+type BodyRequestCodeValuesJSON struct {
+	Codes []string `json:"codes"`
+}
+
+type ResponseProductCodeValuesJSON struct {
+	Data     []ProductJSON `json:"data"`
+	NotFound []string      `json:"not_found"`
+}
+
+type ProductCodeJSON struct {
+	// Format is the format the code was given in
+	Format string `json:"format"`
+	// Value is the canonical form of the code
+	Value string `json:"value"`
+}
+
+type ProductSearchResultJSON struct {
+	ProductJSON
+	// Score is the relevance of the product for the search
+	Score float64 `json:"score"`
+}
+
+type ResponseProductSearchJSON struct {
+	Data     []ProductSearchResultJSON `json:"data"`
+	Page     int                       `json:"page"`
+	PageSize int                       `json:"page_size"`
+	Total    int                       `json:"total"`
+}
+
+type ProductExpirationWeekJSON struct {
+	WeekStart  string  `json:"week_start"`
+	Count      int     `json:"count"`
+	Quantity   int     `json:"quantity"`
+	StockValue float64 `json:"stock_value"`
+}
+
+type ResponseProductExpirationSummaryJSON struct {
+	Data []ProductExpirationWeekJSON `json:"data"`
+}
+
 // NewProductDefault creates a new instance of the product handler
 
 func NewProductDefault(sv internal.ProductService) *ProductDefault {
 	return &ProductDefault{
-		sv: sv,
+		sv:           sv,
+		cacheControl: DefaultCacheControl,
 	}
 }
 
 type ProductDefault struct {
 	// sv is the service used by the handler
 	sv internal.ProductService
+	// authorizer checks the permissions beyond the one of the route, nil allows everything
+	authorizer internal.Authorizer
+	// cacheControl is the Cache-Control of the product reads
+	cacheControl string
 }
 
-// GetAll returns all products
+// SetCacheControl sets the Cache-Control of the product reads, e.g. "private, max-age=60"
+func (h *ProductDefault) SetCacheControl(cacheControl string) {
+	h.cacheControl = cacheControl
+}
+
+// SetAuthorizer sets the policy that checks the permissions beyond the one of the route
+func (h *ProductDefault) SetAuthorizer(authorizer internal.Authorizer) {
+	h.authorizer = authorizer
+}
+
+// permitted reports whether the principal of the request has the permission
+func (h *ProductDefault) permitted(r *http.Request, permission string) bool {
+	if h.authorizer == nil {
+		return true
+	}
+	principal, _ := internal.PrincipalFromContext(r.Context())
+	return h.authorizer.Allowed(principal, permission)
+}
+
+// writer returns the service performing the mutations of the request
+// - without the prices:write permission the prices can not be changed, checked by the service against the
+// row locked by the write
+func (h *ProductDefault) writer(r *http.Request) internal.ProductService {
+	sv := h.sv.WithActor(internal.ActorFromContext(r.Context()))
+	if !h.permitted(r, internal.PermissionPricesWrite) {
+		sv = sv.WithFixedPrice()
+	}
+	return sv
+}
+
+// GetAll returns all products, or those of the category query parameter and its subcategories
+// - the whole catalog answers 304 to the clients holding its current version, by ETag or Last-Modified
 func (h *ProductDefault) GetAll() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		//process
-		products, err := h.sv.FindAll()
+		var products []internal.Product
+		var err error
+		if category := r.URL.Query().Get("category"); category != "" {
+			// get category from query and convert to int
+			var categoryID int
+			categoryID, err = strconv.Atoi(category)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "failed to convert category to int")
+				return
+			}
+			products, err = h.sv.FindByCategoryID(categoryID)
+		} else {
+			// check the version of the catalog before reading it
+			// - the products of a category also depend on the categories, so only the whole catalog is validated
+			var version internal.ProductCatalogVersion
+			version, err = h.sv.CatalogVersion()
+			if err != nil {
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+				return
+			}
+			v := validators{
+				cacheControl: h.cacheControl,
+				etag:         newETag("products", version.Count, version.LastModified.UnixNano()),
+				lastModified: version.LastModified,
+			}
+			if v.notModified(w, r) {
+				return
+			}
+			products, err = h.sv.FindAll()
+		}
 		if err != nil {
 			response.Error(w, http.StatusInternalServerError, "internal server error")
 			return
@@ -87,15 +199,7 @@ func (h *ProductDefault) GetAll() http.HandlerFunc {
 		// serealize to json
 		productsJSON := make([]ProductJSON, 0)
 		for _, product := range products {
-			productsJSON = append(productsJSON, ProductJSON{
-				Id:          product.ID,
-				Name:        product.Name,
-				Quantity:    product.Quantity,
-				CodeValue:   product.CodeValue,
-				IsPublished: product.IsPublished,
-				Expiration:  product.Expiration,
-				Price:       product.Price,
-			})
+			productsJSON = append(productsJSON, toProductJSON(product))
 		}
 
 		//return response
@@ -135,11 +239,297 @@ func (h *ProductDefault) GetByID() http.HandlerFunc {
 			return
 		}
 
+		// answer 304 to the clients holding the current version
+		v := validators{
+			cacheControl: h.cacheControl,
+			etag:         newETag("product", product.ID, product.UpdatedAt.UnixNano()),
+			lastModified: product.UpdatedAt,
+		}
+		if v.notModified(w, r) {
+			return
+		}
+
 		// return response
+		// - the product is returned bare, as it always was, WarehouseQuantity included
 		response.JSON(w, http.StatusOK, product)
 	}
 }
 
+// GetByCodeValue returns a product by its code value
+func (h *ProductDefault) GetByCodeValue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get code from url
+		codeValue := chi.URLParam(r, "code")
+
+		// get the product from the service
+		product, err := h.sv.FindByCodeValue(codeValue)
+
+		// check for errors
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductRepositoryNotFound):
+				response.Error(w, http.StatusNotFound, "product not found")
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, ResponseProduct{
+			Data: toProductJSON(product),
+		})
+	}
+}
+
+// GetByCodeValues returns the products matching a batch of code values
+func (h *ProductDefault) GetByCodeValues() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		//get the body of the request
+		var body BodyRequestCodeValuesJSON
+		err := request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// get the products from the service
+		products, err := h.sv.FindByCodeValues(body.Codes)
+
+		// check for errors
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductServiceTooManyCodes):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// serealize to json
+		found := make(map[string]bool, len(products))
+		productsJSON := make([]ProductJSON, 0)
+		for _, product := range products {
+			found[product.CodeValue] = true
+			productsJSON = append(productsJSON, toProductJSON(product))
+		}
+
+		// collect the codes without a product, the products carry the canonical form of the codes
+		notFound := make([]string, 0)
+		for _, code := range body.Codes {
+			canonical := code
+			if productCode, err := h.sv.NormalizeCode(code); err == nil {
+				canonical = productCode.Value
+			}
+			if !found[canonical] {
+				notFound = append(notFound, code)
+			}
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, ResponseProductCodeValuesJSON{
+			Data:     productsJSON,
+			NotFound: notFound,
+		})
+	}
+}
+
+// ValidateCode returns the format and canonical form of the code query parameter
+func (h *ProductDefault) ValidateCode() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// normalize the code in the service
+		code, err := h.sv.NormalizeCode(r.URL.Query().Get("code"))
+
+		// check for errors
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductServiceInvalidField):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": ProductCodeJSON{
+				Format: code.Format,
+				Value:  code.Value,
+			},
+		})
+	}
+}
+
+// sameCode reports whether both codes have the same canonical form
+func (h *ProductDefault) sameCode(a, b string) bool {
+	codeA, err := h.sv.NormalizeCode(a)
+	if err != nil {
+		return false
+	}
+	codeB, err := h.sv.NormalizeCode(b)
+	if err != nil {
+		return false
+	}
+	return codeA.Value == codeB.Value
+}
+
+// Search returns the products matching the q query parameter ordered by relevance
+func (h *ProductDefault) Search() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the query parameters
+		query := internal.ProductSearchQuery{
+			Text: r.URL.Query().Get("q"),
+		}
+		var err error
+		if page := r.URL.Query().Get("page"); page != "" {
+			query.Page, err = strconv.Atoi(page)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "failed to convert page to int")
+				return
+			}
+		}
+		if pageSize := r.URL.Query().Get("page_size"); pageSize != "" {
+			query.PageSize, err = strconv.Atoi(pageSize)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "failed to convert page_size to int")
+				return
+			}
+		}
+
+		// search the products in the service
+		results, total, err := h.sv.Search(&query)
+
+		// check for errors
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductServiceInvalidField):
+				response.Error(w, http.StatusBadRequest, err.Error())
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// serealize to json
+		resultsJSON := make([]ProductSearchResultJSON, 0)
+		for _, result := range results {
+			resultsJSON = append(resultsJSON, ProductSearchResultJSON{
+				ProductJSON: toProductJSON(result.Product),
+				Score:       result.Score,
+			})
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, ResponseProductSearchJSON{
+			Data:     resultsJSON,
+			Page:     query.Page,
+			PageSize: query.PageSize,
+			Total:    total,
+		})
+	}
+}
+
+// GetExpiring returns the products expiring within the days query parameter (30 by default)
+func (h *ProductDefault) GetExpiring() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the days from the query and convert to int
+		days := 30
+		if value := r.URL.Query().Get("days"); value != "" {
+			var err error
+			days, err = strconv.Atoi(value)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "failed to convert days to int")
+				return
+			}
+		}
+
+		// get the products from the service
+		products, err := h.sv.FindExpiring(days)
+
+		// check for errors
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductServiceInvalidField):
+				response.Error(w, http.StatusBadRequest, err.Error())
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// serealize to json
+		productsJSON := make([]ProductJSON, 0)
+		for _, product := range products {
+			productsJSON = append(productsJSON, toProductJSON(product))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, ResponseProductJSON{
+			Data: productsJSON,
+		})
+	}
+}
+
+// GetExpired returns the products already expired
+func (h *ProductDefault) GetExpired() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the products from the service
+		products, err := h.sv.FindExpired()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		productsJSON := make([]ProductJSON, 0)
+		for _, product := range products {
+			productsJSON = append(productsJSON, toProductJSON(product))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, ResponseProductJSON{
+			Data: productsJSON,
+		})
+	}
+}
+
+// GetExpirationSummary returns the count and stock value of the products per week of expiration
+func (h *ProductDefault) GetExpirationSummary() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the summary from the service
+		weeks, err := h.sv.ExpirationSummary()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		weeksJSON := make([]ProductExpirationWeekJSON, 0)
+		for _, week := range weeks {
+			weeksJSON = append(weeksJSON, ProductExpirationWeekJSON{
+				WeekStart:  week.WeekStart,
+				Count:      week.Count,
+				Quantity:   week.Quantity,
+				StockValue: week.StockValue,
+			})
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, ResponseProductExpirationSummaryJSON{
+			Data: weeksJSON,
+		})
+	}
+}
+
 // Delete a product
 func (h *ProductDefault) Delete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -157,7 +547,7 @@ func (h *ProductDefault) Delete() http.HandlerFunc {
 		}
 
 		// delete the product from the service
-		err = h.sv.Delete(id)
+		err = h.sv.WithActor(internal.ActorFromContext(r.Context())).Delete(id)
 
 		// check for errors
 		if err != nil {
@@ -233,7 +623,7 @@ func (h *ProductDefault) Create() http.HandlerFunc {
 		}
 
 		// create the product in the service
-		err = h.sv.Create(&product)
+		err = h.sv.WithActor(internal.ActorFromContext(r.Context())).Create(&product)
 
 		// check for errors
 		if err != nil {
@@ -241,6 +631,9 @@ func (h *ProductDefault) Create() http.HandlerFunc {
 			case errors.Is(err, internal.ErrProductServiceInvalidField):
 				response.Error(w, http.StatusBadGateway, err.Error())
 				return
+			case errors.Is(err, internal.ErrProductServiceExpired):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+				return
 			case errors.Is(err, internal.ErrProductRepositoryDuplicated):
 				response.Error(w, http.StatusConflict, "product_code already exists")
 				return
@@ -250,15 +643,7 @@ func (h *ProductDefault) Create() http.HandlerFunc {
 		}
 
 		// parsing the product to ProductJSON
-		data := ProductJSON{
-			Id:          product.ID,
-			Name:        product.Name,
-			Quantity:    product.Quantity,
-			CodeValue:   product.CodeValue,
-			IsPublished: product.IsPublished,
-			Expiration:  product.Expiration,
-			Price:       product.Price,
-		}
+		data := toProductJSON(product)
 
 		// create the response
 		ProductJSON := ResponseProductJSON{
@@ -301,70 +686,151 @@ func (h *ProductDefault) Update() http.HandlerFunc {
 			return
 		}
 
-		// validate previus product exists
-		product, err := h.sv.FindByID(id)
+		// apply the changes to the product locked by the update
+		product, err := h.writer(r).Patch(id, func(product *internal.Product) {
+			reqBody := toProductJSON(*product)
+			updateProduct(&reqBody, bodyJSON)
+			product.Name = reqBody.Name
+			product.Quantity = reqBody.Quantity
+			product.CodeValue = reqBody.CodeValue
+			product.IsPublished = reqBody.IsPublished
+			product.Expiration = reqBody.Expiration
+			product.Price = reqBody.Price
+		})
 
 		// check for errors
 		if err != nil {
 			switch {
+			case errors.Is(err, internal.ErrProductRepositoryPriceForbidden):
+				response.Error(w, http.StatusForbidden, "missing permission "+internal.PermissionPricesWrite)
+				return
 			case errors.Is(err, internal.ErrProductRepositoryNotFound):
 				response.Error(w, http.StatusNotFound, "product not found")
 				return
+			case errors.Is(err, internal.ErrProductRepositoryDuplicated):
+				response.Error(w, http.StatusConflict, "product code already exists")
+				return
+			case errors.Is(err, internal.ErrProductRepositoryReservedStock):
+				response.Error(w, http.StatusConflict, "quantity below the reserved units")
+				return
+			case errors.Is(err, internal.ErrStockMovementRepositoryAllocatedStock):
+				response.Error(w, http.StatusConflict, "quantity below the stock allocated to the warehouses")
+				return
+			case errors.Is(err, internal.ErrProductServiceInvalidField), errors.Is(err, internal.ErrProductServiceExpired):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+				return
 			default:
 				response.Error(w, http.StatusInternalServerError, "internal server error")
 				return
 			}
 		}
 
-		// create the reqBody
-		reqBody := ProductJSON{
-			Id:          product.ID,
-			Name:        product.Name,
-			Quantity:    product.Quantity,
-			CodeValue:   product.CodeValue,
-			IsPublished: product.IsPublished,
-			Expiration:  product.Expiration,
-			Price:       product.Price,
+		//response
+		ProductListJSON := ResponseProduct{
+			Data: toProductJSON(product),
 		}
 
-		updateProduct(&reqBody, bodyJSON)
+		// return response
+		response.JSON(w, http.StatusCreated, ProductListJSON)
+	}
+}
+
+// UpsertByCodeValue creates or updates a product identified by its code value
+func (h *ProductDefault) UpsertByCodeValue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 
-		// create the productUpdate
-		productUpdate := internal.Product{
-			ID:          reqBody.Id,
-			Name:        reqBody.Name,
-			Quantity:    reqBody.Quantity,
-			CodeValue:   reqBody.CodeValue,
-			IsPublished: reqBody.IsPublished,
-			Expiration:  reqBody.Expiration,
-			Price:       reqBody.Price,
+		// get code value from url
+		codeValue := chi.URLParam(r, "code_value")
+
+		// read the request body to []bytes
+		bytes, err := io.ReadAll(r.Body)
+
+		// check for errors
+		if err != nil {
+			response.JSON(w, http.StatusBadRequest, map[string]any{
+				"message": "failed to read request body to []bytes",
+			})
+			return
 		}
 
-		// validate id in url and body are different
+		// transform the []bytes to map[string]any
+		var bodyMap map[string]any
+
+		// check for errors
+		if err := json.Unmarshal(bytes, &bodyMap); err != nil {
+			response.JSON(w, http.StatusBadRequest, map[string]any{
+				"message": "failed to transform []bytes to map[string]any",
+			})
+			return
+		}
+
+		// validate key exist in bodyMap
+		if err := validateExistsKeys(bodyMap, "name", "quantity", "is_published", "expiration", "price"); err != nil {
+			response.JSON(w, http.StatusUnprocessableEntity, map[string]any{
+				"message": "one or more keys have not been sent in the request body",
+			})
+			return
+		}
+
+		var body BodyRequestProductJSON
+
+		// check for errors
+		if err := json.Unmarshal(bytes, &body); err != nil {
+			response.JSON(w, http.StatusBadRequest, map[string]any{
+				"message": "failed to transform []bytes to BodyRequestProductJSON",
+			})
+			return
+		}
+
+		// validate code value in url and body are the same, in any of their forms
+		if body.CodeValue != "" && body.CodeValue != codeValue && !h.sameCode(body.CodeValue, codeValue) {
+			response.JSON(w, http.StatusBadRequest, map[string]any{
+				"message": "code_value in url and body are different",
+			})
+			return
+		}
+
+		// serialize the body to a product
+		product := internal.Product{
+			Name:        body.Name,
+			Quantity:    body.Quantity,
+			CodeValue:   codeValue,
+			IsPublished: body.IsPublished,
+			Expiration:  body.Expiration,
+			Price:       body.Price,
+		}
+
+		// upsert the product in the service
+		created, err := h.writer(r).Upsert(&product)
 
 		// check for errors
-		err = h.sv.Update(&productUpdate)
 		if err != nil {
 			switch {
-			case errors.Is(err, internal.ErrProductRepositoryNotFound):
-				response.Error(w, http.StatusNotFound, "product not found")
-				return
-			case errors.Is(err, internal.ErrProductRepositoryDuplicated):
-				response.Error(w, http.StatusConflict, "product code already exists")
-				return
+			case errors.Is(err, internal.ErrProductRepositoryPriceForbidden):
+				response.Error(w, http.StatusForbidden, "missing permission "+internal.PermissionPricesWrite)
+			case errors.Is(err, internal.ErrProductServiceInvalidField), errors.Is(err, internal.ErrProductServiceExpired):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+			case errors.Is(err, internal.ErrProductRepositoryReservedStock):
+				response.Error(w, http.StatusConflict, "quantity below the reserved units")
+			case errors.Is(err, internal.ErrStockMovementRepositoryAllocatedStock):
+				response.Error(w, http.StatusConflict, "quantity below the stock allocated to the warehouses")
 			default:
 				response.Error(w, http.StatusInternalServerError, "internal server error")
-				return
 			}
+			return
 		}
 
-		//response
-		ProductListJSON := ResponseProduct{
-			Data: reqBody,
-		}
+		// parsing the product to ProductJSON
+		data := toProductJSON(product)
 
 		// return response
-		response.JSON(w, http.StatusCreated, ProductListJSON)
+		code := http.StatusOK
+		if created {
+			code = http.StatusCreated
+		}
+		response.JSON(w, code, ResponseProduct{
+			Data: data,
+		})
 	}
 }
 
@@ -379,6 +845,22 @@ func validateExistsKeys(mp map[string]any, keys ...string) (err error) {
 	return
 }
 
+// toProductJSON serializes a product as the endpoints respond it
+func toProductJSON(product internal.Product) ProductJSON {
+	return ProductJSON{
+		Id:                product.ID,
+		Name:              product.Name,
+		Quantity:          product.Quantity,
+		CodeValue:         product.CodeValue,
+		IsPublished:       product.IsPublished,
+		Expiration:        product.Expiration,
+		Price:             product.Price,
+		WarehouseQuantity: product.WarehouseQuantity,
+		CreatedAt:         product.CreatedAt,
+		UpdatedAt:         product.UpdatedAt,
+	}
+}
+
 func updateProduct(productPersisted *ProductJSON, productUpdated ProductJSON) {
 	if productUpdated.Name != "" {
 		productPersisted.Name = productUpdated.Name
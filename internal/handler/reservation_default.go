@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+	"time"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ReservationJSON struct {
+	Id        int       `json:"id"`
+	ProductId int       `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type BodyRequestReservationJSON struct {
+	ProductId  int `json:"product_id"`
+	Quantity   int `json:"quantity"`
+	TTLSeconds int `json:"ttl_seconds"`
+}
+
+type ProductAvailabilityJSON struct {
+	ProductId int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+	Reserved  int `json:"reserved"`
+	Available int `json:"available"`
+}
+
+// NewReservationDefault creates a new instance of the reservation handler
+func NewReservationDefault(sv internal.ReservationService) *ReservationDefault {
+	return &ReservationDefault{
+		sv: sv,
+	}
+}
+
+type ReservationDefault struct {
+	// sv is the service used by the handler
+	sv internal.ReservationService
+}
+
+// Create holds units of a product
+func (h *ReservationDefault) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		//get the body of the request
+		var body BodyRequestReservationJSON
+		err := request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// serialize the body to a reservation
+		reservation := internal.Reservation{
+			ProductID: body.ProductId,
+			Quantity:  body.Quantity,
+		}
+
+		// create the reservation in the service
+		err = h.sv.Create(&reservation, time.Duration(body.TTLSeconds)*time.Second)
+
+		// check for errors
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrReservationServiceInvalidField):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+			default:
+				reservationErrorResponse(w, err)
+			}
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"data": reservationToJSON(reservation),
+		})
+	}
+}
+
+// GetByID returns a reservation
+func (h *ReservationDefault) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the reservation from the service
+		reservation, err := h.sv.FindByID(id)
+		if err != nil {
+			reservationErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": reservationToJSON(reservation),
+		})
+	}
+}
+
+// Confirm takes the reserved units from the stock
+func (h *ReservationDefault) Confirm() http.HandlerFunc {
+	return h.transition(h.sv.Confirm)
+}
+
+// Release gives back the reserved units
+func (h *ReservationDefault) Release() http.HandlerFunc {
+	return h.transition(h.sv.Release)
+}
+
+// transition applies a change of state to the reservation of the url
+func (h *ReservationDefault) transition(fn func(id int) (internal.Reservation, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// apply the transition in the service
+		reservation, err := fn(id)
+		if err != nil {
+			reservationErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": reservationToJSON(reservation),
+		})
+	}
+}
+
+// GetAvailability returns the available stock of a product
+func (h *ReservationDefault) GetAvailability() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the availability from the service
+		availability, err := h.sv.Availability(id)
+		if err != nil {
+			reservationErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": ProductAvailabilityJSON{
+				ProductId: availability.ProductID,
+				Quantity:  availability.Quantity,
+				Reserved:  availability.Reserved,
+				Available: availability.Available,
+			},
+		})
+	}
+}
+
+// reservationErrorResponse writes the response of a reservation error
+func reservationErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, internal.ErrReservationRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "reservation not found")
+	case errors.Is(err, internal.ErrProductRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "product not found")
+	case errors.Is(err, internal.ErrReservationRepositoryNotActive):
+		response.Error(w, http.StatusConflict, "reservation not active")
+	case errors.Is(err, internal.ErrReservationRepositoryInsufficientStock):
+		response.Error(w, http.StatusConflict, "insufficient available stock")
+	case errors.Is(err, internal.ErrStockMovementRepositoryAllocatedStock):
+		response.Error(w, http.StatusConflict, "quantity below the stock allocated to the warehouses")
+	case errors.Is(err, internal.ErrProductRepositoryExpired):
+		response.Error(w, http.StatusUnprocessableEntity, "product expired")
+	default:
+		response.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+// reservationToJSON serializes a reservation
+func reservationToJSON(reservation internal.Reservation) ReservationJSON {
+	return ReservationJSON{
+		Id:        reservation.ID,
+		ProductId: reservation.ProductID,
+		Quantity:  reservation.Quantity,
+		Status:    string(reservation.Status),
+		CreatedAt: reservation.CreatedAt,
+		ExpiresAt: reservation.ExpiresAt,
+	}
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+	"time"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type StockMovementJSON struct {
+	Id            int                 `json:"id"`
+	ProductId     int                 `json:"product_id"`
+	Type          string              `json:"type"`
+	Quantity      int                 `json:"quantity"`
+	QuantityAfter int                 `json:"quantity_after"`
+	Reason        string              `json:"reason"`
+	Actor         string              `json:"actor"`
+	CreatedAt     time.Time           `json:"created_at"`
+	Lots          []LotAllocationJSON `json:"lots,omitempty"`
+}
+
+type BodyRequestStockMovementJSON struct {
+	Type     string `json:"type"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+}
+
+// NewStockMovementDefault creates a new instance of the stock movement handler
+func NewStockMovementDefault(sv internal.StockMovementService) *StockMovementDefault {
+	return &StockMovementDefault{
+		sv: sv,
+	}
+}
+
+type StockMovementDefault struct {
+	// sv is the service used by the handler
+	sv internal.StockMovementService
+}
+
+// GetByProductID returns the movement history of a product
+func (h *StockMovementDefault) GetByProductID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the movements from the service
+		movements, err := h.sv.FindByProductID(id)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		movementsJSON := make([]StockMovementJSON, 0)
+		for _, movement := range movements {
+			movementsJSON = append(movementsJSON, stockMovementToJSON(movement))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": movementsJSON,
+		})
+	}
+}
+
+// Create records a stock movement of a product
+func (h *StockMovementDefault) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestStockMovementJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// serialize the body to a movement, recorded by the authenticated principal
+		movement := internal.StockMovement{
+			ProductID: id,
+			Type:      internal.StockMovementType(body.Type),
+			Quantity:  body.Quantity,
+			Reason:    body.Reason,
+			Actor:     internal.ActorFromContext(r.Context()).Subject,
+		}
+
+		// create the movement in the service
+		err = h.sv.Create(&movement)
+
+		// check for errors
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrStockMovementServiceInvalidField):
+				response.Error(w, http.StatusUnprocessableEntity, err.Error())
+			case errors.Is(err, internal.ErrProductRepositoryNotFound):
+				response.Error(w, http.StatusNotFound, "product not found")
+			case errors.Is(err, internal.ErrStockMovementRepositoryNegativeStock):
+				response.Error(w, http.StatusConflict, "not enough stock")
+			case errors.Is(err, internal.ErrStockMovementRepositoryAllocatedStock):
+				response.Error(w, http.StatusConflict, "quantity below the stock allocated to the warehouses")
+			case errors.Is(err, internal.ErrProductRepositoryExpired):
+				response.Error(w, http.StatusUnprocessableEntity, "product expired")
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"data": stockMovementToJSON(movement),
+		})
+	}
+}
+
+// stockMovementToJSON serializes a stock movement
+func stockMovementToJSON(movement internal.StockMovement) StockMovementJSON {
+	return StockMovementJSON{
+		Id:            movement.ID,
+		ProductId:     movement.ProductID,
+		Type:          string(movement.Type),
+		Quantity:      movement.Quantity,
+		QuantityAfter: movement.QuantityAfter,
+		Reason:        movement.Reason,
+		Actor:         movement.Actor,
+		CreatedAt:     movement.CreatedAt,
+		Lots:          lotAllocationsToJSON(movement.Lots),
+	}
+}
+
+// lotAllocationsToJSON serializes the lots taken by a stock movement
+func lotAllocationsToJSON(allocations []internal.LotAllocation) (allocationsJSON []LotAllocationJSON) {
+	for _, allocation := range allocations {
+		allocationsJSON = append(allocationsJSON, LotAllocationJSON{
+			LotId:     allocation.LotID,
+			LotNumber: allocation.LotNumber,
+			Quantity:  allocation.Quantity,
+		})
+	}
+	return
+}
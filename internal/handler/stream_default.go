@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"storage/internal"
+	"storage/internal/sink"
+	"storage/internal/stream"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootcamp-go/web/response"
+)
+
+// NewStreamDefault creates a new instance of the product change stream handler
+func NewStreamDefault(hub *stream.Hub, sv internal.ProductService, svCategory internal.CategoryService, heartbeat time.Duration) *StreamDefault {
+	return &StreamDefault{
+		hub:        hub,
+		sv:         sv,
+		svCategory: svCategory,
+		heartbeat:  heartbeat,
+	}
+}
+
+type StreamDefault struct {
+	// hub broadcasts the events
+	hub *stream.Hub
+	// sv resolves the products of the categories filtered when connecting
+	sv internal.ProductService
+	// svCategory resolves whether the product of an event belongs to the categories filtered
+	svCategory internal.CategoryService
+	// heartbeat is the interval of the keep alive comments without events
+	heartbeat time.Duration
+}
+
+// GetEvents streams the product changes as Server-Sent Events
+// - ids and categories filter the products (either matches), the membership of the categories is resolved per event
+// - the Last-Event-ID header, or the last_event_id query parameter, resumes after that event; when the events after it
+// are no longer retained a reset event is sent instead, the client must reload the products and then apply the events
+func (h *StreamDefault) GetEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// the writer must flush every event
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			response.Error(w, http.StatusInternalServerError, "streaming not supported")
+			return
+		}
+
+		// get the filters from the query
+		filter, err := h.productFilter(r)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrInternalServerError):
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			default:
+				response.Error(w, http.StatusBadRequest, err.Error())
+			}
+			return
+		}
+
+		// get the event to resume after, -1 streams only the new events
+		after := -1
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("last_event_id")
+		}
+		if lastEventID != "" {
+			after, err = strconv.Atoi(lastEventID)
+			if err != nil || after < 0 {
+				response.Error(w, http.StatusBadRequest, "failed to convert last_event_id to int")
+				return
+			}
+		}
+
+		// start the stream
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 3000\n\n")
+		flusher.Flush()
+
+		send := func(event internal.Event) (err error) {
+			data, err := json.Marshal(sink.EventJSON(event))
+			if err != nil {
+				return
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+			return
+		}
+		ping := func() (err error) {
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+			return
+		}
+
+		reset := func() (err error) {
+			_, err = fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+			return
+		}
+
+		// stream until the client goes away, a slow client is closed to reconnect with its last event id
+		err = h.hub.Stream(r.Context(), after, filter, h.heartbeat, send, ping, reset)
+		if errors.Is(err, stream.ErrSlowSubscriber) {
+			fmt.Fprint(w, "event: overflow\ndata: {}\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// productFilter returns the filter of the events by the ids and categories query parameters, all pass when none is given
+// - the categories are checked for every event, so the products added to them later match; a deleted product
+// matches when it was known to belong to them, from connecting or from its previous events
+func (h *StreamDefault) productFilter(r *http.Request) (filter func(internal.Event) bool, err error) {
+	ids, err := intList(r.URL.Query().Get("ids"), "ids")
+	if err != nil {
+		return
+	}
+	categories, err := intList(r.URL.Query().Get("categories"), "categories")
+	if err != nil {
+		return
+	}
+	if len(ids) == 0 && len(categories) == 0 {
+		filter = func(internal.Event) bool { return true }
+		return
+	}
+
+	products := make(map[int]bool)
+	for _, id := range ids {
+		products[id] = true
+	}
+	members := make(map[int]bool)
+	for _, categoryID := range categories {
+		var found []internal.Product
+		found, err = h.sv.FindByCategoryID(categoryID)
+		if err != nil {
+			return
+		}
+		for _, product := range found {
+			members[product.ID] = true
+		}
+	}
+
+	// the stream calls the filter from a single goroutine
+	filter = func(event internal.Event) bool {
+		if products[event.ProductID] {
+			return true
+		}
+		if len(categories) == 0 {
+			return false
+		}
+		if event.Type == internal.EventProductDeleted {
+			member := members[event.ProductID]
+			delete(members, event.ProductID)
+			return member
+		}
+		found, err := h.svCategory.ContainsProduct(categories, event.ProductID)
+		if err != nil {
+			// keep the last known membership
+			return members[event.ProductID]
+		}
+		if found {
+			members[event.ProductID] = true
+		} else {
+			delete(members, event.ProductID)
+		}
+		return found
+	}
+	return
+}
+
+// intList parses a comma separated list of ints
+func intList(value, name string) (list []int, err error) {
+	if value == "" {
+		return
+	}
+	for _, item := range strings.Split(value, ",") {
+		var n int
+		n, err = strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			err = fmt.Errorf("failed to convert %s to a list of int", name)
+			return
+		}
+		list = append(list, n)
+	}
+	return
+}
@@ -0,0 +1,417 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type SupplierJSON struct {
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+type BodyRequestSupplierJSON struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+type ProductSupplierJSON struct {
+	ProductId    int     `json:"product_id"`
+	SupplierId   int     `json:"supplier_id"`
+	SupplierSKU  string  `json:"supplier_sku"`
+	CostPrice    float64 `json:"cost_price"`
+	LeadTimeDays int     `json:"lead_time_days"`
+	Preferred    bool    `json:"preferred"`
+}
+
+type BodyRequestProductSupplierJSON struct {
+	SupplierSKU  string  `json:"supplier_sku"`
+	CostPrice    float64 `json:"cost_price"`
+	LeadTimeDays int     `json:"lead_time_days"`
+	Preferred    bool    `json:"preferred"`
+}
+
+type ProductMarginJSON struct {
+	ProductId     int     `json:"product_id"`
+	SupplierId    int     `json:"supplier_id"`
+	Price         float64 `json:"price"`
+	CostPrice     float64 `json:"cost_price"`
+	Margin        float64 `json:"margin"`
+	MarginPercent float64 `json:"margin_percent"`
+}
+
+// NewSupplierDefault creates a new instance of the supplier handler
+func NewSupplierDefault(sv internal.SupplierService) *SupplierDefault {
+	return &SupplierDefault{
+		sv: sv,
+	}
+}
+
+type SupplierDefault struct {
+	// sv is the service used by the handler
+	sv internal.SupplierService
+}
+
+// GetAll returns all suppliers
+func (h *SupplierDefault) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the suppliers from the service
+		suppliers, err := h.sv.FindAll()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		suppliersJSON := make([]SupplierJSON, 0)
+		for _, supplier := range suppliers {
+			suppliersJSON = append(suppliersJSON, supplierToJSON(supplier))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": suppliersJSON,
+		})
+	}
+}
+
+// GetByID returns a supplier
+func (h *SupplierDefault) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the supplier from the service
+		supplier, err := h.sv.FindByID(id)
+		if err != nil {
+			supplierErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": supplierToJSON(supplier),
+		})
+	}
+}
+
+// Create creates a supplier
+func (h *SupplierDefault) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		//get the body of the request
+		var body BodyRequestSupplierJSON
+		err := request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// create the supplier in the service
+		supplier := internal.Supplier{
+			Name:  body.Name,
+			Email: body.Email,
+			Phone: body.Phone,
+		}
+		err = h.sv.Create(&supplier)
+		if err != nil {
+			supplierErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"data": supplierToJSON(supplier),
+		})
+	}
+}
+
+// Update updates a supplier
+func (h *SupplierDefault) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestSupplierJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// update the supplier in the service
+		supplier := internal.Supplier{
+			ID:    id,
+			Name:  body.Name,
+			Email: body.Email,
+			Phone: body.Phone,
+		}
+		err = h.sv.Update(&supplier)
+		if err != nil {
+			supplierErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": supplierToJSON(supplier),
+		})
+	}
+}
+
+// Delete deletes a supplier
+func (h *SupplierDefault) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// delete the supplier in the service
+		err = h.sv.Delete(id)
+		if err != nil {
+			supplierErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "supplier deleted successfully",
+			"data":    nil,
+		})
+	}
+}
+
+// GetProductSuppliers returns the suppliers of a product
+func (h *SupplierDefault) GetProductSuppliers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the links from the service
+		links, err := h.sv.FindByProductID(id)
+		if err != nil {
+			supplierErrorResponse(w, err)
+			return
+		}
+
+		// serealize to json
+		linksJSON := make([]ProductSupplierJSON, 0)
+		for _, link := range links {
+			linksJSON = append(linksJSON, productSupplierToJSON(link))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": linksJSON,
+		})
+	}
+}
+
+// SetProductSupplier creates or updates the conditions a supplier sells a product at
+func (h *SupplierDefault) SetProductSupplier() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get ids from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+		supplierID, err := strconv.Atoi(chi.URLParam(r, "supplier_id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert supplier_id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestProductSupplierJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// set the link in the service
+		link := internal.ProductSupplier{
+			ProductID:    id,
+			SupplierID:   supplierID,
+			SupplierSKU:  body.SupplierSKU,
+			CostPrice:    body.CostPrice,
+			LeadTimeDays: body.LeadTimeDays,
+			Preferred:    body.Preferred,
+		}
+		err = h.sv.SetProductSupplier(&link)
+		if err != nil {
+			supplierErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": productSupplierToJSON(link),
+		})
+	}
+}
+
+// DeleteProductSupplier deletes the link between a product and a supplier
+func (h *SupplierDefault) DeleteProductSupplier() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get ids from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+		supplierID, err := strconv.Atoi(chi.URLParam(r, "supplier_id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert supplier_id to int")
+			return
+		}
+
+		// delete the link in the service
+		err = h.sv.DeleteProductSupplier(id, supplierID)
+		if err != nil {
+			supplierErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "product supplier deleted successfully",
+			"data":    nil,
+		})
+	}
+}
+
+// GetMargins returns the margin of every product with suppliers
+func (h *SupplierDefault) GetMargins() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the margins from the service
+		margins, err := h.sv.FindMargins()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		marginsJSON := make([]ProductMarginJSON, 0)
+		for _, margin := range margins {
+			marginsJSON = append(marginsJSON, productMarginToJSON(margin))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": marginsJSON,
+		})
+	}
+}
+
+// GetProductMargin returns the margin of a product
+func (h *SupplierDefault) GetProductMargin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the margin from the service
+		margin, err := h.sv.FindMarginByProductID(id)
+		if err != nil {
+			supplierErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": productMarginToJSON(margin),
+		})
+	}
+}
+
+// supplierErrorResponse writes the response of a supplier error
+func supplierErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, internal.ErrSupplierServiceInvalidField):
+		response.Error(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, internal.ErrSupplierRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "supplier not found")
+	case errors.Is(err, internal.ErrProductSupplierRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "product supplier not found")
+	case errors.Is(err, internal.ErrProductRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "product not found")
+	case errors.Is(err, internal.ErrSupplierRepositoryDuplicated):
+		response.Error(w, http.StatusConflict, "supplier already exists")
+	default:
+		response.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+// supplierToJSON serializes a supplier
+func supplierToJSON(supplier internal.Supplier) SupplierJSON {
+	return SupplierJSON{
+		Id:    supplier.ID,
+		Name:  supplier.Name,
+		Email: supplier.Email,
+		Phone: supplier.Phone,
+	}
+}
+
+// productSupplierToJSON serializes a product supplier
+func productSupplierToJSON(link internal.ProductSupplier) ProductSupplierJSON {
+	return ProductSupplierJSON{
+		ProductId:    link.ProductID,
+		SupplierId:   link.SupplierID,
+		SupplierSKU:  link.SupplierSKU,
+		CostPrice:    link.CostPrice,
+		LeadTimeDays: link.LeadTimeDays,
+		Preferred:    link.Preferred,
+	}
+}
+
+// productMarginToJSON serializes a product margin
+func productMarginToJSON(margin internal.ProductMargin) ProductMarginJSON {
+	return ProductMarginJSON{
+		ProductId:     margin.ProductID,
+		SupplierId:    margin.SupplierID,
+		Price:         margin.Price,
+		CostPrice:     margin.CostPrice,
+		Margin:        margin.Margin,
+		MarginPercent: margin.MarginPercent,
+	}
+}
@@ -0,0 +1,396 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WarehouseJSON struct {
+	Id      int    `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type BodyRequestWarehouseJSON struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type WarehouseStockJSON struct {
+	ProductId   int `json:"product_id"`
+	WarehouseId int `json:"warehouse_id"`
+	Quantity    int `json:"quantity"`
+}
+
+type ProductStockJSON struct {
+	ProductId   int                  `json:"product_id"`
+	Quantity    int                  `json:"quantity"`
+	Allocated   int                  `json:"allocated"`
+	Unallocated int                  `json:"unallocated"`
+	Warehouses  []WarehouseStockJSON `json:"warehouses"`
+}
+
+type BodyRequestStockJSON struct {
+	Quantity int `json:"quantity"`
+}
+
+type BodyRequestTransferJSON struct {
+	FromWarehouseId int `json:"from_warehouse_id"`
+	ToWarehouseId   int `json:"to_warehouse_id"`
+	Quantity        int `json:"quantity"`
+}
+
+// NewWarehouseDefault creates a new instance of the warehouse handler
+func NewWarehouseDefault(sv internal.WarehouseService) *WarehouseDefault {
+	return &WarehouseDefault{
+		sv: sv,
+	}
+}
+
+type WarehouseDefault struct {
+	// sv is the service used by the handler
+	sv internal.WarehouseService
+}
+
+// GetAll returns all warehouses
+func (h *WarehouseDefault) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the warehouses from the service
+		warehouses, err := h.sv.FindAll()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		warehousesJSON := make([]WarehouseJSON, 0)
+		for _, warehouse := range warehouses {
+			warehousesJSON = append(warehousesJSON, WarehouseJSON{
+				Id:      warehouse.ID,
+				Name:    warehouse.Name,
+				Address: warehouse.Address,
+			})
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": warehousesJSON,
+		})
+	}
+}
+
+// GetByID returns a warehouse
+func (h *WarehouseDefault) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the warehouse from the service
+		warehouse, err := h.sv.FindByID(id)
+		if err != nil {
+			warehouseErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": WarehouseJSON{
+				Id:      warehouse.ID,
+				Name:    warehouse.Name,
+				Address: warehouse.Address,
+			},
+		})
+	}
+}
+
+// Create creates a warehouse
+func (h *WarehouseDefault) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		//get the body of the request
+		var body BodyRequestWarehouseJSON
+		err := request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// create the warehouse in the service
+		warehouse := internal.Warehouse{
+			Name:    body.Name,
+			Address: body.Address,
+		}
+		err = h.sv.Create(&warehouse)
+		if err != nil {
+			warehouseErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"data": WarehouseJSON{
+				Id:      warehouse.ID,
+				Name:    warehouse.Name,
+				Address: warehouse.Address,
+			},
+		})
+	}
+}
+
+// Update updates a warehouse
+func (h *WarehouseDefault) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestWarehouseJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// update the warehouse in the service
+		warehouse := internal.Warehouse{
+			ID:      id,
+			Name:    body.Name,
+			Address: body.Address,
+		}
+		err = h.sv.Update(&warehouse)
+		if err != nil {
+			warehouseErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": WarehouseJSON{
+				Id:      warehouse.ID,
+				Name:    warehouse.Name,
+				Address: warehouse.Address,
+			},
+		})
+	}
+}
+
+// Delete deletes a warehouse
+func (h *WarehouseDefault) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// delete the warehouse in the service
+		err = h.sv.Delete(id)
+		if err != nil {
+			warehouseErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "warehouse deleted successfully",
+			"data":    nil,
+		})
+	}
+}
+
+// GetStock returns the stock of the products in a warehouse
+func (h *WarehouseDefault) GetStock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the stock from the service
+		stocks, err := h.sv.FindStockByWarehouseID(id)
+		if err != nil {
+			warehouseErrorResponse(w, err)
+			return
+		}
+
+		// serealize to json
+		stocksJSON := make([]WarehouseStockJSON, 0)
+		for _, stock := range stocks {
+			stocksJSON = append(stocksJSON, WarehouseStockJSON{
+				ProductId:   stock.ProductID,
+				WarehouseId: stock.WarehouseID,
+				Quantity:    stock.Quantity,
+			})
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": stocksJSON,
+		})
+	}
+}
+
+// GetProductStock returns the stock of a product per warehouse
+func (h *WarehouseDefault) GetProductStock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the stock from the service
+		stock, err := h.sv.FindStockByProductID(id)
+		if err != nil {
+			warehouseErrorResponse(w, err)
+			return
+		}
+
+		// serealize to json
+		data := ProductStockJSON{
+			ProductId:   stock.ProductID,
+			Quantity:    stock.Quantity,
+			Allocated:   stock.Allocated,
+			Unallocated: stock.Unallocated,
+			Warehouses:  make([]WarehouseStockJSON, 0),
+		}
+		for _, s := range stock.Warehouses {
+			data.Warehouses = append(data.Warehouses, WarehouseStockJSON{
+				ProductId:   s.ProductID,
+				WarehouseId: s.WarehouseID,
+				Quantity:    s.Quantity,
+			})
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": data,
+		})
+	}
+}
+
+// SetProductStock sets the stock of a product in a warehouse
+func (h *WarehouseDefault) SetProductStock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get ids from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+		warehouseID, err := strconv.Atoi(chi.URLParam(r, "warehouse_id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert warehouse_id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestStockJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// set the stock in the service
+		stock := internal.WarehouseStock{
+			ProductID:   id,
+			WarehouseID: warehouseID,
+			Quantity:    body.Quantity,
+		}
+		err = h.sv.SetStock(&stock)
+		if err != nil {
+			warehouseErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": WarehouseStockJSON{
+				ProductId:   stock.ProductID,
+				WarehouseId: stock.WarehouseID,
+				Quantity:    stock.Quantity,
+			},
+		})
+	}
+}
+
+// TransferProductStock moves units of a product between warehouses
+func (h *WarehouseDefault) TransferProductStock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestTransferJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// transfer the stock in the service
+		err = h.sv.Transfer(id, body.FromWarehouseId, body.ToWarehouseId, body.Quantity)
+		if err != nil {
+			warehouseErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "stock transferred successfully",
+			"data":    nil,
+		})
+	}
+}
+
+// warehouseErrorResponse writes the response of a warehouse error
+func warehouseErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, internal.ErrWarehouseServiceInvalidField):
+		response.Error(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, internal.ErrWarehouseRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "warehouse not found")
+	case errors.Is(err, internal.ErrProductRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "product not found")
+	case errors.Is(err, internal.ErrWarehouseRepositoryDuplicated):
+		response.Error(w, http.StatusConflict, "warehouse already exists")
+	case errors.Is(err, internal.ErrWarehouseRepositoryNotEmpty):
+		response.Error(w, http.StatusConflict, "warehouse has stock")
+	case errors.Is(err, internal.ErrWarehouseRepositoryInsufficientStock):
+		response.Error(w, http.StatusConflict, "insufficient stock")
+	default:
+		response.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
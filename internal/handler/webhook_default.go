@@ -0,0 +1,403 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"storage/internal"
+	"strconv"
+	"time"
+
+	"github.com/bootcamp-go/web/request"
+	"github.com/bootcamp-go/web/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhookJSON struct {
+	Id         int       `json:"id"`
+	Url        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookCreatedJSON struct {
+	WebhookJSON
+	// Secret signs the deliveries, it is only returned when created
+	Secret string `json:"secret"`
+}
+
+type BodyRequestWebhookJSON struct {
+	Url        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+	Active     *bool    `json:"active"`
+}
+
+type BodyRequestWebhookUpdateJSON struct {
+	Url        *string   `json:"url"`
+	EventTypes *[]string `json:"event_types"`
+	Active     *bool     `json:"active"`
+}
+
+type WebhookDeliveryJSON struct {
+	Id             int                  `json:"id"`
+	WebhookId      int                  `json:"webhook_id"`
+	EventId        int                  `json:"event_id"`
+	EventType      string               `json:"event_type"`
+	Status         string               `json:"status"`
+	Attempts       int                  `json:"attempts"`
+	NextAttemptAt  *time.Time           `json:"next_attempt_at"`
+	LastStatusCode int                  `json:"last_status_code"`
+	LastError      string               `json:"last_error"`
+	CreatedAt      time.Time            `json:"created_at"`
+	DeliveredAt    *time.Time           `json:"delivered_at"`
+	Body           json.RawMessage      `json:"body,omitempty"`
+	History        []WebhookAttemptJSON `json:"history,omitempty"`
+}
+
+type WebhookAttemptJSON struct {
+	Id          int       `json:"id"`
+	StatusCode  int       `json:"status_code"`
+	Error       string    `json:"error"`
+	DurationMs  int64     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// NewWebhookDefault creates a new instance of the webhook handler
+func NewWebhookDefault(sv internal.WebhookService) *WebhookDefault {
+	return &WebhookDefault{
+		sv: sv,
+	}
+}
+
+type WebhookDefault struct {
+	// sv is the service used by the handler
+	sv internal.WebhookService
+}
+
+// GetAll returns all the webhooks, without their secrets
+func (h *WebhookDefault) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get the webhooks from the service
+		webhooks, err := h.sv.FindAll()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		// serealize to json
+		webhooksJSON := make([]WebhookJSON, 0)
+		for _, wh := range webhooks {
+			webhooksJSON = append(webhooksJSON, webhookToJSON(wh))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": webhooksJSON,
+		})
+	}
+}
+
+// GetByID returns a webhook, without its secret
+func (h *WebhookDefault) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the webhook from the service
+		wh, err := h.sv.FindByID(id)
+		if err != nil {
+			webhookErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": webhookToJSON(wh),
+		})
+	}
+}
+
+// Create creates a webhook, its secret is only returned in this response
+func (h *WebhookDefault) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		//get the body of the request
+		var body BodyRequestWebhookJSON
+		err := request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// create the webhook in the service, active by default
+		wh := internal.Webhook{
+			URL:        body.Url,
+			EventTypes: eventTypesFromJSON(body.EventTypes),
+			Secret:     body.Secret,
+			Active:     body.Active == nil || *body.Active,
+		}
+		err = h.sv.Create(&wh)
+		if err != nil {
+			webhookErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"data": WebhookCreatedJSON{
+				WebhookJSON: webhookToJSON(wh),
+				Secret:      wh.Secret,
+			},
+		})
+	}
+}
+
+// Update updates the url, event types or active flag of a webhook
+func (h *WebhookDefault) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		//get the body of the request
+		var body BodyRequestWebhookUpdateJSON
+		err = request.JSON(r, &body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+
+		// apply the fields given to the webhook
+		wh, err := h.sv.FindByID(id)
+		if err != nil {
+			webhookErrorResponse(w, err)
+			return
+		}
+		if body.Url != nil {
+			wh.URL = *body.Url
+		}
+		if body.EventTypes != nil {
+			wh.EventTypes = eventTypesFromJSON(*body.EventTypes)
+		}
+		if body.Active != nil {
+			wh.Active = *body.Active
+		}
+
+		// update the webhook in the service
+		err = h.sv.Update(&wh)
+		if err != nil {
+			webhookErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": webhookToJSON(wh),
+		})
+	}
+}
+
+// Delete deletes a webhook and its deliveries
+func (h *WebhookDefault) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// delete the webhook in the service
+		err = h.sv.Delete(id)
+		if err != nil {
+			webhookErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "webhook deleted successfully",
+			"data":    nil,
+		})
+	}
+}
+
+// GetDeliveries returns the latest deliveries of a webhook, filtered by the status query parameter
+func (h *WebhookDefault) GetDeliveries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get id from url and convert to int
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+			return
+		}
+
+		// get the deliveries from the service
+		deliveries, err := h.sv.FindDeliveries(id, internal.WebhookDeliveryStatus(r.URL.Query().Get("status")))
+		if err != nil {
+			webhookErrorResponse(w, err)
+			return
+		}
+
+		// serealize to json
+		deliveriesJSON := make([]WebhookDeliveryJSON, 0)
+		for _, delivery := range deliveries {
+			deliveriesJSON = append(deliveriesJSON, webhookDeliveryToJSON(delivery, false))
+		}
+
+		//return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": deliveriesJSON,
+		})
+	}
+}
+
+// GetDelivery returns a delivery of a webhook with its body and attempts
+func (h *WebhookDefault) GetDelivery() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get ids from url and convert to int
+		id, deliveryID, ok := webhookDeliveryIDs(w, r)
+		if !ok {
+			return
+		}
+
+		// get the delivery from the service
+		delivery, err := h.sv.FindDelivery(id, deliveryID)
+		if err != nil {
+			webhookErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"data": webhookDeliveryToJSON(delivery, true),
+		})
+	}
+}
+
+// Replay attempts a failed delivery of a webhook again
+func (h *WebhookDefault) Replay() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// get ids from url and convert to int
+		id, deliveryID, ok := webhookDeliveryIDs(w, r)
+		if !ok {
+			return
+		}
+
+		// replay the delivery in the service
+		err := h.sv.Replay(id, deliveryID)
+		if err != nil {
+			webhookErrorResponse(w, err)
+			return
+		}
+
+		// return response
+		response.JSON(w, http.StatusAccepted, map[string]any{
+			"message": "delivery scheduled successfully",
+			"data":    nil,
+		})
+	}
+}
+
+// webhookDeliveryIDs reads the webhook and delivery ids of the url, responding 400 when one is invalid
+func webhookDeliveryIDs(w http.ResponseWriter, r *http.Request) (id, deliveryID int, ok bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "failed to convert id to int")
+		return
+	}
+	deliveryID, err = strconv.Atoi(chi.URLParam(r, "delivery_id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "failed to convert delivery_id to int")
+		return
+	}
+	ok = true
+	return
+}
+
+// webhookToJSON serializes a webhook, without its secret
+func webhookToJSON(wh internal.Webhook) WebhookJSON {
+	eventTypes := make([]string, 0, len(wh.EventTypes))
+	for _, eventType := range wh.EventTypes {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+	return WebhookJSON{
+		Id:         wh.ID,
+		Url:        wh.URL,
+		EventTypes: eventTypes,
+		Active:     wh.Active,
+		CreatedAt:  wh.CreatedAt,
+	}
+}
+
+// webhookDeliveryToJSON serializes a delivery, with its body and attempts when detailed
+func webhookDeliveryToJSON(delivery internal.WebhookDelivery, detailed bool) (deliveryJSON WebhookDeliveryJSON) {
+	deliveryJSON = WebhookDeliveryJSON{
+		Id:             delivery.ID,
+		WebhookId:      delivery.WebhookID,
+		EventId:        delivery.EventID,
+		EventType:      string(delivery.EventType),
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+	if delivery.Status == internal.WebhookDeliveryPending {
+		deliveryJSON.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if detailed {
+		deliveryJSON.Body = delivery.Body
+		deliveryJSON.History = make([]WebhookAttemptJSON, 0, len(delivery.History))
+		for _, attempt := range delivery.History {
+			deliveryJSON.History = append(deliveryJSON.History, WebhookAttemptJSON{
+				Id:          attempt.ID,
+				StatusCode:  attempt.StatusCode,
+				Error:       attempt.Error,
+				DurationMs:  attempt.Duration.Milliseconds(),
+				AttemptedAt: attempt.AttemptedAt,
+			})
+		}
+	}
+	return
+}
+
+// eventTypesFromJSON converts the event types of a body
+func eventTypesFromJSON(types []string) (eventTypes []internal.EventType) {
+	for _, t := range types {
+		eventTypes = append(eventTypes, internal.EventType(t))
+	}
+	return
+}
+
+// webhookErrorResponse writes the response of a webhook error
+func webhookErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, internal.ErrWebhookServiceInvalidField):
+		response.Error(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, internal.ErrWebhookRepositoryNotFound):
+		response.Error(w, http.StatusNotFound, "webhook not found")
+	case errors.Is(err, internal.ErrWebhookServiceNotReplayable):
+		response.Error(w, http.StatusConflict, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+// JobStatus is a struct that contains the state of a scheduled job
+type JobStatus struct {
+	// Name is the unique name of the job
+	Name string
+	// Schedule is the schedule expression of the job
+	Schedule string
+	// Running tells whether the job is currently running in this instance
+	Running bool
+	// LastRun is the start time of the last run, zero if it never ran
+	LastRun time.Time
+	// LastDuration is the duration of the last run
+	LastDuration time.Duration
+	// LastError is the error of the last run, empty if it succeeded
+	LastError string
+	// NextRun is the time of the next run
+	NextRun time.Time
+	// Runs is the amount of runs in this instance
+	Runs int
+	// Skipped is the amount of runs skipped because the job was still running or the lease was held elsewhere
+	Skipped int
+}
+
+var (
+	// ErrJobNotFound is the error returned when the job is not registered
+	ErrJobNotFound = errors.New("scheduler: job not found")
+)
+
+// JobLeaseRepository is an interface that contains the methods to coordinate jobs between replicas
+type JobLeaseRepository interface {
+	// Acquire takes the lease of the job for the owner during ttl, returns false if another owner holds it
+	Acquire(job, owner string, ttl time.Duration) (acquired bool, err error)
+	// Release ends the run of the owner keeping the lease for hold more, e.g. until the next tick,
+	// so the other replicas skip the tick already run
+	Release(job, owner string, hold time.Duration) error
+}
+
+// JobScheduler is an interface that contains the methods that the job scheduler should support
+type JobScheduler interface {
+	// Statuses returns the status of all the jobs
+	Statuses() []JobStatus
+	// Status returns the status of the job with the given name
+	Status(name string) (JobStatus, error)
+}
@@ -0,0 +1,281 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidKey is the error returned when a key of the set can not be parsed
+	ErrInvalidKey = errors.New("jwt: invalid key")
+	// ErrUnsupportedKey is the error returned when the type, curve or algorithm of a key is not supported
+	ErrUnsupportedKey = errors.New("jwt: unsupported key")
+)
+
+// Key is a verification key of the set
+type Key struct {
+	// ID is the kid of the key, it may be empty
+	ID string
+	// Algorithm is the algorithm the key is used with: RS256, ES256 or HS256
+	Algorithm string
+	// Public is the *rsa.PublicKey, *ecdsa.PublicKey or []byte secret of the key
+	Public any
+}
+
+// jwk is a JSON Web Key as defined by RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// symmetric
+	K string `json:"k"`
+}
+
+// ParseKeySet parses a JSON Web Key Set, the keys not meant for signatures or not supported are skipped
+// - sets are shared by several services, so a key of another algorithm (e.g. RS384 or Ed25519) is expected
+func ParseKeySet(data []byte) (keys []Key, err error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &set); err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidKey, err)
+		return
+	}
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key Key
+		key, err = parseKey(k)
+		if errors.Is(err, ErrUnsupportedKey) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+// parseKey parses a key, its algorithm follows its type and must match the alg member when given
+func parseKey(k jwk) (key Key, err error) {
+	key.ID = k.Kid
+	switch k.Kty {
+	case "RSA":
+		key.Algorithm = "RS256"
+		var n, e []byte
+		if n, err = decodeSegment(k.N); err != nil {
+			break
+		}
+		if e, err = decodeSegment(k.E); err != nil {
+			break
+		}
+		key.Public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		key.Algorithm = "ES256"
+		if k.Crv != "P-256" {
+			err = fmt.Errorf("%w: curve %q", ErrUnsupportedKey, k.Crv)
+			break
+		}
+		var x, y []byte
+		if x, err = decodeSegment(k.X); err != nil {
+			break
+		}
+		if y, err = decodeSegment(k.Y); err != nil {
+			break
+		}
+		public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !public.Curve.IsOnCurve(public.X, public.Y) {
+			err = errors.New("point is not on the curve")
+			break
+		}
+		key.Public = public
+	case "oct":
+		key.Algorithm = "HS256"
+		var secret []byte
+		if secret, err = decodeSegment(k.K); err != nil {
+			break
+		}
+		if len(secret) < 32 {
+			err = errors.New("secret shorter than 256 bits")
+			break
+		}
+		key.Public = secret
+	default:
+		err = fmt.Errorf("%w: key type %q", ErrUnsupportedKey, k.Kty)
+	}
+	if err == nil && k.Alg != "" && k.Alg != key.Algorithm {
+		err = fmt.Errorf("%w: algorithm %q for key type %q", ErrUnsupportedKey, k.Alg, k.Kty)
+	}
+	if err != nil && !errors.Is(err, ErrUnsupportedKey) {
+		err = fmt.Errorf("%w: %s: %w", ErrInvalidKey, k.Kid, err)
+	}
+	return
+}
+
+// NewJWKS creates a key set loaded from a file path or an http(s) url, the keys are loaded right away
+// - the keys are reloaded in the background every refresh interval, and on an unknown kid at most once per
+// minRefresh, so rotated keys are picked up; a failed reload keeps the previous keys
+func NewJWKS(location string, refresh, minRefresh time.Duration) (j *JWKS, err error) {
+	j = &JWKS{
+		location:   location,
+		refresh:    refresh,
+		minRefresh: minRefresh,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+	j.checkedAt = j.now()
+	j.keys, err = j.load()
+	return
+}
+
+// JWKS is a cached JSON Web Key Set
+type JWKS struct {
+	// location is the file path or url of the set
+	location string
+	// refresh is the interval after which the keys are reloaded
+	refresh time.Duration
+	// minRefresh is the minimum interval between reloads caused by unknown kids
+	minRefresh time.Duration
+	// client fetches the set from urls
+	client *http.Client
+	// now returns the current time
+	now func() time.Time
+
+	// mu guards the keys, the time of the last load attempt and the load in flight, it is never held while loading
+	mu        sync.Mutex
+	keys      []Key
+	checkedAt time.Time
+	// loading is closed when the load in flight finishes, nil if none
+	loading chan struct{}
+}
+
+// Find returns the key with the kid for the algorithm, or the only key for the algorithm when kid is empty
+// - an expired set is reloaded in the background while its keys keep being used, only an unknown kid waits
+// for the reload
+func (j *JWKS) Find(kid, algorithm string) (key Key, ok bool) {
+	j.mu.Lock()
+	key, ok = j.find(kid, algorithm)
+	elapsed := j.now().Sub(j.checkedAt)
+	// an unknown kid waits for the load in flight, or starts one
+	rotated := !ok && kid != "" && (j.loading != nil || elapsed >= j.minRefresh)
+	var loading chan struct{}
+	if rotated || elapsed >= j.refresh {
+		loading = j.startLoadLocked()
+	}
+	j.mu.Unlock()
+	if !rotated {
+		return
+	}
+
+	// an unknown kid may have been rotated in
+	<-loading
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	key, ok = j.find(kid, algorithm)
+	return
+}
+
+// find looks the key up in the loaded set
+func (j *JWKS) find(kid, algorithm string) (key Key, ok bool) {
+	candidates := 0
+	for _, k := range j.keys {
+		if k.Algorithm != algorithm {
+			continue
+		}
+		if kid != "" && k.ID == kid {
+			return k, true
+		}
+		if kid == "" {
+			key = k
+			candidates++
+		}
+	}
+	ok = candidates == 1
+	return
+}
+
+// startLoadLocked starts loading the set unless a load is in flight, and returns the channel closed when it
+// finishes; the lock must be held
+// - failed attempts count as checks too, so an unreachable set is not fetched on every request
+func (j *JWKS) startLoadLocked() chan struct{} {
+	if j.loading != nil {
+		return j.loading
+	}
+	loading := make(chan struct{})
+	j.loading = loading
+	j.checkedAt = j.now()
+
+	go func() {
+		keys, err := j.load()
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if err == nil {
+			j.keys = keys
+		}
+		j.loading = nil
+		close(loading)
+	}()
+	return loading
+}
+
+// load reads and parses the set
+func (j *JWKS) load() (keys []Key, err error) {
+	var data []byte
+	if strings.HasPrefix(j.location, "http://") || strings.HasPrefix(j.location, "https://") {
+		data, err = j.fetch()
+	} else {
+		data, err = os.ReadFile(j.location)
+	}
+	if err != nil {
+		return
+	}
+
+	keys, err = ParseKeySet(data)
+	return
+}
+
+// fetch gets the set from its url
+func (j *JWKS) fetch() (data []byte, err error) {
+	resp, err := j.client.Get(j.location)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("jwt: fetching %s: status %d", j.location, resp.StatusCode)
+		return
+	}
+	data, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return
+}
+
+// decodeSegment decodes base64url without padding, as used by JOSE
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,240 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keySet returns the JSON Web Key Set of the keys
+func keySet(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// newJWK returns the JWK of a new RSA key
+func newJWK(t *testing.T, kid string) map[string]string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := PublicJWK(kid, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwk
+}
+
+func TestParseKeySet(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec, err := PublicJWK("ec", ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaJWK := newJWK(t, "rsa")
+
+	t.Run("skips the unsupported keys", func(t *testing.T) {
+		rs384 := newJWK(t, "rs384")
+		rs384["alg"] = "RS384"
+		p384 := map[string]string{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"}
+		okp := map[string]string{"kty": "OKP", "kid": "ed25519", "crv": "Ed25519", "x": "AA"}
+		encryption := newJWK(t, "enc")
+		encryption["use"] = "enc"
+
+		keys, err := ParseKeySet(keySet(t, rs384, p384, rsaJWK, okp, encryption, ec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 2 || keys[0].ID != "rsa" || keys[0].Algorithm != "RS256" || keys[1].ID != "ec" || keys[1].Algorithm != "ES256" {
+			t.Fatalf("expected the rsa and ec keys, got %+v", keys)
+		}
+	})
+
+	t.Run("rejects a malformed supported key", func(t *testing.T) {
+		broken := map[string]string{"kty": "EC", "kid": "broken", "crv": "P-256", "x": ec["x"], "y": ec["x"]}
+		_, err := ParseKeySet(keySet(t, rsaJWK, broken))
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("expected %v, got %v", ErrInvalidKey, err)
+		}
+	})
+
+	t.Run("rejects a short secret", func(t *testing.T) {
+		_, err := ParseKeySet(keySet(t, map[string]string{"kty": "oct", "kid": "short", "k": "c2hvcnQ"}))
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("expected %v, got %v", ErrInvalidKey, err)
+		}
+	})
+}
+
+// keyServer serves a key set that can be replaced and delayed
+type keyServer struct {
+	mu       sync.Mutex
+	set      []byte
+	requests int
+	// release, when set, holds the responses until it is closed
+	release chan struct{}
+}
+
+func (s *keyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	set, release := s.set, s.release
+	s.mu.Unlock()
+
+	if release != nil {
+		<-release
+	}
+	w.Write(set)
+}
+
+func (s *keyServer) replace(set []byte, release chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set, s.release = set, release
+}
+
+func (s *keyServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// clock is a manual clock safe for concurrent use
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestJWKS_Find(t *testing.T) {
+	first, second := newJWK(t, "first"), newJWK(t, "second")
+	server := &keyServer{set: keySet(t, first)}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	jwks, err := NewJWKS(ts.URL, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := &clock{now: time.Unix(1700000000, 0)}
+	jwks.now = now.Now
+	jwks.checkedAt = now.Now()
+
+	if _, ok := jwks.Find("first", "RS256"); !ok {
+		t.Fatal("expected the first key")
+	}
+	if _, ok := jwks.Find("", "RS256"); !ok {
+		t.Fatal("expected the only RS256 key without kid")
+	}
+	if _, ok := jwks.Find("first", "ES256"); ok {
+		t.Fatal("expected no key for another algorithm")
+	}
+
+	// an unknown kid reloads the set at most once per minRefresh
+	server.replace(keySet(t, first, second), nil)
+	if _, ok := jwks.Find("second", "RS256"); ok {
+		t.Fatal("expected no reload before minRefresh")
+	}
+	now.Add(time.Minute)
+	if _, ok := jwks.Find("second", "RS256"); !ok {
+		t.Fatal("expected the rotated key after minRefresh")
+	}
+	if server.count() != 2 {
+		t.Fatalf("expected 2 fetches, got %d", server.count())
+	}
+
+	// a failed reload keeps the keys
+	server.replace([]byte("not a key set"), nil)
+	now.Add(time.Hour)
+	if _, ok := jwks.Find("first", "RS256"); !ok {
+		t.Fatal("expected the first key")
+	}
+	now.Add(time.Minute)
+	if _, ok := jwks.Find("unknown", "RS256"); ok {
+		t.Fatal("expected no unknown key")
+	}
+	if _, ok := jwks.Find("second", "RS256"); !ok {
+		t.Fatal("expected the keys to be kept after a failed reload")
+	}
+}
+
+func TestJWKS_Find_DoesNotBlockOnReload(t *testing.T) {
+	first, second := newJWK(t, "first"), newJWK(t, "second")
+	server := &keyServer{set: keySet(t, first)}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	jwks, err := NewJWKS(ts.URL, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := &clock{now: time.Unix(1700000000, 0)}
+	jwks.now = now.Now
+	jwks.checkedAt = now.Now()
+
+	// a slow endpoint serving a rotated set
+	release := make(chan struct{})
+	server.replace(keySet(t, first, second), release)
+	now.Add(time.Hour)
+
+	// the unknown kids wait for a single fetch
+	found := make(chan bool, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, ok := jwks.Find("second", "RS256")
+			found <- ok
+		}()
+	}
+
+	// the known kids keep being verified meanwhile
+	done := make(chan bool)
+	go func() {
+		_, ok := jwks.Find("first", "RS256")
+		done <- ok
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("expected the first key")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("find blocked on the reload of the set")
+	}
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		if !<-found {
+			t.Fatal("expected the rotated key once reloaded")
+		}
+	}
+	if server.count() != 2 {
+		t.Fatalf("expected 2 fetches, got %d", server.count())
+	}
+}
@@ -0,0 +1,214 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMalformed is the error returned when the token is not a compact JWS
+	ErrMalformed = errors.New("jwt: malformed token")
+	// ErrUnsupportedAlgorithm is the error returned when the algorithm of the token is not RS256, ES256 or HS256
+	ErrUnsupportedAlgorithm = errors.New("jwt: unsupported algorithm")
+	// ErrUnknownKey is the error returned when no key of the set verifies the token
+	ErrUnknownKey = errors.New("jwt: unknown key")
+	// ErrSignature is the error returned when the signature is wrong
+	ErrSignature = errors.New("jwt: invalid signature")
+	// ErrClaims is the error returned when the issuer, audience or validity period of the token are wrong
+	ErrClaims = errors.New("jwt: invalid claims")
+)
+
+// KeyFinder finds the key that verifies a token
+type KeyFinder interface {
+	// Find returns the key with the kid for the algorithm, or the only key for the algorithm when kid is empty
+	Find(kid, algorithm string) (Key, bool)
+}
+
+// Claims are the claims of a verified token
+type Claims map[string]any
+
+// String returns the claim as a string, empty when it is missing or not a string
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns the claim as a list of strings, a string claim is split by spaces as OAuth scopes are
+func (c Claims) Strings(name string) (values []string) {
+	switch v := c[name].(type) {
+	case string:
+		values = strings.Fields(v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return
+}
+
+// Time returns a NumericDate claim, ok is false when it is missing or not a number
+func (c Claims) Time(name string) (t time.Time, ok bool) {
+	seconds, ok := c[name].(float64)
+	if !ok {
+		return
+	}
+	t = time.Unix(int64(seconds), 0)
+	return
+}
+
+// Verifier verifies tokens signed by the keys of a set and validates their registered claims
+type Verifier struct {
+	// Keys finds the keys that verify the tokens
+	Keys KeyFinder
+	// Issuer is the expected iss claim, not checked when empty
+	Issuer string
+	// Audience is the expected aud claim, not checked when empty
+	Audience string
+	// Leeway is the tolerance for the clock skew on exp, nbf and iat
+	Leeway time.Duration
+	// Now returns the current time, time.Now when nil
+	Now func() time.Time
+}
+
+// Verify verifies the signature of the compact serialized token and validates its claims
+// - exp is required, nbf and iat are checked when present
+func (v *Verifier) Verify(token string) (claims Claims, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		err = ErrMalformed
+		return
+	}
+
+	// header
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err = decodeJSON(parts[0], &header); err != nil {
+		return
+	}
+	switch header.Alg {
+	case "RS256", "ES256", "HS256":
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, header.Alg)
+		return
+	}
+
+	// signature, the key must be of the algorithm of the header to prevent algorithm confusion
+	key, ok := v.Keys.Find(header.Kid, header.Alg)
+	if !ok {
+		err = fmt.Errorf("%w: %q", ErrUnknownKey, header.Kid)
+		return
+	}
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		err = ErrMalformed
+		return
+	}
+	if err = verifySignature(key, parts[0]+"."+parts[1], signature); err != nil {
+		return
+	}
+
+	// claims
+	if err = decodeJSON(parts[1], &claims); err != nil {
+		return
+	}
+	err = v.validate(claims)
+	return
+}
+
+// validate validates the registered claims
+func (v *Verifier) validate(claims Claims) (err error) {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	expiration, ok := claims.Time("exp")
+	if !ok {
+		return fmt.Errorf("%w: exp is required", ErrClaims)
+	}
+	if !now.Before(expiration.Add(v.Leeway)) {
+		return fmt.Errorf("%w: token expired", ErrClaims)
+	}
+	if notBefore, ok := claims.Time("nbf"); ok && now.Add(v.Leeway).Before(notBefore) {
+		return fmt.Errorf("%w: token not valid yet", ErrClaims)
+	}
+	if issuedAt, ok := claims.Time("iat"); ok && now.Add(v.Leeway).Before(issuedAt) {
+		return fmt.Errorf("%w: token issued in the future", ErrClaims)
+	}
+
+	if v.Issuer != "" && claims.String("iss") != v.Issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrClaims)
+	}
+	if v.Audience != "" {
+		// aud is a string or a list of strings
+		var audiences []string
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = []string{aud}
+		case []any:
+			audiences = claims.Strings("aud")
+		}
+		found := false
+		for _, audience := range audiences {
+			if audience == v.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: unexpected audience", ErrClaims)
+		}
+	}
+	return nil
+}
+
+// verifySignature verifies the signature of the signing input with the key
+func verifySignature(key Key, input string, signature []byte) error {
+	digest := sha256.Sum256([]byte(input))
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		// the signature is r and s as 32 byte big endian integers
+		if len(signature) == 64 {
+			r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+			if ecdsa.Verify(public, digest[:], r, s) {
+				return nil
+			}
+		}
+	case []byte:
+		mac := hmac.New(sha256.New, public)
+		mac.Write([]byte(input))
+		if hmac.Equal(mac.Sum(nil), signature) {
+			return nil
+		}
+	}
+	return ErrSignature
+}
+
+// decodeJSON decodes a base64url segment holding a JSON object
+func decodeJSON(segment string, v any) (err error) {
+	data, err := decodeSegment(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+// staticKeys is a key finder over a fixed list of keys
+type staticKeys []Key
+
+func (s staticKeys) Find(kid, algorithm string) (Key, bool) {
+	j := &JWKS{keys: s}
+	return j.find(kid, algorithm)
+}
+
+// publicKey returns the verification key of a private key through its JWK, as a set would publish it
+func publicKey(t *testing.T, kid string, private any) Key {
+	t.Helper()
+	jwk, err := PublicJWK(kid, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ParseKeySet(keySet(t, jwk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(keys))
+	}
+	return keys[0]
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	now := time.Unix(1700000000, 0)
+	verifier := &Verifier{
+		Keys:     staticKeys{publicKey(t, "rsa", rsaKey), publicKey(t, "ec", ecKey), publicKey(t, "hmac", secret)},
+		Issuer:   "https://issuer.example",
+		Audience: "storage",
+		Leeway:   30 * time.Second,
+		Now:      func() time.Time { return now },
+	}
+	valid := func() Claims {
+		return Claims{"sub": "user-1", "iss": "https://issuer.example", "aud": []any{"other", "storage"}, "exp": float64(now.Add(time.Minute).Unix())}
+	}
+
+	cases := []struct {
+		name    string
+		kid     string
+		key     any
+		claims  func() Claims
+		wantErr error
+	}{
+		{name: "RS256", kid: "rsa", key: rsaKey, claims: valid},
+		{name: "ES256", kid: "ec", key: ecKey, claims: valid},
+		{name: "HS256", kid: "hmac", key: secret, claims: valid},
+		{name: "audience as a string", kid: "rsa", key: rsaKey, claims: func() Claims {
+			c := valid()
+			c["aud"] = "storage"
+			return c
+		}},
+		{name: "expired within the leeway", kid: "rsa", key: rsaKey, claims: func() Claims {
+			c := valid()
+			c["exp"] = float64(now.Add(-10 * time.Second).Unix())
+			return c
+		}},
+		{name: "expired", kid: "rsa", key: rsaKey, wantErr: ErrClaims, claims: func() Claims {
+			c := valid()
+			c["exp"] = float64(now.Add(-time.Minute).Unix())
+			return c
+		}},
+		{name: "missing exp", kid: "rsa", key: rsaKey, wantErr: ErrClaims, claims: func() Claims {
+			c := valid()
+			delete(c, "exp")
+			return c
+		}},
+		{name: "not valid yet", kid: "rsa", key: rsaKey, wantErr: ErrClaims, claims: func() Claims {
+			c := valid()
+			c["nbf"] = float64(now.Add(time.Minute).Unix())
+			return c
+		}},
+		{name: "other issuer", kid: "rsa", key: rsaKey, wantErr: ErrClaims, claims: func() Claims {
+			c := valid()
+			c["iss"] = "https://attacker.example"
+			return c
+		}},
+		{name: "other audience", kid: "rsa", key: rsaKey, wantErr: ErrClaims, claims: func() Claims {
+			c := valid()
+			c["aud"] = "other"
+			return c
+		}},
+		{name: "missing audience", kid: "rsa", key: rsaKey, wantErr: ErrClaims, claims: func() Claims {
+			c := valid()
+			delete(c, "aud")
+			return c
+		}},
+		{name: "unknown kid", kid: "rotated", key: rsaKey, claims: valid, wantErr: ErrUnknownKey},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			token, err := Sign(c.claims(), c.kid, c.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := verifier.Verify(token)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("expected error %v, got %v", c.wantErr, err)
+			}
+			if err == nil && claims.String("sub") != "user-1" {
+				t.Fatalf("expected sub user-1, got %q", claims.String("sub"))
+			}
+		})
+	}
+}
+
+func TestVerifier_Verify_Tampered(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	verifier := &Verifier{
+		Keys: staticKeys{publicKey(t, "rsa", rsaKey)},
+		Now:  func() time.Time { return now },
+	}
+	claims := Claims{"sub": "user-1", "exp": float64(now.Add(time.Minute).Unix())}
+
+	t.Run("signed by another key", func(t *testing.T) {
+		token, err := Sign(claims, "rsa", otherKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifier.Verify(token); !errors.Is(err, ErrSignature) {
+			t.Fatalf("expected %v, got %v", ErrSignature, err)
+		}
+	})
+
+	t.Run("HS256 signed with the public key", func(t *testing.T) {
+		// the key of the kid is RS256, so the HMAC algorithm must not find it
+		jwk, err := PublicJWK("rsa", rsaKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := Sign(claims, "rsa", []byte(jwk["n"]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifier.Verify(token); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("expected %v, got %v", ErrUnknownKey, err)
+		}
+	})
+
+	t.Run("none algorithm", func(t *testing.T) {
+		token := "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.eyJzdWIiOiJ1c2VyLTEifQ."
+		if _, err := verifier.Verify(token); !errors.Is(err, ErrUnsupportedAlgorithm) {
+			t.Fatalf("expected %v, got %v", ErrUnsupportedAlgorithm, err)
+		}
+	})
+}
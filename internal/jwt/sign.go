@@ -0,0 +1,102 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Sign signs the claims with the private key, so tokens can be issued locally
+// - the key is an *rsa.PrivateKey (RS256), an *ecdsa.PrivateKey on P-256 (ES256) or a []byte secret (HS256)
+func Sign(claims Claims, kid string, key any) (token string, err error) {
+	header := map[string]string{"typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		header["alg"] = "RS256"
+	case *ecdsa.PrivateKey:
+		header["alg"] = "ES256"
+	case []byte:
+		header["alg"] = "HS256"
+	default:
+		err = fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, key)
+		return
+	}
+
+	// signing input
+	h, err := json.Marshal(header)
+	if err != nil {
+		return
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return
+	}
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+
+	// signature
+	var signature []byte
+	switch private := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, signErr := ecdsa.Sign(rand.Reader, private, digest[:])
+		err = signErr
+		signature = make([]byte, 64)
+		if err == nil {
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	case []byte:
+		mac := hmac.New(sha256.New, private)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	}
+	if err != nil {
+		return
+	}
+
+	token = input + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return
+}
+
+// PublicJWK returns the JSON Web Key of the public part of the key, the secret itself for HS256
+func PublicJWK(kid string, key any) (jwk map[string]string, err error) {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		jwk = map[string]string{"kty": "RSA", "alg": "RS256", "n": encode(k.N.Bytes()), "e": encode(bigEndian(k.E))}
+	case *ecdsa.PrivateKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		k.X.FillBytes(x)
+		k.Y.FillBytes(y)
+		jwk = map[string]string{"kty": "EC", "alg": "ES256", "crv": "P-256", "x": encode(x), "y": encode(y)}
+	case []byte:
+		jwk = map[string]string{"kty": "oct", "alg": "HS256", "k": encode(k)}
+	default:
+		err = fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, key)
+		return
+	}
+	jwk["use"] = "sig"
+	if kid != "" {
+		jwk["kid"] = kid
+	}
+	return
+}
+
+// bigEndian returns the minimal big endian bytes of a positive integer
+func bigEndian(n int) (b []byte) {
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return
+}
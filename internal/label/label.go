@@ -0,0 +1,207 @@
+package label
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"storage/internal/barcode"
+	"storage/internal/pdf"
+)
+
+// Layout is the arrangement of the labels on a sheet, the lengths are in points
+type Layout struct {
+	// Name is the name of the layout
+	Name string
+	// PageWidth is the width of the sheet
+	PageWidth float64
+	// PageHeight is the height of the sheet
+	PageHeight float64
+	// Columns is the amount of labels per row
+	Columns int
+	// Rows is the amount of labels per column
+	Rows int
+	// LabelWidth is the width of a label
+	LabelWidth float64
+	// LabelHeight is the height of a label
+	LabelHeight float64
+	// MarginLeft is the distance from the left edge of the sheet to the first column
+	MarginLeft float64
+	// MarginTop is the distance from the top edge of the sheet to the first row
+	MarginTop float64
+	// PitchX is the horizontal distance between the left edges of two adjacent labels
+	PitchX float64
+	// PitchY is the vertical distance between the top edges of two adjacent labels
+	PitchY float64
+}
+
+// PerSheet returns the amount of labels of a sheet
+func (l Layout) PerSheet() int {
+	return l.Columns * l.Rows
+}
+
+// Layouts are the layouts available by name
+var Layouts = map[string]Layout{
+	// 3 x 10 address labels of 2 5/8" x 1" on US letter
+	"avery-5160": {
+		Name: "avery-5160", PageWidth: 8.5 * pdf.Inch, PageHeight: 11 * pdf.Inch, Columns: 3, Rows: 10,
+		LabelWidth: 2.625 * pdf.Inch, LabelHeight: 1 * pdf.Inch, MarginLeft: 0.1875 * pdf.Inch, MarginTop: 0.5 * pdf.Inch,
+		PitchX: 2.75 * pdf.Inch, PitchY: 1 * pdf.Inch,
+	},
+	// 2 x 10 labels of 4" x 1" on US letter
+	"avery-5161": {
+		Name: "avery-5161", PageWidth: 8.5 * pdf.Inch, PageHeight: 11 * pdf.Inch, Columns: 2, Rows: 10,
+		LabelWidth: 4 * pdf.Inch, LabelHeight: 1 * pdf.Inch, MarginLeft: 0.15625 * pdf.Inch, MarginTop: 0.5 * pdf.Inch,
+		PitchX: 4.1875 * pdf.Inch, PitchY: 1 * pdf.Inch,
+	},
+	// 2 x 5 shipping labels of 4" x 2" on US letter
+	"avery-5163": {
+		Name: "avery-5163", PageWidth: 8.5 * pdf.Inch, PageHeight: 11 * pdf.Inch, Columns: 2, Rows: 5,
+		LabelWidth: 4 * pdf.Inch, LabelHeight: 2 * pdf.Inch, MarginLeft: 0.15625 * pdf.Inch, MarginTop: 0.5 * pdf.Inch,
+		PitchX: 4.1875 * pdf.Inch, PitchY: 2 * pdf.Inch,
+	},
+	// 3 x 7 labels of 63.5 x 38.1 mm on A4
+	"avery-l7160": {
+		Name: "avery-l7160", PageWidth: 210 * pdf.Millimeter, PageHeight: 297 * pdf.Millimeter, Columns: 3, Rows: 7,
+		LabelWidth: 63.5 * pdf.Millimeter, LabelHeight: 38.1 * pdf.Millimeter, MarginLeft: 7.2 * pdf.Millimeter, MarginTop: 15.15 * pdf.Millimeter,
+		PitchX: 66 * pdf.Millimeter, PitchY: 38.1 * pdf.Millimeter,
+	},
+}
+
+// DefaultLayout is the name of the layout used when none is given
+const DefaultLayout = "avery-5160"
+
+// LayoutNames returns the names of the layouts available, sorted
+func LayoutNames() (names []string) {
+	for name := range Layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Label is the content of a shelf label
+type Label struct {
+	// Name is the name of the product
+	Name string
+	// Price is the price of the product
+	Price float64
+	// Expiration is the date of expiration of the product (YYYY-MM-DD), omitted when empty
+	Expiration string
+	// Code is the code value of the product, rendered as EAN-13 when it is one and as Code 128 otherwise
+	Code string
+}
+
+// ErrInvalidLabel is the error returned when a label can not be rendered
+var ErrInvalidLabel = errors.New("label: invalid label")
+
+// padding is the space between the edge of a label and its content
+const padding = 6.0
+
+// WriteSheets writes the labels as a PDF of as many sheets of the layout as needed
+// - skip is the amount of positions already used on the first sheet, so partially used sheets can be reused
+func WriteSheets(w io.Writer, layout Layout, labels []Label, skip int) (err error) {
+	document := pdf.NewDocument()
+	var page *pdf.Page
+	for i, label := range labels {
+		position := (skip + i) % layout.PerSheet()
+		if page == nil || position == 0 {
+			page = document.AddPage(layout.PageWidth, layout.PageHeight)
+		}
+
+		// bottom left corner of the label, rows are counted from the top of the sheet
+		column, row := position%layout.Columns, position/layout.Columns
+		x := layout.MarginLeft + float64(column)*layout.PitchX
+		y := layout.PageHeight - layout.MarginTop - float64(row)*layout.PitchY - layout.LabelHeight
+
+		err = drawLabel(page, x, y, layout.LabelWidth, layout.LabelHeight, label)
+		if err != nil {
+			err = fmt.Errorf("%w: %s: %w", ErrInvalidLabel, label.Code, err)
+			return
+		}
+	}
+
+	// an empty request still produces a valid document
+	if page == nil {
+		document.AddPage(layout.PageWidth, layout.PageHeight)
+	}
+	err = document.Write(w)
+	return
+}
+
+// drawLabel draws the label in the rectangle with its bottom left corner at x, y
+// - the name on top, the price and expiration below it, and the barcode with its code filling the rest
+func drawLabel(page *pdf.Page, x, y, width, height float64, label Label) (err error) {
+	inner := width - 2*padding
+	top := y + height - padding
+
+	// scale the text to the height of the label
+	nameSize := min(10, height/8)
+	priceSize := min(14, height/6)
+	smallSize := min(7, height/11)
+
+	// name
+	top -= nameSize
+	page.Text(pdf.Helvetica, nameSize, x+padding, top, fit(pdf.Helvetica, nameSize, inner, label.Name))
+
+	// price and expiration
+	top -= priceSize + 2
+	page.Text(pdf.HelveticaBold, priceSize, x+padding, top, fmt.Sprintf("%.2f", label.Price))
+	if label.Expiration != "" {
+		expiration := "EXP " + label.Expiration
+		page.Text(pdf.Helvetica, smallSize, x+width-padding-pdf.TextWidth(pdf.Helvetica, smallSize, expiration), top, expiration)
+	}
+
+	// barcode and the code under it
+	bottom := y + padding
+	page.Text(pdf.Helvetica, smallSize, x+(width-pdf.TextWidth(pdf.Helvetica, smallSize, label.Code))/2, bottom, label.Code)
+	bottom += smallSize + 1
+	barHeight := top - 4 - bottom
+	if barHeight < 8 {
+		// too small for a readable barcode, the code is printed anyway
+		return
+	}
+
+	symbol, err := encode(label.Code)
+	if err != nil {
+		return
+	}
+	module := inner / float64(symbol.Width+2*symbol.QuietZone)
+	left := x + padding + float64(symbol.QuietZone)*module
+	for i := 0; i < symbol.Width; i++ {
+		if !symbol.At(i, 0) {
+			continue
+		}
+		// contiguous bars are drawn as a single rectangle
+		start := i
+		for i+1 < symbol.Width && symbol.At(i+1, 0) {
+			i++
+		}
+		page.Rect(left+float64(start)*module, bottom, float64(i-start+1)*module, barHeight)
+	}
+	return
+}
+
+// encode encodes the code as EAN-13 when it is one and as Code 128 otherwise
+func encode(code string) (symbol *barcode.Bitmap, err error) {
+	if len(code) == 13 && barcode.ValidCheckDigit(code) {
+		symbol, err = barcode.EncodeEAN13(code)
+		if err == nil {
+			return
+		}
+	}
+	symbol, err = barcode.EncodeCode128(code)
+	return
+}
+
+// fit truncates the text with an ellipsis so it fits in the width
+func fit(font pdf.Font, size, width float64, text string) string {
+	if pdf.TextWidth(font, size, text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.TextWidth(font, size, string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+// Lot is a struct that contains a received batch of units of a product
+type Lot struct {
+	// ID is the unique identifier of the lot
+	ID int
+	// ProductID is the identifier of the product
+	ProductID int
+	// LotNumber is the number given to the lot by the manufacturer, unique per product
+	LotNumber string
+	// Quantity is the amount of units of the lot still in stock
+	Quantity int
+	// ReceivedQuantity is the amount of units received in the lot
+	ReceivedQuantity int
+	// Expiration is the date of expiration of the lot (YYYY-MM-DD)
+	Expiration string
+	// ReceivedDate is the date the lot was received (YYYY-MM-DD)
+	ReceivedDate string
+}
+
+// LotAllocation is a struct that contains the units taken from a lot by a stock movement
+type LotAllocation struct {
+	// LotID is the identifier of the lot
+	LotID int
+	// LotNumber is the number of the lot
+	LotNumber string
+	// Quantity is the amount of units taken from the lot
+	Quantity int
+}
+
+// LotStock is a struct that contains a lot in stock for the stock by lot report
+type LotStock struct {
+	// Lot is the lot in stock
+	Lot Lot
+	// ProductName is the name of the product of the lot
+	ProductName string
+	// StockValue is the value (quantity * price) of the units of the lot
+	StockValue float64
+}
+
+// LotReceipt is a struct that contains the lot received and who received it
+type LotReceipt struct {
+	// Lot is the lot received
+	Lot Lot
+	// Actor is who received the lot
+	Actor string
+	// CreatedAt is the moment of the receipt
+	CreatedAt time.Time
+}
+
+var (
+	// ErrLotRepositoryDuplicated is the error returned when the product already has a lot with the same number
+	ErrLotRepositoryDuplicated = errors.New("repository: lot already exists")
+	// ErrLotServiceInvalidField is the error returned when the lot has an invalid field
+	ErrLotServiceInvalidField = errors.New("service: invalid field")
+)
+
+// LotRepository is an interface that contains the methods that the lot repository should support
+type LotRepository interface {
+	// FindByProductID returns the lots of the product ordered by expiration, the depleted ones only when asked
+	FindByProductID(productID int, includeDepleted bool) ([]Lot, error)
+	// Receive records the lot and adds its units to the product quantity atomically
+	// - it sets the ID of the lot and keeps the product expiration as the earliest live lot's date
+	Receive(receipt *LotReceipt) error
+	// StockReport returns the lots in stock ordered by expiration, only the ones expiring before the date when given (YYYY-MM-DD)
+	StockReport(before string) ([]LotStock, error)
+}
+
+// LotService is an interface that contains the methods that the lot service should support
+type LotService interface {
+	// FindByProductID returns the lots of the product
+	FindByProductID(productID int, includeDepleted bool) ([]Lot, error)
+	// Receive records a lot received for a product
+	Receive(receipt *LotReceipt) error
+	// StockReport returns the lots in stock, only the ones expiring before the date when given (YYYY-MM-DD)
+	StockReport(before string) ([]LotStock, error)
+}
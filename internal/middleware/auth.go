@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"storage/internal"
+	"storage/internal/websocket"
+	"strings"
+
+	"github.com/bootcamp-go/web/response"
+)
+
+// AuthenticateFunc returns the principal of the credentials of an Authorization header scheme
+type AuthenticateFunc func(credentials string) (internal.Principal, error)
+
+// NewAuth creates a new instance of the authentication middleware without schemes
+func NewAuth() *Auth {
+	return &Auth{
+		schemes: make(map[string]AuthenticateFunc),
+	}
+}
+
+// Auth is the middleware that authenticates the requests by their Authorization header
+// - requests without valid credentials are rejected with 401, the others carry their principal in the context
+type Auth struct {
+	// schemes are the functions that authenticate the credentials by lowercase scheme
+	schemes map[string]AuthenticateFunc
+	// names are the schemes in the order they were registered, to list them in WWW-Authenticate
+	names []string
+}
+
+// Register registers the function that authenticates the credentials of the scheme, e.g. ApiKey
+func (a *Auth) Register(scheme string, authenticate AuthenticateFunc) {
+	a.schemes[strings.ToLower(scheme)] = authenticate
+	a.names = append(a.names, scheme)
+}
+
+// Handler returns the middleware
+func (a *Auth) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// get the scheme and credentials from the header
+		// - browsers cannot set headers on a WebSocket handshake, so it may carry them in a subprotocol
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" && websocket.IsUpgrade(r) {
+			authorization, _ = websocket.Authorization(r)
+		}
+		scheme, credentials, found := strings.Cut(authorization, " ")
+		authenticate, ok := a.schemes[strings.ToLower(scheme)]
+		if !found || !ok {
+			a.unauthorized(w, "missing or unsupported authorization")
+			return
+		}
+
+		// authenticate the credentials
+		principal, err := authenticate(strings.TrimSpace(credentials))
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrUnauthenticated):
+				a.unauthorized(w, "invalid credentials")
+			default:
+				response.Error(w, http.StatusInternalServerError, "internal server error")
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(internal.ContextWithPrincipal(r.Context(), principal)))
+	})
+}
+
+// unauthorized writes a 401 response challenging the client with the registered schemes
+func (a *Auth) unauthorized(w http.ResponseWriter, message string) {
+	for _, scheme := range a.names {
+		w.Header().Add("WWW-Authenticate", scheme)
+	}
+	response.Error(w, http.StatusUnauthorized, message)
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+	"storage/internal"
+
+	"github.com/bootcamp-go/web/response"
+)
+
+// Require returns the middleware that rejects with 403 the requests whose principal lacks the permission
+func Require(authorizer internal.Authorizer, permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := internal.PrincipalFromContext(r.Context())
+			if !authorizer.Allowed(principal, permission) {
+				response.Error(w, http.StatusForbidden, "missing permission "+permission)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"fmt"
+	"storage/internal"
+	"storage/internal/jwt"
+)
+
+// JWTAuthenticate returns the function that authenticates bearer tokens with the verifier
+// - the principal is the sub claim and its roles are the ones of the roles claim, a list or a space separated string
+func JWTAuthenticate(verifier *jwt.Verifier, rolesClaim string) AuthenticateFunc {
+	return func(credentials string) (principal internal.Principal, err error) {
+		claims, err := verifier.Verify(credentials)
+		if err != nil {
+			err = fmt.Errorf("%w: %w", internal.ErrUnauthenticated, err)
+			return
+		}
+
+		subject := claims.String("sub")
+		if subject == "" {
+			err = fmt.Errorf("%w: sub is required", internal.ErrUnauthenticated)
+			return
+		}
+		name := claims.String("name")
+		if name == "" {
+			name = subject
+		}
+		principal = internal.Principal{
+			Subject: "jwt:" + subject,
+			Name:    name,
+			Method:  "jwt",
+			Roles:   claims.Strings(rolesClaim),
+		}
+		return
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"storage/internal"
+)
+
+// RequestIDHeader is the header carrying the id of the request, in the request and the response
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of a request id given by the client
+const maxRequestIDLength = 128
+
+// RequestID is the middleware that sets the id of the request in its context and the response
+// - the id given by the client is kept when it is printable and short enough, otherwise a random one is generated
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(internal.ContextWithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID reports whether the request id is not empty, short enough and printable ascii
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random request id of 32 hex characters
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
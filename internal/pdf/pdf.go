@@ -0,0 +1,163 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Font is one of the standard fonts every PDF reader provides, so they are not embedded
+type Font string
+
+const (
+	// Helvetica is the regular sans serif font
+	Helvetica Font = "F1"
+	// HelveticaBold is the bold sans serif font
+	HelveticaBold Font = "F2"
+)
+
+// Points per unit of length
+const (
+	// Inch is the amount of points in an inch
+	Inch = 72.0
+	// Millimeter is the amount of points in a millimeter
+	Millimeter = Inch / 25.4
+)
+
+// NewDocument creates a new empty document
+func NewDocument() *Document {
+	return &Document{}
+}
+
+// Document is a PDF document made of pages with text and filled rectangles
+type Document struct {
+	// pages are the pages of the document
+	pages []*Page
+}
+
+// AddPage adds a page of the given size in points
+func (d *Document) AddPage(width, height float64) *Page {
+	page := &Page{Width: width, Height: height}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Page is a page of the document, the origin is its bottom left corner
+type Page struct {
+	// Width is the width of the page in points
+	Width float64
+	// Height is the height of the page in points
+	Height float64
+	// content is the content stream of the page
+	content bytes.Buffer
+}
+
+// Rect draws a black filled rectangle with its bottom left corner at x, y
+func (p *Page) Rect(x, y, width, height float64) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f %.3f re f\n", x, y, width, height)
+}
+
+// Text draws the text with its baseline starting at x, y
+func (p *Page) Text(font Font, size, x, y float64, text string) {
+	fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.3f %.3f Td (%s) Tj ET\n", font, size, x, y, escape(text))
+}
+
+// Write writes the document
+func (d *Document) Write(w io.Writer) (err error) {
+	bw := &countingWriter{w: bufio.NewWriter(w)}
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, bw.n)
+		fmt.Fprintf(bw, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// header, catalog, page tree and fonts, then every page followed by its content
+	fmt.Fprint(bw, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", page.Width, page.Height, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()))
+	}
+
+	// cross reference table, every entry is 20 bytes long
+	xref := bw.n
+	fmt.Fprintf(bw, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(bw, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(bw, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	err = bw.w.Flush()
+	return
+}
+
+// countingWriter counts the bytes written, to know the offsets of the objects
+type countingWriter struct {
+	w *bufio.Writer
+	n int
+}
+
+// Write writes to the underlying writer and counts the bytes
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += n
+	return
+}
+
+// escape escapes the text as a PDF string in the WinAnsi encoding, characters out of Latin-1 are replaced by ?
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 128:
+			b.WriteRune(r)
+		case r >= 160 && r < 256:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// helveticaWidths are the widths of the printable ASCII characters of Helvetica in thousandths of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// TextWidth returns the width in points of the text in Helvetica
+// - bold text is approximated as 5% wider, characters out of ASCII count as the average width
+func TextWidth(font Font, size float64, text string) float64 {
+	width := 0
+	for _, r := range text {
+		if r >= 32 && r < 127 {
+			width += helveticaWidths[r-32]
+		} else {
+			width += 556
+		}
+	}
+	points := float64(width) * size / 1000
+	if font == HelveticaBold {
+		points *= 1.05
+	}
+	return points
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+// PriceChange is a struct that contains a price of a product and when it takes effect
+type PriceChange struct {
+	// ID is the unique identifier of the change
+	ID int
+	// ProductID is the identifier of the product
+	ProductID int
+	// Price is the price of the product from EffectiveFrom
+	Price float64
+	// EffectiveFrom is the moment the price takes effect
+	EffectiveFrom time.Time
+	// Applied tells whether the price was already set on the product
+	Applied bool
+	// CreatedAt is the moment the change was recorded
+	CreatedAt time.Time
+}
+
+var (
+	// ErrPriceRepositoryNotFound is the error returned when there is no price recorded for the product at a time
+	ErrPriceRepositoryNotFound = errors.New("repository: price not found")
+	// ErrPriceServiceInvalidField is the error returned when the price change has an invalid field
+	ErrPriceServiceInvalidField = errors.New("service: invalid field")
+)
+
+// PriceRepository is an interface that contains the methods that the price repository should support
+type PriceRepository interface {
+	// FindByProductID returns the price changes of the product ordered by EffectiveFrom, including the scheduled ones
+	FindByProductID(productID int) ([]PriceChange, error)
+	// FindAsOf returns the price change of the product in effect at the given time
+	FindAsOf(productID int, at time.Time) (PriceChange, error)
+	// Schedule records a price change taking effect in the future
+	Schedule(change *PriceChange) error
+	// ApplyDue sets on the products the scheduled prices whose EffectiveFrom is not after now
+	ApplyDue(now time.Time) (applied int, err error)
+	// FindProductIDsChangedSince returns the ids of the products whose price changed since the given time
+	FindProductIDsChangedSince(since time.Time) ([]int, error)
+}
+
+// PriceService is an interface that contains the methods that the price service should support
+type PriceService interface {
+	// FindByProductID returns the price history of the product
+	FindByProductID(productID int) ([]PriceChange, error)
+	// FindAsOf returns the price of the product at the given time
+	FindAsOf(productID int, at time.Time) (PriceChange, error)
+	// Schedule records a price change taking effect in the future
+	Schedule(change *PriceChange) error
+	// ApplyDue sets on the products the scheduled prices already in effect
+	ApplyDue() (applied int, err error)
+	// FindProductIDsChangedSince returns the ids of the products whose price changed since the given time
+	FindProductIDsChangedSince(since time.Time) ([]int, error)
+}
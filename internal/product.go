@@ -1,6 +1,9 @@
 package internal
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // Product is a struct that contains the product's information
 type Product struct {
@@ -18,6 +21,58 @@ type Product struct {
 	Expiration string
 	// Price is the price of the product
 	Price float64
+	// WarehouseQuantity is the stock of the product allocated to warehouses
+	WarehouseQuantity int
+	// CreatedAt is when the product was created
+	CreatedAt time.Time
+	// UpdatedAt is when a field of the product last changed
+	UpdatedAt time.Time
+}
+
+// ProductSearchQuery is a struct that contains the parameters of a product search
+type ProductSearchQuery struct {
+	// Text is the text to search in the name of the products
+	Text string
+	// Page is the page of results, starting at 1
+	Page int
+	// PageSize is the amount of results per page
+	PageSize int
+}
+
+// ProductSearchResult is a struct that contains a product found by a search and its relevance
+type ProductSearchResult struct {
+	// Product is the product found
+	Product Product
+	// Score is the relevance of the product for the search, from 0 to 1
+	Score float64
+}
+
+// ProductCode is a struct that contains a product code recognized and normalized
+type ProductCode struct {
+	// Format is the format the code was given in (ean13, upca, gtin14, ndc or other)
+	Format string
+	// Value is the canonical form of the code, the one stored as CodeValue
+	Value string
+}
+
+// ProductExpirationWeek is a struct that contains the aggregate of the products expiring in a week
+type ProductExpirationWeek struct {
+	// WeekStart is the date of the monday of the week
+	WeekStart string
+	// Count is the amount of products expiring in the week
+	Count int
+	// Quantity is the amount of units expiring in the week
+	Quantity int
+	// StockValue is the value (quantity * price) of the units expiring in the week
+	StockValue float64
+}
+
+// ProductCatalogVersion is a struct that identifies the state of the whole catalog, it changes with every product change
+type ProductCatalogVersion struct {
+	// Count is the amount of products
+	Count int
+	// LastModified is when a product was last created, updated or deleted
+	LastModified time.Time
 }
 
 var (
@@ -25,8 +80,18 @@ var (
 	ErrProductRepositoryNotFound = errors.New("repository: product not found")
 	// ErrProductRepositoryDuplicated is the error returned when the product already exists
 	ErrProductRepositoryDuplicated = errors.New("repository: product already exists")
+	// ErrProductRepositoryReservedStock is the error returned when the quantity is set below the units reserved
+	ErrProductRepositoryReservedStock = errors.New("repository: quantity below the reserved units")
+	// ErrProductRepositoryPriceForbidden is the error returned when an update changes the price and it is fixed
+	ErrProductRepositoryPriceForbidden = errors.New("repository: price change not allowed")
+	// ErrProductRepositoryExpired is the error returned when an expired product is sold and selling them is blocked
+	ErrProductRepositoryExpired = errors.New("repository: product expired")
 	// ErrProductRepositoryInvalidField is the error returned when the product has an invalid field
 	ErrProductServiceInvalidField = errors.New("service: invalid field")
+	// ErrProductServiceTooManyCodes is the error returned when a batch lookup exceeds the allowed amount of codes
+	ErrProductServiceTooManyCodes = errors.New("service: too many codes")
+	// ErrProductServiceExpired is the error returned when an expired product is published
+	ErrProductServiceExpired = errors.New("service: product expired")
 	// ErrInternalServerError is the error returned when an internal server error occurs
 	ErrInternalServerError = errors.New("internal server error")
 )
@@ -37,12 +102,46 @@ type ProductRepository interface {
 	FindByID(id int) (Product, error)
 	// FindAll returns all the products
 	FindAll() ([]Product, error)
+	// FindByIDs returns the products with the given IDs ordered by ID
+	FindByIDs(ids []int) ([]Product, error)
+	// FindByCategoryID returns the products of the category or any of its descendants
+	FindByCategoryID(categoryID int) ([]Product, error)
+	// CountByCategoryID returns the amount of products of the category or any of its descendants
+	CountByCategoryID(categoryID int) (int, error)
+	// FindByCodeValue returns the product with the given code value
+	FindByCodeValue(codeValue string) (Product, error)
+	// FindByCodeValues returns the products with the given code values
+	FindByCodeValues(codeValues []string) ([]Product, error)
+	// Search returns the page of products matching the query ordered by relevance and the total of matches
+	// - the typo tolerant matches are returned only when no product matches the text as a prefix
+	Search(query ProductSearchQuery) (results []ProductSearchResult, total int, err error)
+	// FindByExpirationRange returns the products expiring between from and to (both included, YYYY-MM-DD)
+	FindByExpirationRange(from, to string) ([]Product, error)
+	// FindExpiredBefore returns the products expiring before the given date (YYYY-MM-DD)
+	FindExpiredBefore(date string) ([]Product, error)
+	// ExpirationSummary returns the products aggregated by week of expiration
+	ExpirationSummary() ([]ProductExpirationWeek, error)
+	// CatalogVersion returns the version of the whole catalog, cheaper than reading it
+	CatalogVersion() (ProductCatalogVersion, error)
+	// UnpublishExpiredBefore unpublishes the products expiring before the given date (YYYY-MM-DD)
+	UnpublishExpiredBefore(date string) (affected int, err error)
 	// Delete deletes the product with the given ID
 	Delete(id int) error
 	// Create creates a new product
 	Create(product *Product) error
 	// Update updates the product with the given ID
 	Update(product *Product) error
+	// Patch applies the changes to the product with the given ID, locked until it is updated, and returns it
+	// - an error of apply cancels the update and is returned
+	Patch(id int, apply func(product *Product) error) (Product, error)
+	// Upsert creates the product or updates the one with the same code value
+	// - created is true when the product did not exist before
+	Upsert(product *Product) (created bool, err error)
+	// WithActor returns the repository recording its mutations in the audit log as performed by the actor
+	WithActor(actor Actor) ProductRepository
+	// WithFixedPrice returns the repository whose updates fail with ErrProductRepositoryPriceForbidden when they
+	// change the price of an existing product, compared with the row locked by the update
+	WithFixedPrice() ProductRepository
 }
 
 // ProductService is an interface that contains the methods that the product service should support
@@ -51,10 +150,45 @@ type ProductService interface {
 	FindByID(id int) (Product, error)
 	// FindAll returns all the products
 	FindAll() ([]Product, error)
+	// FindByIDs returns the products with the given IDs ordered by ID
+	FindByIDs(ids []int) ([]Product, error)
+	// FindByCategoryID returns the products of the category or any of its descendants
+	FindByCategoryID(categoryID int) ([]Product, error)
+	// CountByCategoryID returns the amount of products of the category or any of its descendants
+	CountByCategoryID(categoryID int) (int, error)
+	// FindByCodeValue returns the product with the given code value
+	FindByCodeValue(codeValue string) (Product, error)
+	// FindByCodeValues returns the products with the given code values
+	FindByCodeValues(codeValues []string) ([]Product, error)
+	// NormalizeCode validates the code against the accepted formats and returns its canonical form
+	NormalizeCode(code string) (ProductCode, error)
+	// Search returns the page of products matching the query ordered by relevance and the total of matches
+	// - the page and page size of the query are normalized to the values used
+	Search(query *ProductSearchQuery) (results []ProductSearchResult, total int, err error)
+	// FindExpiring returns the products expiring within the given amount of days
+	FindExpiring(days int) ([]Product, error)
+	// FindExpired returns the products already expired
+	FindExpired() ([]Product, error)
+	// ExpirationSummary returns the products aggregated by week of expiration
+	ExpirationSummary() ([]ProductExpirationWeek, error)
+	// CatalogVersion returns the version of the whole catalog, cheaper than reading it
+	CatalogVersion() (ProductCatalogVersion, error)
+	// UnpublishExpired unpublishes the products already expired
+	UnpublishExpired() (affected int, err error)
 	// Delete deletes the product with the given ID
 	Delete(id int) error
 	// Create creates a new product
 	Create(product *Product) error
 	// Update updates the product with the given ID
 	Update(product *Product) error
+	// Patch applies the changes to the current version of the product with the given ID and returns it updated
+	Patch(id int, apply func(product *Product)) (Product, error)
+	// Upsert creates the product or updates the one with the same code value
+	// - created is true when the product did not exist before
+	Upsert(product *Product) (created bool, err error)
+	// WithActor returns the service whose mutations are audited as performed by the actor
+	WithActor(actor Actor) ProductService
+	// WithFixedPrice returns the service whose updates can not change the price of an existing product,
+	// e.g. for the principals without the prices:write permission
+	WithFixedPrice() ProductService
 }
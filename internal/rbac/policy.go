@@ -0,0 +1,59 @@
+package rbac
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"storage/internal"
+	"strings"
+)
+
+// Policy grants permissions to roles
+// - a permission is granted exactly, by the wildcard of its resource (products:*) or by *
+type Policy struct {
+	// Roles are the permissions granted by role
+	Roles map[string][]string `json:"roles"`
+}
+
+// DefaultPolicy is the policy used when no policy file is configured
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Roles: map[string][]string{
+			"admin":  {"*"},
+			"editor": {internal.PermissionProductsRead, internal.PermissionProductsWrite, internal.PermissionPricesWrite, internal.PermissionStockWrite, internal.PermissionCatalogWrite},
+			"clerk":  {internal.PermissionProductsRead, internal.PermissionStockWrite},
+			"viewer": {internal.PermissionProductsRead},
+		},
+	}
+}
+
+// LoadPolicy loads a policy from a JSON file like {"roles": {"viewer": ["products:read"]}}
+func LoadPolicy(path string) (policy *Policy, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	policy = &Policy{}
+	if err = json.Unmarshal(data, policy); err != nil {
+		err = fmt.Errorf("rbac: parsing %s: %w", path, err)
+		return
+	}
+	if len(policy.Roles) == 0 {
+		err = fmt.Errorf("rbac: %s defines no roles", path)
+		return
+	}
+	return
+}
+
+// Allowed reports whether any of the roles of the principal grants the permission
+func (p *Policy) Allowed(principal internal.Principal, permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+	for _, role := range principal.Roles {
+		for _, granted := range p.Roles[role] {
+			if granted == "*" || granted == permission || granted == resource+":*" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"database/sql"
+	"storage/internal"
+	"strings"
+	"time"
+)
+
+// NewAPIKeyMysql creates a new instance of the api key repository
+func NewAPIKeyMysql(db *sql.DB) *APIKeyMysql {
+	return &APIKeyMysql{db}
+}
+
+// APIKeyMysql is the mysql implementation of the api key repository
+type APIKeyMysql struct {
+	db *sql.DB
+}
+
+func (a *APIKeyMysql) FindAll() (keys []internal.APIKey, err error) {
+	// query
+	rows, err := a.db.Query("SELECT k.`id`, k.`name`, k.`prefix`, k.`key_hash`, k.`roles`, k.`created_at`, k.`last_used_at`, k.`revoked_at` FROM `api_keys` AS `k` ORDER BY k.`id`")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the keys
+	for rows.Next() {
+		var key internal.APIKey
+		var roles string
+		err = rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &roles, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt)
+		if err != nil {
+			return
+		}
+		key.Roles = splitRoles(roles)
+		keys = append(keys, key)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (a *APIKeyMysql) FindByPrefix(prefix string) (key internal.APIKey, err error) {
+	// query
+	row := a.db.QueryRow("SELECT k.`id`, k.`name`, k.`prefix`, k.`key_hash`, k.`roles`, k.`created_at`, k.`last_used_at`, k.`revoked_at` FROM `api_keys` AS `k` WHERE k.`prefix` = ?", prefix)
+
+	// serialize the key
+	var roles string
+	err = row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &roles, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt)
+	key.Roles = splitRoles(roles)
+
+	// check errors
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrAPIKeyRepositoryNotFound
+			return
+		}
+		return
+	}
+	return
+}
+
+func (a *APIKeyMysql) Create(key *internal.APIKey) (err error) {
+	// execute the query
+	result, err := a.db.Exec("INSERT INTO `api_keys` (`name`, `prefix`, `key_hash`, `roles`, `created_at`) VALUES (?, ?, ?, ?, ?)", (*key).Name, (*key).Prefix, (*key).Hash, strings.Join((*key).Roles, ","), (*key).CreatedAt)
+	if err != nil {
+		return
+	}
+
+	// get the last inserted id
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	// set the id of the key
+	(*key).ID = int(id)
+	return
+}
+
+func (a *APIKeyMysql) Revoke(id int, at time.Time) (err error) {
+	// execute the query
+	result, err := a.db.Exec("UPDATE `api_keys` SET `revoked_at` = COALESCE(`revoked_at`, ?) WHERE `id` = ?", at, id)
+	if err != nil {
+		return
+	}
+
+	// check the key exists
+	var exists bool
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		// the row is not affected either when it was already revoked
+		err = a.db.QueryRow("SELECT EXISTS(SELECT 1 FROM `api_keys` WHERE `id` = ?)", id).Scan(&exists)
+		if err != nil {
+			return
+		}
+		if !exists {
+			err = internal.ErrAPIKeyRepositoryNotFound
+			return
+		}
+	}
+	return
+}
+
+func (a *APIKeyMysql) Touch(id int, at time.Time) (err error) {
+	// execute the query
+	_, err = a.db.Exec("UPDATE `api_keys` SET `last_used_at` = ? WHERE `id` = ?", at, id)
+	return
+}
+
+// splitRoles splits the comma separated roles of a key
+func splitRoles(roles string) []string {
+	if roles == "" {
+		return nil
+	}
+	return strings.Split(roles, ",")
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"storage/internal"
+	"strings"
+	"time"
+)
+
+// NewAuditMysql creates a new instance of the audit repository
+func NewAuditMysql(db *sql.DB) *AuditMysql {
+	return &AuditMysql{db}
+}
+
+// AuditMysql is the mysql implementation of the audit repository
+// - the entries are written by ProductMysql within the transaction of each mutation
+type AuditMysql struct {
+	db *sql.DB
+}
+
+func (a *AuditMysql) Find(query internal.AuditQuery) (entries []internal.AuditEntry, total int, err error) {
+	// build the filters
+	var conditions []string
+	var args []any
+	if query.ProductID != 0 {
+		conditions = append(conditions, "a.`product_id` = ?")
+		args = append(args, query.ProductID)
+	}
+	if query.Action != "" {
+		conditions = append(conditions, "a.`action` = ?")
+		args = append(args, query.Action)
+	}
+	if query.Subject != "" {
+		conditions = append(conditions, "a.`actor` = ?")
+		args = append(args, query.Subject)
+	}
+	if query.RequestID != "" {
+		conditions = append(conditions, "a.`request_id` = ?")
+		args = append(args, query.RequestID)
+	}
+	if !query.From.IsZero() {
+		conditions = append(conditions, "a.`created_at` >= ?")
+		args = append(args, query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		conditions = append(conditions, "a.`created_at` < ?")
+		args = append(args, query.To.UTC())
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// count the matches
+	row := a.db.QueryRow("SELECT COUNT(*) FROM `product_audit` AS `a`"+where, args...)
+	err = row.Scan(&total)
+	if err != nil {
+		return
+	}
+
+	// query
+	offset := (query.Page - 1) * query.PageSize
+	rows, err := a.db.Query("SELECT a.`id`, a.`product_id`, a.`action`, a.`actor`, a.`auth_method`, a.`request_id`, a.`changes`, a.`created_at` FROM `product_audit` AS `a`"+where+" ORDER BY a.`id` DESC LIMIT ? OFFSET ?", append(args, query.PageSize, offset)...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the entries
+	for rows.Next() {
+		var entry internal.AuditEntry
+		var changes []byte
+		err = rows.Scan(&entry.ID, &entry.ProductID, &entry.Action, &entry.Actor.Subject, &entry.Actor.Method, &entry.Actor.RequestID, &changes, &entry.CreatedAt)
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(changes, &entry.Changes)
+		if err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+// recordAudit records the mutation of a product by the actor, before is nil on creation and after is nil on deletion
+// - an update changing no field is not recorded
+func recordAudit(tx *sql.Tx, actor internal.Actor, before, after *internal.Product) (err error) {
+	var action internal.AuditAction
+	var productID int
+	switch {
+	case before == nil:
+		action, productID = internal.AuditActionCreate, (*after).ID
+	case after == nil:
+		action, productID = internal.AuditActionDelete, (*before).ID
+	default:
+		action, productID = internal.AuditActionUpdate, (*after).ID
+	}
+
+	changes := productChanges(before, after)
+	if len(changes) == 0 {
+		return
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec("INSERT INTO `product_audit` (`product_id`, `action`, `actor`, `auth_method`, `request_id`, `changes`, `created_at`) VALUES (?, ?, ?, ?, ?, ?, ?)", productID, action, actor.Subject, actor.Method, actor.RequestID, data, time.Now().UTC())
+	return
+}
+
+// productChanges returns the fields that differ between the two versions of a product, either may be nil
+func productChanges(before, after *internal.Product) (changes map[string]internal.AuditChange) {
+	fields := func(product *internal.Product) map[string]any {
+		if product == nil {
+			return nil
+		}
+		return map[string]any{
+			"name":         (*product).Name,
+			"quantity":     (*product).Quantity,
+			"code_value":   (*product).CodeValue,
+			"is_published": (*product).IsPublished,
+			"expiration":   expirationDate((*product).Expiration),
+			"price":        (*product).Price,
+		}
+	}
+	previous, current := fields(before), fields(after)
+
+	changes = make(map[string]internal.AuditChange)
+	for _, name := range []string{"name", "quantity", "code_value", "is_published", "expiration", "price"} {
+		var change internal.AuditChange
+		if previous != nil {
+			change.Before = previous[name]
+		}
+		if current != nil {
+			change.After = current[name]
+		}
+		if change.Before != change.After {
+			changes[name] = change
+		}
+	}
+	return
+}
+
+// expirationDate returns the date part of an expiration, which may come from the database with a time part
+func expirationDate(expiration string) string {
+	date, _, _ := strings.Cut(expiration, "T")
+	return date
+}
+
+// lockProduct locks the row of the product matching the condition and returns it, ErrProductRepositoryNotFound when there is none
+func lockProduct(tx *sql.Tx, condition string, arg any) (product internal.Product, err error) {
+	var expiration sql.NullString
+	row := tx.QueryRow("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, DATE_FORMAT(p.`expiration`, '%Y-%m-%d'), p.`price` FROM `products` AS `p` WHERE "+condition+" FOR UPDATE", arg)
+	err = row.Scan(&product.ID, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &expiration, &product.Price)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrProductRepositoryNotFound
+		}
+		return
+	}
+	product.Expiration = expiration.String
+	return
+}
@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"storage/internal"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// NewCategoryMysql creates a new instance of the category repository
+func NewCategoryMysql(db *sql.DB) *CategoryMysql {
+	return &CategoryMysql{db}
+}
+
+// CategoryMysql is the mysql implementation of the category repository
+type CategoryMysql struct {
+	db *sql.DB
+}
+
+func (c *CategoryMysql) FindAll() (categories []internal.Category, err error) {
+	// query
+	rows, err := c.db.Query("SELECT c.`id`, c.`name`, c.`parent_id` FROM `categories` AS `c` ORDER BY c.`name`, c.`id`")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the categories
+	return scanCategories(rows)
+}
+
+func (c *CategoryMysql) FindByID(id int) (category internal.Category, err error) {
+	// query
+	row := c.db.QueryRow("SELECT c.`id`, c.`name`, c.`parent_id` FROM `categories` AS `c` WHERE c.`id` = ?", id)
+
+	// serialize the category
+	var parentID sql.NullInt64
+	err = row.Scan(&category.ID, &category.Name, &parentID)
+
+	// check errors
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrCategoryRepositoryNotFound
+			return
+		}
+		return
+	}
+	category.ParentID = int(parentID.Int64)
+	return
+}
+
+func (c *CategoryMysql) Create(category *internal.Category) (err error) {
+	// execute the query
+	result, err := c.db.Exec("INSERT INTO `categories` (`name`, `parent_id`) VALUES (?, ?)", (*category).Name, nullableID((*category).ParentID))
+	if err != nil {
+		err = categoryMysqlError(err)
+		if errors.Is(err, internal.ErrCategoryRepositoryNotFound) {
+			err = internal.ErrCategoryRepositoryInvalidParent
+		}
+		return
+	}
+
+	// get the last inserted id
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	// set the id of the category
+	(*category).ID = int(id)
+	return
+}
+
+func (c *CategoryMysql) Update(category *internal.Category) (err error) {
+	// start the transaction
+	tx, err := c.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the category
+	row := tx.QueryRow("SELECT `id` FROM `categories` WHERE `id` = ? FOR UPDATE", (*category).ID)
+	err = row.Scan(new(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrCategoryRepositoryNotFound
+		}
+		return
+	}
+
+	// the parent must not create a cycle
+	err = checkCategoryAncestors(tx, (*category).ID, (*category).ParentID)
+	if err != nil {
+		return
+	}
+
+	// execute the query
+	_, err = tx.Exec("UPDATE `categories` SET `name` = ?, `parent_id` = ? WHERE `id` = ?", (*category).Name, nullableID((*category).ParentID), (*category).ID)
+	if err != nil {
+		err = categoryMysqlError(err)
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+// checkCategoryAncestors returns ErrCategoryRepositoryInvalidParent when the parent does not exist or the category
+// is the parent or one of its ancestors
+// - the ancestors are locked while walked, so a concurrent change of parent can not close a cycle through them
+func checkCategoryAncestors(tx *sql.Tx, id, parentID int) (err error) {
+	for ancestorID := parentID; ancestorID != 0; {
+		if ancestorID == id {
+			err = internal.ErrCategoryRepositoryInvalidParent
+			return
+		}
+
+		var next sql.NullInt64
+		row := tx.QueryRow("SELECT `parent_id` FROM `categories` WHERE `id` = ? FOR UPDATE", ancestorID)
+		err = row.Scan(&next)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				err = internal.ErrCategoryRepositoryInvalidParent
+			}
+			return
+		}
+		ancestorID = int(next.Int64)
+	}
+	return
+}
+
+func (c *CategoryMysql) Delete(id int) (err error) {
+	// execute the query, the product assignments are deleted in cascade
+	result, err := c.db.Exec("DELETE FROM `categories` WHERE `id` = ?", id)
+	if err != nil {
+		err = categoryMysqlError(err)
+		return
+	}
+
+	// check the category existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = internal.ErrCategoryRepositoryNotFound
+		return
+	}
+	return
+}
+
+func (c *CategoryMysql) CountProducts() (counts []internal.CategoryCount, err error) {
+	// query
+	// - the recursive cte pairs every category with itself and all its descendants
+	rows, err := c.db.Query("WITH RECURSIVE `tree` AS (SELECT c.`id` AS `root`, c.`id` FROM `categories` AS `c` UNION ALL SELECT t.`root`, c.`id` FROM `categories` AS `c` INNER JOIN `tree` AS `t` ON c.`parent_id` = t.`id`) SELECT t.`root`, COUNT(DISTINCT CASE WHEN t.`id` = t.`root` THEN pc.`product_id` END), COUNT(DISTINCT pc.`product_id`) FROM `tree` AS `t` INNER JOIN `product_categories` AS `pc` ON pc.`category_id` = t.`id` GROUP BY t.`root`")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the counts
+	for rows.Next() {
+		var count internal.CategoryCount
+		err = rows.Scan(&count.CategoryID, &count.Products, &count.TotalProducts)
+		if err != nil {
+			return
+		}
+		counts = append(counts, count)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (c *CategoryMysql) FindByProductID(productID int) (categories []internal.Category, err error) {
+	// query
+	rows, err := c.db.Query("SELECT c.`id`, c.`name`, c.`parent_id` FROM `categories` AS `c` INNER JOIN `product_categories` AS `pc` ON pc.`category_id` = c.`id` WHERE pc.`product_id` = ? ORDER BY c.`name`, c.`id`", productID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the categories
+	return scanCategories(rows)
+}
+
+func (c *CategoryMysql) ContainsProduct(categoryIDs []int, productID int) (found bool, err error) {
+	if len(categoryIDs) == 0 {
+		return
+	}
+
+	// query
+	// - the recursive cte collects the categories and their descendants
+	args := make([]any, 0, len(categoryIDs)+1)
+	for _, id := range categoryIDs {
+		args = append(args, id)
+	}
+	args = append(args, productID)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(categoryIDs)), ", ")
+	row := c.db.QueryRow("WITH RECURSIVE `tree` AS (SELECT c.`id` FROM `categories` AS `c` WHERE c.`id` IN ("+placeholders+") UNION SELECT c.`id` FROM `categories` AS `c` INNER JOIN `tree` AS `t` ON c.`parent_id` = t.`id`) SELECT EXISTS (SELECT 1 FROM `product_categories` AS `pc` INNER JOIN `tree` AS `t` ON pc.`category_id` = t.`id` WHERE pc.`product_id` = ?)", args...)
+	err = row.Scan(&found)
+	return
+}
+
+func (c *CategoryMysql) SetProductCategories(productID int, categoryIDs []int) (err error) {
+	// start the transaction
+	tx, err := c.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the product
+	_, err = lockProductQuantity(tx, productID)
+	if err != nil {
+		return
+	}
+
+	// replace the assignments
+	_, err = tx.Exec("DELETE FROM `product_categories` WHERE `product_id` = ?", productID)
+	if err != nil {
+		return
+	}
+	for _, categoryID := range categoryIDs {
+		_, err = tx.Exec("INSERT INTO `product_categories` (`product_id`, `category_id`) VALUES (?, ?)", productID, categoryID)
+		if err != nil {
+			err = categoryMysqlError(err)
+			return
+		}
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+// nullableID stores the zero id as NULL
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// categoryMysqlError maps the mysql errors of the category statements
+func categoryMysqlError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1062:
+			return internal.ErrCategoryRepositoryDuplicated
+		case 1451:
+			// a row referencing the category as parent
+			return internal.ErrCategoryRepositoryHasChildren
+		case 1452:
+			// the parent or the assigned category does not exist
+			return internal.ErrCategoryRepositoryNotFound
+		}
+	}
+	return err
+}
+
+// scanCategories serializes the rows of a categories query
+func scanCategories(rows *sql.Rows) (categories []internal.Category, err error) {
+	for rows.Next() {
+		var category internal.Category
+		var parentID sql.NullInt64
+		err = rows.Scan(&category.ID, &category.Name, &parentID)
+		if err != nil {
+			return
+		}
+		category.ParentID = int(parentID.Int64)
+		categories = append(categories, category)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
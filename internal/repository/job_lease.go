@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// NewJobLeaseMysql creates a new instance of the job lease repository
+func NewJobLeaseMysql(db *sql.DB) *JobLeaseMysql {
+	return &JobLeaseMysql{db}
+}
+
+// JobLeaseMysql is the mysql implementation of the job lease repository
+type JobLeaseMysql struct {
+	db *sql.DB
+}
+
+func (j *JobLeaseMysql) Acquire(job, owner string, ttl time.Duration) (acquired bool, err error) {
+	// take the lease when it does not exist, is expired or is already ours
+	// - owner is assigned first, so expires_at is only extended when the owner is now ours
+	_, err = j.db.Exec("INSERT INTO `job_leases` (`name`, `owner`, `expires_at`) VALUES (?, ?, NOW(6) + INTERVAL ? MICROSECOND) ON DUPLICATE KEY UPDATE `owner` = IF(`expires_at` < NOW(6) OR `owner` = VALUES(`owner`), VALUES(`owner`), `owner`), `expires_at` = IF(`owner` = VALUES(`owner`), VALUES(`expires_at`), `expires_at`)", job, owner, ttl.Microseconds())
+	if err != nil {
+		return
+	}
+
+	// check who holds the lease
+	var holder string
+	row := j.db.QueryRow("SELECT `owner` FROM `job_leases` WHERE `name` = ?", job)
+	err = row.Scan(&holder)
+	if err != nil {
+		return
+	}
+	acquired = holder == owner
+	return
+}
+
+func (j *JobLeaseMysql) Release(job, owner string, hold time.Duration) (err error) {
+	// keep the lease for hold more if it is ours, expiring it now when there is nothing to hold
+	// - the expiration is computed by the database, like in Acquire, so it does not depend on the time zones
+	_, err = j.db.Exec("UPDATE `job_leases` SET `expires_at` = NOW(6) + INTERVAL ? MICROSECOND WHERE `name` = ? AND `owner` = ?", max(hold, 0).Microseconds(), job, owner)
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"storage/internal"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// NewLotMysql creates a new instance of the lot repository
+func NewLotMysql(db *sql.DB) *LotMysql {
+	return &LotMysql{db: db}
+}
+
+// LotMysql is the mysql implementation of the lot repository
+// - stock not covered by lots (received before lot tracking or added by PATCH) is consumed after the lots
+type LotMysql struct {
+	db *sql.DB
+	// invalidator is notified of the products changed, nil if none
+	invalidator ProductInvalidator
+}
+
+// SetInvalidator sets the invalidator notified of the products changed
+func (l *LotMysql) SetInvalidator(invalidator ProductInvalidator) {
+	l.invalidator = invalidator
+}
+
+func (l *LotMysql) FindByProductID(productID int, includeDepleted bool) (lots []internal.Lot, err error) {
+	// query
+	query := "SELECT l.`id`, l.`product_id`, l.`lot_number`, l.`quantity`, l.`received_quantity`, DATE_FORMAT(l.`expiration`, '%Y-%m-%d'), DATE_FORMAT(l.`received_date`, '%Y-%m-%d') FROM `lots` AS `l` WHERE l.`product_id` = ?"
+	if !includeDepleted {
+		query += " AND l.`quantity` > 0"
+	}
+	query += " ORDER BY l.`expiration`, l.`id`"
+	rows, err := l.db.Query(query, productID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the lots
+	for rows.Next() {
+		var lot internal.Lot
+		err = rows.Scan(&lot.ID, &lot.ProductID, &lot.LotNumber, &lot.Quantity, &lot.ReceivedQuantity, &lot.Expiration, &lot.ReceivedDate)
+		if err != nil {
+			return
+		}
+		lots = append(lots, lot)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (l *LotMysql) Receive(receipt *internal.LotReceipt) (err error) {
+	// start the transaction
+	tx, err := l.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the product
+	quantity, err := lockProductQuantity(tx, (*receipt).Lot.ProductID)
+	if err != nil {
+		return
+	}
+
+	// execute the query
+	lot := &(*receipt).Lot
+	result, err := tx.Exec("INSERT INTO `lots` (`product_id`, `lot_number`, `quantity`, `received_quantity`, `expiration`, `received_date`) VALUES (?, ?, ?, ?, ?, ?)", lot.ProductID, lot.LotNumber, lot.Quantity, lot.ReceivedQuantity, lot.Expiration, lot.ReceivedDate)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			err = internal.ErrLotRepositoryDuplicated
+		}
+		return
+	}
+
+	// get the last inserted id
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	lot.ID = int(id)
+
+	// add the units to the product and record the receipt in the stock ledger
+	_, err = tx.Exec("UPDATE `products` SET `quantity` = ? WHERE `id` = ?", quantity+lot.Quantity, lot.ProductID)
+	if err != nil {
+		return
+	}
+	movement := internal.StockMovement{
+		ProductID:     lot.ProductID,
+		Type:          internal.StockMovementReceipt,
+		Quantity:      lot.Quantity,
+		QuantityAfter: quantity + lot.Quantity,
+		Reason:        fmt.Sprintf("lot %s received", lot.LotNumber),
+		Actor:         (*receipt).Actor,
+		CreatedAt:     (*receipt).CreatedAt,
+	}
+	err = insertStockMovement(tx, &movement)
+	if err != nil {
+		return
+	}
+	err = refreshProductExpiration(tx, lot.ProductID)
+	if err != nil {
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	invalidateProducts(l.invalidator, (*receipt).Lot.ProductID)
+	return
+}
+
+func (l *LotMysql) StockReport(before string) (stock []internal.LotStock, err error) {
+	// query
+	query := "SELECT l.`id`, l.`product_id`, l.`lot_number`, l.`quantity`, l.`received_quantity`, DATE_FORMAT(l.`expiration`, '%Y-%m-%d'), DATE_FORMAT(l.`received_date`, '%Y-%m-%d'), p.`name`, l.`quantity` * p.`price` FROM `lots` AS `l` INNER JOIN `products` AS `p` ON p.`id` = l.`product_id` WHERE l.`quantity` > 0"
+	args := []any{}
+	if before != "" {
+		query += " AND l.`expiration` < ?"
+		args = append(args, before)
+	}
+	query += " ORDER BY l.`expiration`, l.`id`"
+	rows, err := l.db.Query(query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the lots
+	for rows.Next() {
+		var item internal.LotStock
+		err = rows.Scan(&item.Lot.ID, &item.Lot.ProductID, &item.Lot.LotNumber, &item.Lot.Quantity, &item.Lot.ReceivedQuantity, &item.Lot.Expiration, &item.Lot.ReceivedDate, &item.ProductName, &item.StockValue)
+		if err != nil {
+			return
+		}
+		stock = append(stock, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+// allocateLots takes the units removed by the movement from the lots of the product, first expired first out,
+// records the allocations and sets them on the movement
+// - the product row must be locked by the transaction
+func allocateLots(tx *sql.Tx, movement *internal.StockMovement) (err error) {
+	// lock the live lots in order of expiration
+	rows, err := tx.Query("SELECT `id`, `lot_number`, `quantity` FROM `lots` WHERE `product_id` = ? AND `quantity` > 0 ORDER BY `expiration`, `id` FOR UPDATE", (*movement).ProductID)
+	if err != nil {
+		return
+	}
+	var lots []internal.LotAllocation
+	for rows.Next() {
+		var lot internal.LotAllocation
+		err = rows.Scan(&lot.LotID, &lot.LotNumber, &lot.Quantity)
+		if err != nil {
+			rows.Close()
+			return
+		}
+		lots = append(lots, lot)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+
+	// take the units from the earliest lots
+	remaining := -(*movement).Quantity
+	for _, lot := range lots {
+		if remaining == 0 {
+			break
+		}
+		lot.Quantity = min(lot.Quantity, remaining)
+		remaining -= lot.Quantity
+
+		_, err = tx.Exec("UPDATE `lots` SET `quantity` = `quantity` - ? WHERE `id` = ?", lot.Quantity, lot.LotID)
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec("INSERT INTO `stock_movement_lots` (`stock_movement_id`, `lot_id`, `quantity`) VALUES (?, ?, ?)", (*movement).ID, lot.LotID, lot.Quantity)
+		if err != nil {
+			return
+		}
+		(*movement).Lots = append((*movement).Lots, lot)
+	}
+
+	// the product expiration follows the earliest live lot
+	if len((*movement).Lots) > 0 {
+		err = refreshProductExpiration(tx, (*movement).ProductID)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// refreshProductExpiration sets the expiration of the product to the earliest live lot's date
+// - it is left untouched when the product has no live lots
+func refreshProductExpiration(tx *sql.Tx, productID int) (err error) {
+	_, err = tx.Exec("UPDATE `products` AS `p` INNER JOIN (SELECT MIN(l.`expiration`) AS `expiration` FROM `lots` AS `l` WHERE l.`product_id` = ? AND l.`quantity` > 0) AS `e` ON e.`expiration` IS NOT NULL SET p.`expiration` = e.`expiration` WHERE p.`id` = ?", productID, productID)
+	return
+}
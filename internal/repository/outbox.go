@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"storage/internal"
+	"time"
+)
+
+// NewOutboxMysql creates a new instance of the outbox repository
+func NewOutboxMysql(db *sql.DB) *OutboxMysql {
+	return &OutboxMysql{db}
+}
+
+// OutboxMysql is the mysql implementation of the outbox repository
+// - the events are written by the other repositories within the transaction of each change
+type OutboxMysql struct {
+	db *sql.DB
+}
+
+func (o *OutboxMysql) FindPending(now time.Time, limit int) (events []internal.Event, err error) {
+	// query
+	rows, err := o.db.Query("SELECT e.`id`, e.`type`, e.`product_id`, e.`payload`, e.`occurred_at`, e.`attempts`, e.`last_error`, e.`next_attempt_at` FROM `outbox_events` AS `e` WHERE e.`dispatched_at` IS NULL AND e.`next_attempt_at` <= ? AND NOT EXISTS (SELECT 1 FROM `outbox_events` AS `o` WHERE o.`product_id` = e.`product_id` AND o.`id` < e.`id` AND o.`dispatched_at` IS NULL) ORDER BY e.`id` LIMIT ?", now.UTC(), limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the events
+	for rows.Next() {
+		var event internal.Event
+		var payload []byte
+		err = rows.Scan(&event.ID, &event.Type, &event.ProductID, &payload, &event.OccurredAt, &event.Attempts, &event.LastError, &event.NextAttemptAt)
+		if err != nil {
+			return
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (o *OutboxMysql) MarkDispatched(id int, at time.Time) (err error) {
+	// execute the query
+	_, err = o.db.Exec("UPDATE `outbox_events` SET `dispatched_at` = ? WHERE `id` = ?", at.UTC(), id)
+	return
+}
+
+func (o *OutboxMysql) MarkFailed(id int, lastError string, nextAttemptAt time.Time) (err error) {
+	// execute the query
+	_, err = o.db.Exec("UPDATE `outbox_events` SET `attempts` = `attempts` + 1, `last_error` = LEFT(?, 1024), `next_attempt_at` = ? WHERE `id` = ?", lastError, nextAttemptAt.UTC(), id)
+	return
+}
+
+func (o *OutboxMysql) FindAfter(id int, limit int) (events []internal.Event, err error) {
+	// query
+	rows, err := o.db.Query("SELECT e.`id`, e.`type`, e.`product_id`, e.`payload`, e.`occurred_at`, e.`attempts`, e.`last_error`, e.`next_attempt_at`, e.`dispatched_at` FROM `outbox_events` AS `e` WHERE e.`id` > ? ORDER BY e.`id` LIMIT ?", id, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the events
+	for rows.Next() {
+		var event internal.Event
+		var payload []byte
+		err = rows.Scan(&event.ID, &event.Type, &event.ProductID, &payload, &event.OccurredAt, &event.Attempts, &event.LastError, &event.NextAttemptAt, &event.DispatchedAt)
+		if err != nil {
+			return
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (o *OutboxMysql) LastID() (id int, err error) {
+	// query
+	row := o.db.QueryRow("SELECT COALESCE(MAX(`id`), 0) FROM `outbox_events`")
+	err = row.Scan(&id)
+	return
+}
+
+func (o *OutboxMysql) FirstID() (id int, err error) {
+	// query
+	row := o.db.QueryRow("SELECT COALESCE(MIN(`id`), 0) FROM `outbox_events`")
+	err = row.Scan(&id)
+	return
+}
+
+func (o *OutboxMysql) DeleteDispatchedBefore(before time.Time, limit int) (deleted int, err error) {
+	// the events are deleted up to the oldest one not dispatched, so no gap is left before the ones retained
+	var bound int
+	row := o.db.QueryRow("SELECT COALESCE((SELECT MIN(`id`) FROM `outbox_events` WHERE `dispatched_at` IS NULL), (SELECT MAX(`id`) + 1 FROM `outbox_events`), 0)")
+	err = row.Scan(&bound)
+	if err != nil {
+		return
+	}
+
+	// execute the query
+	result, err := o.db.Exec("DELETE FROM `outbox_events` WHERE `id` < ? AND `dispatched_at` IS NOT NULL AND `occurred_at` < ? ORDER BY `id` LIMIT ?", bound, before.UTC(), limit)
+	if err != nil {
+		return
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	deleted = int(rowsAffected)
+	return
+}
+
+// enqueueEvent stores an event in the outbox, it is relayed once the transaction commits
+func enqueueEvent(tx *sql.Tx, eventType internal.EventType, productID int, payload any) (err error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.Exec("INSERT INTO `outbox_events` (`type`, `product_id`, `payload`, `occurred_at`, `next_attempt_at`) VALUES (?, ?, ?, ?, ?)", eventType, productID, data, now, now)
+	return
+}
+
+// enqueueProductEvent emits the event of the change of a product, before is nil on creation and after is nil on deletion
+// - an update changing no field emits nothing
+func enqueueProductEvent(tx *sql.Tx, actor internal.Actor, before, after *internal.Product) (err error) {
+	payload := internal.ProductEventPayload{
+		Actor:     actor.Subject,
+		RequestID: actor.RequestID,
+	}
+	var eventType internal.EventType
+	switch {
+	case before == nil:
+		eventType, payload.Product = internal.EventProductCreated, productSnapshot(after)
+	case after == nil:
+		eventType, payload.Product = internal.EventProductDeleted, productSnapshot(before)
+	default:
+		eventType, payload.Product = internal.EventProductUpdated, productSnapshot(after)
+		payload.Changes = productChanges(before, after)
+		if len(payload.Changes) == 0 {
+			return
+		}
+	}
+
+	err = enqueueEvent(tx, eventType, payload.Product.ID, payload)
+	return
+}
+
+// enqueueStockEvent emits the event of the change of stock recorded by a movement
+func enqueueStockEvent(tx *sql.Tx, movement *internal.StockMovement) (err error) {
+	err = enqueueEvent(tx, internal.EventStockChanged, (*movement).ProductID, internal.StockChangedPayload{
+		ProductID:    (*movement).ProductID,
+		MovementID:   (*movement).ID,
+		MovementType: (*movement).Type,
+		Delta:        (*movement).Quantity,
+		Quantity:     (*movement).QuantityAfter,
+		Reason:       (*movement).Reason,
+	})
+	return
+}
+
+// productSnapshot returns the fields of the product carried by the events
+func productSnapshot(product *internal.Product) internal.ProductSnapshot {
+	return internal.ProductSnapshot{
+		ID:          (*product).ID,
+		Name:        (*product).Name,
+		Quantity:    (*product).Quantity,
+		CodeValue:   (*product).CodeValue,
+		IsPublished: (*product).IsPublished,
+		Expiration:  expirationDate((*product).Expiration),
+		Price:       (*product).Price,
+	}
+}
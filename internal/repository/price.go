@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"storage/internal"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// NewPriceMysql creates a new instance of the price repository
+func NewPriceMysql(db *sql.DB) *PriceMysql {
+	return &PriceMysql{db: db}
+}
+
+// PriceMysql is the mysql implementation of the price repository
+type PriceMysql struct {
+	db *sql.DB
+	// invalidator is notified of the products changed, nil if none
+	invalidator ProductInvalidator
+}
+
+// SetInvalidator sets the invalidator notified of the products changed
+func (p *PriceMysql) SetInvalidator(invalidator ProductInvalidator) {
+	p.invalidator = invalidator
+}
+
+func (p *PriceMysql) FindByProductID(productID int) (changes []internal.PriceChange, err error) {
+	// query
+	rows, err := p.db.Query("SELECT h.`id`, h.`product_id`, h.`price`, h.`effective_from`, h.`applied`, h.`created_at` FROM `price_history` AS `h` WHERE h.`product_id` = ? ORDER BY h.`effective_from`, h.`id`", productID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the changes
+	for rows.Next() {
+		var change internal.PriceChange
+		err = rows.Scan(&change.ID, &change.ProductID, &change.Price, &change.EffectiveFrom, &change.Applied, &change.CreatedAt)
+		if err != nil {
+			return
+		}
+		changes = append(changes, change)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (p *PriceMysql) FindAsOf(productID int, at time.Time) (change internal.PriceChange, err error) {
+	// query
+	row := p.db.QueryRow("SELECT h.`id`, h.`product_id`, h.`price`, h.`effective_from`, h.`applied`, h.`created_at` FROM `price_history` AS `h` WHERE h.`product_id` = ? AND h.`effective_from` <= ? ORDER BY h.`effective_from` DESC, h.`id` DESC LIMIT 1", productID, at)
+
+	// serialize the change
+	err = row.Scan(&change.ID, &change.ProductID, &change.Price, &change.EffectiveFrom, &change.Applied, &change.CreatedAt)
+
+	// check errors
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrPriceRepositoryNotFound
+			return
+		}
+		return
+	}
+	return
+}
+
+func (p *PriceMysql) Schedule(change *internal.PriceChange) (err error) {
+	// execute the query
+	result, err := p.db.Exec("INSERT INTO `price_history` (`product_id`, `price`, `effective_from`, `applied`, `created_at`) VALUES (?, ?, ?, FALSE, ?)", (*change).ProductID, (*change).Price, (*change).EffectiveFrom, (*change).CreatedAt)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 {
+			err = internal.ErrProductRepositoryNotFound
+		}
+		return
+	}
+
+	// get the last inserted id
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	// set the id of the change
+	(*change).ID = int(id)
+	return
+}
+
+func (p *PriceMysql) ApplyDue(now time.Time) (applied int, err error) {
+	// start the transaction
+	tx, err := p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the due changes, the latest one of every product wins
+	rows, err := tx.Query("SELECT `id`, `product_id`, `price` FROM `price_history` WHERE `applied` = FALSE AND `effective_from` <= ? ORDER BY `product_id`, `effective_from`, `id` FOR UPDATE", now)
+	if err != nil {
+		return
+	}
+	var ids []int
+	prices := make(map[int]float64)
+	for rows.Next() {
+		var id, productID int
+		var price float64
+		err = rows.Scan(&id, &productID, &price)
+		if err != nil {
+			rows.Close()
+			return
+		}
+		ids = append(ids, id)
+		prices[productID] = price
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+
+	// set the prices on the products, recording the changes and emitting their events
+	for productID, price := range prices {
+		var before internal.Product
+		before, err = lockProduct(tx, "p.`id` = ?", productID)
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec("UPDATE `products` SET `price` = ? WHERE `id` = ?", price, productID)
+		if err != nil {
+			return
+		}
+		after := before
+		after.Price = price
+		err = recordProductChange(tx, internal.SystemActor, &before, &after)
+		if err != nil {
+			return
+		}
+	}
+
+	// mark the changes as applied
+	for _, id := range ids {
+		_, err = tx.Exec("UPDATE `price_history` SET `applied` = TRUE WHERE `id` = ?", id)
+		if err != nil {
+			return
+		}
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	applied = len(ids)
+	for productID := range prices {
+		invalidateProducts(p.invalidator, productID)
+	}
+	return
+}
+
+func (p *PriceMysql) FindProductIDsChangedSince(since time.Time) (ids []int, err error) {
+	// query
+	rows, err := p.db.Query("SELECT DISTINCT h.`product_id` FROM `price_history` AS `h` WHERE h.`applied` = TRUE AND h.`effective_from` >= ? ORDER BY h.`product_id`", since)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the ids
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
@@ -4,24 +4,49 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"storage/internal"
+	"storage/internal/search"
+	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 )
 
 // NewSellerMysql creates a new instance of the seller repository
 func NewProductMysql(db *sql.DB) *ProductMysql {
-	return &ProductMysql{db}
+	return &ProductMysql{
+		db:    db,
+		actor: internal.SystemActor,
+	}
 }
 
 type ProductMysql struct {
 	db *sql.DB
+	// actor is who the mutations are recorded as performed by in the audit log
+	actor internal.Actor
+	// fixedPrice rejects the updates changing the price of an existing product
+	fixedPrice bool
+}
+
+// WithActor returns a copy of the repository recording its mutations as performed by the actor
+func (p *ProductMysql) WithActor(actor internal.Actor) internal.ProductRepository {
+	copy := *p
+	copy.actor = actor
+	return &copy
+}
+
+// WithFixedPrice returns a copy of the repository whose updates can not change the price of an existing product
+func (p *ProductMysql) WithFixedPrice() internal.ProductRepository {
+	copy := *p
+	copy.fixedPrice = true
+	return &copy
 }
 
 func (p *ProductMysql) FindAll() (products []internal.Product, err error) {
 	// query
 
-	rows, err := p.db.Query("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price` FROM `products` AS  `p`")
+	rows, err := p.db.Query("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0), p.`created_at`, p.`updated_at` FROM `products` AS  `p`")
 	if err != nil {
 		return
 	}
@@ -30,7 +55,7 @@ func (p *ProductMysql) FindAll() (products []internal.Product, err error) {
 	// serialize the products
 	for rows.Next() {
 		var product internal.Product
-		err = rows.Scan(&product.ID, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &product.Expiration, &product.Price)
+		err = rows.Scan(&product.ID, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &product.Expiration, &product.Price, &product.WarehouseQuantity, &product.CreatedAt, &product.UpdatedAt)
 		if err != nil {
 			return
 		}
@@ -46,10 +71,10 @@ func (p *ProductMysql) FindAll() (products []internal.Product, err error) {
 func (p *ProductMysql) FindByID(id int) (product internal.Product, err error) {
 	// query
 
-	row := p.db.QueryRow("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price` FROM `products` AS  `p` WHERE p.`id` = ?", id)
+	row := p.db.QueryRow("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0), p.`created_at`, p.`updated_at` FROM `products` AS  `p` WHERE p.`id` = ?", id)
 
 	// serialize the product
-	err = row.Scan(&product.ID, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &product.Expiration, &product.Price)
+	err = row.Scan(&product.ID, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &product.Expiration, &product.Price, &product.WarehouseQuantity, &product.CreatedAt, &product.UpdatedAt)
 
 	// check errors
 	if err != nil {
@@ -62,18 +87,367 @@ func (p *ProductMysql) FindByID(id int) (product internal.Product, err error) {
 	return
 }
 
+func (p *ProductMysql) FindByCategoryID(categoryID int) (products []internal.Product, err error) {
+	// query
+	// - the recursive cte collects the category and its descendants
+	rows, err := p.db.Query("WITH RECURSIVE `tree` AS (SELECT c.`id` FROM `categories` AS `c` WHERE c.`id` = ? UNION ALL SELECT c.`id` FROM `categories` AS `c` INNER JOIN `tree` AS `t` ON c.`parent_id` = t.`id`) SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0), p.`created_at`, p.`updated_at` FROM `products` AS  `p` WHERE p.`id` IN (SELECT pc.`product_id` FROM `product_categories` AS `pc` INNER JOIN `tree` AS `t` ON pc.`category_id` = t.`id`) ORDER BY p.`id`", categoryID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the products
+	return scanProducts(rows)
+}
+
+func (p *ProductMysql) CountByCategoryID(categoryID int) (count int, err error) {
+	// query
+	// - the recursive cte collects the category and its descendants
+	row := p.db.QueryRow("WITH RECURSIVE `tree` AS (SELECT c.`id` FROM `categories` AS `c` WHERE c.`id` = ? UNION ALL SELECT c.`id` FROM `categories` AS `c` INNER JOIN `tree` AS `t` ON c.`parent_id` = t.`id`) SELECT COUNT(DISTINCT pc.`product_id`) FROM `product_categories` AS `pc` INNER JOIN `tree` AS `t` ON pc.`category_id` = t.`id`", categoryID)
+
+	// serialize the count
+	err = row.Scan(&count)
+	return
+}
+
+func (p *ProductMysql) FindByCodeValue(codeValue string) (product internal.Product, err error) {
+	// query
+	row := p.db.QueryRow("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0), p.`created_at`, p.`updated_at` FROM `products` AS  `p` WHERE p.`code_value` = ?", codeValue)
+
+	// serialize the product
+	err = row.Scan(&product.ID, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &product.Expiration, &product.Price, &product.WarehouseQuantity, &product.CreatedAt, &product.UpdatedAt)
+
+	// check errors
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrProductRepositoryNotFound
+			return
+		}
+		return
+	}
+	return
+}
+
+func (p *ProductMysql) FindByIDs(ids []int) (products []internal.Product, err error) {
+	// nothing to look up
+	if len(ids) == 0 {
+		return
+	}
+
+	// build the placeholders of the IN clause
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	// query
+	rows, err := p.db.Query("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0), p.`created_at`, p.`updated_at` FROM `products` AS  `p` WHERE p.`id` IN ("+placeholders+") ORDER BY p.`id`", args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the products
+	return scanProducts(rows)
+}
+
+func (p *ProductMysql) FindByCodeValues(codeValues []string) (products []internal.Product, err error) {
+	// nothing to look up
+	if len(codeValues) == 0 {
+		return
+	}
+
+	// build the placeholders of the IN clause
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(codeValues)), ", ")
+	args := make([]any, len(codeValues))
+	for i, codeValue := range codeValues {
+		args[i] = codeValue
+	}
+
+	// query
+	rows, err := p.db.Query("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0), p.`created_at`, p.`updated_at` FROM `products` AS  `p` WHERE p.`code_value` IN ("+placeholders+")", args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the products
+	return scanProducts(rows)
+}
+
+func (p *ProductMysql) Search(query internal.ProductSearchQuery) (results []internal.ProductSearchResult, total int, err error) {
+	// build the boolean mode expression, every token matches as a prefix
+	tokens := search.Tokenize(query.Text)
+	if len(tokens) == 0 {
+		return
+	}
+	expression := strings.Join(tokens, "* ") + "*"
+	offset := (query.Page - 1) * query.PageSize
+
+	// count the matches
+	row := p.db.QueryRow("SELECT COUNT(*) FROM `products` AS `p` WHERE MATCH(p.`name`) AGAINST (? IN BOOLEAN MODE)", expression)
+	err = row.Scan(&total)
+	if err != nil {
+		return
+	}
+
+	// the full-text matches, if any, are the results
+	if total > 0 {
+		results, err = p.searchFullText(expression, query.PageSize, offset)
+		return
+	}
+
+	// no full-text match, the results are the typo tolerant matches
+	fuzzy, err := p.searchFallback(query)
+	if err != nil {
+		return
+	}
+
+	// paginate
+	total = len(fuzzy)
+	start := min(offset, total)
+	end := min(start+query.PageSize, total)
+	results = fuzzy[start:end]
+	return
+}
+
+// searchFullText returns a page of the products matching the boolean mode expression ordered by relevance
+// - the relevance is relative to the best match, which scores 1
+func (p *ProductMysql) searchFullText(expression string, limit, offset int) (results []internal.ProductSearchResult, err error) {
+	if limit == 0 {
+		return
+	}
+
+	// query
+	rows, err := p.db.Query("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0), p.`created_at`, p.`updated_at`, m.`relevance` / MAX(m.`relevance`) OVER () AS `score` FROM (SELECT `id`, MATCH(`name`) AGAINST (? IN BOOLEAN MODE) AS `relevance` FROM `products` WHERE MATCH(`name`) AGAINST (? IN BOOLEAN MODE)) AS `m` INNER JOIN `products` AS `p` ON p.`id` = m.`id` ORDER BY `score` DESC, p.`id` LIMIT ? OFFSET ?", expression, expression, limit, offset)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the results
+	for rows.Next() {
+		var result internal.ProductSearchResult
+		err = rows.Scan(&result.Product.ID, &result.Product.Name, &result.Product.Quantity, &result.Product.CodeValue, &result.Product.IsPublished, &result.Product.Expiration, &result.Product.Price, &result.Product.WarehouseQuantity, &result.Product.CreatedAt, &result.Product.UpdatedAt, &result.Score)
+		if err != nil {
+			return
+		}
+		results = append(results, result)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+// searchFallback ranks all the products in memory, tolerating typos in the query
+func (p *ProductMysql) searchFallback(query internal.ProductSearchQuery) (results []internal.ProductSearchResult, err error) {
+	products, err := p.FindAll()
+	if err != nil {
+		return
+	}
+
+	// rank the names of the products
+	names := make([]string, len(products))
+	for i, product := range products {
+		names[i] = product.Name
+	}
+	for _, match := range search.Rank(query.Text, names) {
+		results = append(results, internal.ProductSearchResult{
+			Product: products[match.Index],
+			Score:   match.Score,
+		})
+	}
+	return
+}
+
+func (p *ProductMysql) FindByExpirationRange(from, to string) (products []internal.Product, err error) {
+	// query
+	rows, err := p.db.Query("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0), p.`created_at`, p.`updated_at` FROM `products` AS  `p` WHERE p.`expiration` BETWEEN ? AND ? ORDER BY p.`expiration`, p.`id`", from, to)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the products
+	return scanProducts(rows)
+}
+
+func (p *ProductMysql) FindExpiredBefore(date string) (products []internal.Product, err error) {
+	// query
+	rows, err := p.db.Query("SELECT p.`id`, p.`name`, p.`quantity`, p.`code_value`, p.`is_published`, p.`expiration`, p.`price`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0), p.`created_at`, p.`updated_at` FROM `products` AS  `p` WHERE p.`expiration` < ? ORDER BY p.`expiration`, p.`id`", date)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the products
+	return scanProducts(rows)
+}
+
+func (p *ProductMysql) ExpirationSummary() (weeks []internal.ProductExpirationWeek, err error) {
+	// query
+	rows, err := p.db.Query("SELECT DATE_FORMAT(DATE_SUB(p.`expiration`, INTERVAL WEEKDAY(p.`expiration`) DAY), '%Y-%m-%d') AS `week_start`, COUNT(*), COALESCE(SUM(p.`quantity`), 0), COALESCE(SUM(p.`quantity` * p.`price`), 0) FROM `products` AS `p` WHERE p.`expiration` IS NOT NULL GROUP BY `week_start` ORDER BY `week_start`")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the weeks
+	for rows.Next() {
+		var week internal.ProductExpirationWeek
+		err = rows.Scan(&week.WeekStart, &week.Count, &week.Quantity, &week.StockValue)
+		if err != nil {
+			return
+		}
+		weeks = append(weeks, week)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (p *ProductMysql) CatalogVersion() (version internal.ProductCatalogVersion, err error) {
+	// query
+	// - the deletions leave no row behind, so they are taken from the audit log
+	row := p.db.QueryRow("SELECT COUNT(*), CAST(GREATEST(COALESCE(MAX(p.`updated_at`), TIMESTAMP('1970-01-01')), COALESCE((SELECT MAX(a.`created_at`) FROM `product_audit` AS `a` WHERE a.`action` = ?), TIMESTAMP('1970-01-01'))) AS DATETIME(6)) FROM `products` AS `p`", internal.AuditActionDelete)
+
+	// serialize the version
+	err = row.Scan(&version.Count, &version.LastModified)
+	return
+}
+
+func (p *ProductMysql) UnpublishExpiredBefore(date string) (affected int, err error) {
+	// start the transaction
+	tx, err := p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the expired products still published
+	rows, err := tx.Query("SELECT `id` FROM `products` WHERE `is_published` = '1' AND `expiration` < ? FOR UPDATE", date)
+	if err != nil {
+		return
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+
+	// unpublish them, recording the changes and emitting their events
+	for _, id := range ids {
+		var before internal.Product
+		before, err = lockProduct(tx, "p.`id` = ?", id)
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec("UPDATE `products` SET `is_published` = '0' WHERE `id` = ?", id)
+		if err != nil {
+			return
+		}
+		after := before
+		after.IsPublished = "0"
+		err = recordProductChange(tx, p.actor, &before, &after)
+		if err != nil {
+			return
+		}
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	affected = len(ids)
+	return
+}
+
+// scanProducts serializes the rows of a products query
+func scanProducts(rows *sql.Rows) (products []internal.Product, err error) {
+	for rows.Next() {
+		var product internal.Product
+		err = rows.Scan(&product.ID, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &product.Expiration, &product.Price, &product.WarehouseQuantity, &product.CreatedAt, &product.UpdatedAt)
+		if err != nil {
+			return
+		}
+		products = append(products, product)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
 func (p *ProductMysql) Delete(id int) (err error) {
+	// start the transaction
+	tx, err := p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the product row and get the current version
+	before, err := lockProduct(tx, "p.`id` = ?", id)
+	if err != nil {
+		return
+	}
+
 	// query
-	_, err = p.db.Exec("DELETE FROM `products` WHERE `id` = ?", id)
+	_, err = tx.Exec("DELETE FROM `products` WHERE `id` = ?", id)
 	if err != nil {
 		return
 	}
+
+	// record the deletion and emit its event
+	err = recordProductChange(tx, p.actor, &before, nil)
+	if err != nil {
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
 	return
 }
 
 func (p *ProductMysql) Create(product *internal.Product) (err error) {
+	// start the transaction
+	tx, err := p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
 	// execute the query
-	result, err := p.db.Exec("INSERT INTO `products` (`name`, `quantity`, `code_value`, `is_published`, `expiration`, `price`) VALUES (?, ?, ?, ?, ?, ?)", (*product).Name, (*product).Quantity, (*product).CodeValue, (*product).IsPublished, (*product).Expiration, (*product).Price)
+	result, err := tx.Exec("INSERT INTO `products` (`name`, `quantity`, `code_value`, `is_published`, `expiration`, `price`) VALUES (?, ?, ?, ?, ?, ?)", (*product).Name, (*product).Quantity, (*product).CodeValue, (*product).IsPublished, (*product).Expiration, (*product).Price)
 
 	if err != nil {
 		var mySqlErr *mysql.MySQLError
@@ -98,17 +472,114 @@ func (p *ProductMysql) Create(product *internal.Product) (err error) {
 	// set the id of the warehouse
 	(*product).ID = int(id)
 
-	return
+	// record the initial stock and price
+	err = recordQuantityChange(tx, p.actor.Subject, product, 0, internal.StockMovementReceipt, "product created")
+	if err != nil {
+		return
+	}
+	err = recordPriceChange(tx, product, 0)
+	if err != nil {
+		return
+	}
+	err = scanProductTimestamps(tx, product)
+	if err != nil {
+		return
+	}
 
+	// record the creation and emit its event
+	err = recordProductChange(tx, p.actor, nil, product)
+	if err != nil {
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
 	return
 }
 
 func (p *ProductMysql) Update(product *internal.Product) (err error) {
-	// execute the query
+	// start the transaction
+	tx, err := p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-	//_, err = p.db.Exec("UPDATE `products` AS `p` SET p.`name` = ?, p.`quantity` = ?, p.`code_value` = ?, p.`is_published` = ?, p.`expiration` = ?, p.`price` = ? WHERE p.`id` = ?", (*product).Name, (*product).Quantity, (*product).CodeValue, (*product).IsPublished, (*product).Expiration, (*product).Price, (*product).ID)
+	// lock the product row and get the current version
+	before, err := lockProduct(tx, "p.`id` = ?", (*product).ID)
+	if err != nil {
+		return
+	}
+
+	// update it
+	err = p.update(tx, &before, product)
+	if err != nil {
+		return
+	}
 
-	_, err = p.db.Exec("UPDATE `products` AS `p` SET p.`name` = ?, p.`quantity` = ?, p.`code_value` = ?, p.`is_published` = ?, p.`expiration` = ?, p.`price` = ? WHERE p.`id` = ?", (*product).Name, (*product).Quantity, (*product).CodeValue, (*product).IsPublished, (*product).Expiration, (*product).Price, (*product).ID)
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+func (p *ProductMysql) Patch(id int, apply func(product *internal.Product) error) (product internal.Product, err error) {
+	// start the transaction
+	tx, err := p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the product row and get the current version
+	before, err := lockProduct(tx, "p.`id` = ?", id)
+	if err != nil {
+		return
+	}
+
+	// apply the changes to the current version and update it
+	product = before
+	err = apply(&product)
+	if err != nil {
+		return
+	}
+	product.ID = id
+	err = p.update(tx, &before, &product)
+	if err != nil {
+		return
+	}
+	row := tx.QueryRow("SELECT COALESCE(SUM(`quantity`), 0) FROM `warehouse_stocks` WHERE `product_id` = ?", id)
+	err = row.Scan(&product.WarehouseQuantity)
+	if err != nil {
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+// update writes the product over its current version before, whose row must be locked
+func (p *ProductMysql) update(tx *sql.Tx, before, product *internal.Product) (err error) {
+	// the quantity can not be lowered below the units reserved, nor the price changed when it is fixed
+	err = checkReservedStock(tx, before, (*product).Quantity)
+	if err != nil {
+		return
+	}
+	err = p.checkPriceChange(before, product)
+	if err != nil {
+		return
+	}
+
+	// execute the query
+	_, err = tx.Exec("UPDATE `products` AS `p` SET p.`name` = ?, p.`quantity` = ?, p.`code_value` = ?, p.`is_published` = ?, p.`expiration` = ?, p.`price` = ? WHERE p.`id` = ?", (*product).Name, (*product).Quantity, (*product).CodeValue, (*product).IsPublished, (*product).Expiration, (*product).Price, (*product).ID)
 
 	if err != nil {
 		var mysqlErr *mysql.MySQLError
@@ -123,5 +594,220 @@ func (p *ProductMysql) Update(product *internal.Product) (err error) {
 		}
 		return
 	}
+
+	// record the change of stock as an adjustment and the change of price
+	err = recordQuantityChange(tx, p.actor.Subject, product, (*before).Quantity, internal.StockMovementAdjustment, "product updated")
+	if err != nil {
+		return
+	}
+	err = recordPriceChange(tx, product, (*before).Price)
+	if err != nil {
+		return
+	}
+	err = syncProductExpiration(tx, product, (*before).Expiration)
+	if err != nil {
+		return
+	}
+	err = scanProductTimestamps(tx, product)
+	if err != nil {
+		return
+	}
+
+	// record the update and emit its event
+	err = recordProductChange(tx, p.actor, before, product)
+	return
+}
+
+func (p *ProductMysql) Upsert(product *internal.Product) (created bool, err error) {
+	// start the transaction
+	tx, err := p.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the existing product, if any, and get its current version
+	before, err := lockProduct(tx, "p.`code_value` = ?", (*product).CodeValue)
+	if err != nil && !errors.Is(err, internal.ErrProductRepositoryNotFound) {
+		return
+	}
+
+	// the quantity of an existing product can not be lowered below the units reserved, nor its price changed
+	// when it is fixed
+	if err == nil {
+		err = checkReservedStock(tx, &before, (*product).Quantity)
+		if err != nil {
+			return
+		}
+		err = p.checkPriceChange(&before, product)
+		if err != nil {
+			return
+		}
+	}
+
+	// execute the query
+	// - id = LAST_INSERT_ID(id) makes LastInsertId return the id of the existing row on update
+	result, err := tx.Exec("INSERT INTO `products` (`name`, `quantity`, `code_value`, `is_published`, `expiration`, `price`) VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE `id` = LAST_INSERT_ID(`id`), `name` = VALUES(`name`), `quantity` = VALUES(`quantity`), `is_published` = VALUES(`is_published`), `expiration` = VALUES(`expiration`), `price` = VALUES(`price`)", (*product).Name, (*product).Quantity, (*product).CodeValue, (*product).IsPublished, (*product).Expiration, (*product).Price)
+
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) {
+			err = internal.ErrInternalServerError
+			return
+		}
+		return
+	}
+
+	// check if the row was inserted (1) or updated (2, or 0 when nothing changed)
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	created = rowsAffected == 1
+
+	// get the id of the inserted or updated row
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	// set the id of the product
+	(*product).ID = int(id)
+
+	// record the change of stock and price
+	if created {
+		err = recordQuantityChange(tx, p.actor.Subject, product, 0, internal.StockMovementReceipt, "product created")
+	} else {
+		err = recordQuantityChange(tx, p.actor.Subject, product, before.Quantity, internal.StockMovementAdjustment, "product updated")
+	}
+	if err != nil {
+		return
+	}
+	err = recordPriceChange(tx, product, before.Price)
+	if err != nil {
+		return
+	}
+	err = syncProductExpiration(tx, product, before.Expiration)
+	if err != nil {
+		return
+	}
+	err = scanProductTimestamps(tx, product)
+	if err != nil {
+		return
+	}
+
+	// record the creation or update and emit its event
+	if created {
+		err = recordProductChange(tx, p.actor, nil, product)
+	} else {
+		err = recordProductChange(tx, p.actor, &before, product)
+	}
+	if err != nil {
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+// recordProductChange records the change of a product in the audit log and emits its event in the outbox
+// - before is nil on creation and after is nil on deletion
+func recordProductChange(tx *sql.Tx, actor internal.Actor, before, after *internal.Product) (err error) {
+	err = recordAudit(tx, actor, before, after)
+	if err != nil {
+		return
+	}
+	err = enqueueProductEvent(tx, actor, before, after)
+	return
+}
+
+// checkReservedStock returns ErrProductRepositoryReservedStock when the quantity lowers the locked product below its units reserved
+func checkReservedStock(tx *sql.Tx, before *internal.Product, quantity int) (err error) {
+	if quantity >= (*before).Quantity {
+		return
+	}
+	reserved, err := reservedQuantity(tx, (*before).ID, time.Now().UTC())
+	if err != nil {
+		return
+	}
+	if quantity < reserved {
+		err = internal.ErrProductRepositoryReservedStock
+	}
+	return
+}
+
+// recordQuantityChange records a stock movement of the actor when the quantity of the product differs from the previous one
+func recordQuantityChange(tx *sql.Tx, actor string, product *internal.Product, previous int, movementType internal.StockMovementType, reason string) (err error) {
+	if (*product).Quantity == previous {
+		return
+	}
+
+	movement := internal.StockMovement{
+		ProductID:     (*product).ID,
+		Type:          movementType,
+		Quantity:      (*product).Quantity - previous,
+		QuantityAfter: (*product).Quantity,
+		Reason:        reason,
+		Actor:         actor,
+		CreatedAt:     time.Now().UTC(),
+	}
+	err = insertStockMovement(tx, &movement)
+	return
+}
+
+// checkPriceChange returns ErrProductRepositoryPriceForbidden when the price is fixed and the update changes it
+// - before is the product locked by the update
+func (p *ProductMysql) checkPriceChange(before, after *internal.Product) (err error) {
+	if p.fixedPrice && math.Round((*before).Price*100) != math.Round((*after).Price*100) {
+		err = internal.ErrProductRepositoryPriceForbidden
+	}
+	return
+}
+
+// recordPriceChange records the price of the product in the history when it differs from the previous one
+// - the prices are compared in cents, as the column stores them
+func recordPriceChange(tx *sql.Tx, product *internal.Product, previous float64) (err error) {
+	if math.Round((*product).Price*100) == math.Round(previous*100) {
+		return
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.Exec("INSERT INTO `price_history` (`product_id`, `price`, `effective_from`, `applied`, `created_at`) VALUES (?, ?, ?, TRUE, ?)", (*product).ID, (*product).Price, now, now)
+	return
+}
+
+// syncProductExpiration keeps the expiration of the product as the earliest live lot's date and sets it on the product
+// - an expiration set explicitly by the write, i.e. different from the previous one, is kept until the lots change
+func syncProductExpiration(tx *sql.Tx, product *internal.Product, previous string) (err error) {
+	if (*product).Expiration != previous {
+		return
+	}
+
+	err = refreshProductExpiration(tx, (*product).ID)
+	if err != nil {
+		return
+	}
+
+	var expiration sql.NullString
+	row := tx.QueryRow("SELECT DATE_FORMAT(`expiration`, '%Y-%m-%d') FROM `products` WHERE `id` = ?", (*product).ID)
+	err = row.Scan(&expiration)
+	if err != nil {
+		return
+	}
+	if expiration.Valid {
+		(*product).Expiration = expiration.String
+	}
+	return
+}
+
+// scanProductTimestamps sets on the product when it was created and last updated, as set by the database
+func scanProductTimestamps(tx *sql.Tx, product *internal.Product) (err error) {
+	row := tx.QueryRow("SELECT `created_at`, `updated_at` FROM `products` WHERE `id` = ?", (*product).ID)
+	err = row.Scan(&(*product).CreatedAt, &(*product).UpdatedAt)
 	return
 }
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"encoding/json"
+	"storage/internal"
+	"storage/internal/cache"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProductInvalidator is notified of the products changed by the repositories writing them, e.g. the product cache
+type ProductInvalidator interface {
+	// Invalidate reports the product with the given id changed
+	Invalidate(id int)
+}
+
+// invalidateProducts reports the products changed to the invalidator, if any
+func invalidateProducts(invalidator ProductInvalidator, ids ...int) {
+	if invalidator == nil {
+		return
+	}
+	for _, id := range ids {
+		invalidator.Invalidate(id)
+	}
+}
+
+// NewProductCache creates a read-through cache of the products by id in front of the repository
+// - up to capacity products are kept in process, each one for ttl
+func NewProductCache(rp internal.ProductRepository, capacity int, ttl time.Duration) *ProductCache {
+	return &ProductCache{
+		ProductRepository: rp,
+		shared: &productCacheShared{
+			local: cache.NewLRU[int, internal.Product](capacity, ttl),
+			ttl:   ttl,
+		},
+	}
+}
+
+// ProductCache is a decorator of the product repository caching FindByID
+// - its mutations invalidate the products changed, the changes made by other repositories or instances
+// must be reported with Invalidate, e.g. following the outbox events
+type ProductCache struct {
+	internal.ProductRepository
+	// shared is the state shared by the copies with an actor
+	shared *productCacheShared
+}
+
+// productCacheShared is the state of the product cache
+type productCacheShared struct {
+	// local is the in-process cache
+	local *cache.LRU[int, internal.Product]
+	// remote is the cache shared by the instances, nil if none
+	remote cache.Remote
+	// ttl is how long a product is cached
+	ttl time.Duration
+	// group collapses the concurrent loads of the same product
+	group cache.Group[int, internal.Product]
+	// generation is increased by every invalidation, a load started before one is not cached
+	generation atomic.Uint64
+	// mu makes the check of the generation and the caching of a load atomic with the invalidations
+	mu sync.Mutex
+
+	hits, remoteHits, misses, loads, invalidations, remoteErrors atomic.Uint64
+}
+
+// SetRemote sets the cache shared by the instances, read after the in-process one
+func (c *ProductCache) SetRemote(remote cache.Remote) {
+	c.shared.remote = remote
+}
+
+// WithActor returns a copy of the cache sharing its entries, whose mutations are performed by the actor
+func (c *ProductCache) WithActor(actor internal.Actor) internal.ProductRepository {
+	return &ProductCache{
+		ProductRepository: c.ProductRepository.WithActor(actor),
+		shared:            c.shared,
+	}
+}
+
+// WithFixedPrice returns a copy of the cache sharing its entries, whose updates can not change the prices
+func (c *ProductCache) WithFixedPrice() internal.ProductRepository {
+	return &ProductCache{
+		ProductRepository: c.ProductRepository.WithFixedPrice(),
+		shared:            c.shared,
+	}
+}
+
+// Stats returns the counters of the cache
+func (c *ProductCache) Stats() cache.Stats {
+	return cache.Stats{
+		Hits:          c.shared.hits.Load(),
+		RemoteHits:    c.shared.remoteHits.Load(),
+		Misses:        c.shared.misses.Load(),
+		Loads:         c.shared.loads.Load(),
+		Invalidations: c.shared.invalidations.Load(),
+		Evictions:     c.shared.local.Evictions(),
+		RemoteErrors:  c.shared.remoteErrors.Load(),
+		Size:          c.shared.local.Len(),
+	}
+}
+
+func (c *ProductCache) FindByID(id int) (product internal.Product, err error) {
+	// get the product from the in-process cache
+	product, found := c.shared.local.Get(id)
+	if found {
+		c.shared.hits.Add(1)
+		return
+	}
+	c.shared.misses.Add(1)
+
+	// load it once for all the concurrent misses
+	product, err, _ = c.shared.group.Do(id, func() (product internal.Product, err error) {
+		generation := c.shared.generation.Load()
+		product, found := c.getRemote(id)
+		if found {
+			c.shared.remoteHits.Add(1)
+		} else {
+			c.shared.loads.Add(1)
+			product, err = c.ProductRepository.FindByID(id)
+			if err != nil {
+				return
+			}
+		}
+
+		// cache it unless it was invalidated meanwhile
+		c.shared.mu.Lock()
+		defer c.shared.mu.Unlock()
+		if c.shared.generation.Load() != generation {
+			return
+		}
+		c.shared.local.Set(id, product)
+		if !found {
+			c.setRemote(product)
+		}
+		return
+	})
+	return
+}
+
+func (c *ProductCache) UnpublishExpiredBefore(date string) (affected int, err error) {
+	affected, err = c.ProductRepository.UnpublishExpiredBefore(date)
+	if err != nil || affected == 0 {
+		return
+	}
+
+	// invalidate the products expired, the ones unpublished are among them
+	products, err := c.ProductRepository.FindExpiredBefore(date)
+	if err != nil {
+		c.Purge()
+		err = nil
+		return
+	}
+	for _, product := range products {
+		c.Invalidate(product.ID)
+	}
+	return
+}
+
+func (c *ProductCache) Delete(id int) (err error) {
+	err = c.ProductRepository.Delete(id)
+	if err != nil {
+		return
+	}
+	c.Invalidate(id)
+	return
+}
+
+func (c *ProductCache) Create(product *internal.Product) (err error) {
+	err = c.ProductRepository.Create(product)
+	if err != nil {
+		return
+	}
+	c.Invalidate((*product).ID)
+	return
+}
+
+func (c *ProductCache) Update(product *internal.Product) (err error) {
+	err = c.ProductRepository.Update(product)
+	if err != nil {
+		return
+	}
+	c.Invalidate((*product).ID)
+	return
+}
+
+func (c *ProductCache) Patch(id int, apply func(product *internal.Product) error) (product internal.Product, err error) {
+	product, err = c.ProductRepository.Patch(id, apply)
+	if err != nil {
+		return
+	}
+	c.Invalidate(id)
+	return
+}
+
+func (c *ProductCache) Upsert(product *internal.Product) (created bool, err error) {
+	created, err = c.ProductRepository.Upsert(product)
+	if err != nil {
+		return
+	}
+	c.Invalidate((*product).ID)
+	return
+}
+
+// Invalidate removes the product from the caches, the next read loads it again
+func (c *ProductCache) Invalidate(id int) {
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+	c.shared.generation.Add(1)
+	c.shared.invalidations.Add(1)
+	c.shared.group.Forget(id)
+	c.shared.local.Delete(id)
+	if c.shared.remote != nil {
+		if err := c.shared.remote.Delete(productCacheKey(id)); err != nil {
+			c.shared.remoteErrors.Add(1)
+		}
+	}
+}
+
+// Purge removes all the products from the in-process cache, the remote one expires them by ttl
+func (c *ProductCache) Purge() {
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+	c.shared.generation.Add(1)
+	c.shared.local.Purge()
+}
+
+// getRemote returns the product from the remote cache, a failure is a miss
+func (c *ProductCache) getRemote(id int) (product internal.Product, found bool) {
+	if c.shared.remote == nil {
+		return
+	}
+	data, found, err := c.shared.remote.Get(productCacheKey(id))
+	if err == nil && found {
+		err = json.Unmarshal(data, &product)
+	}
+	if err != nil {
+		c.shared.remoteErrors.Add(1)
+		found = false
+	}
+	return
+}
+
+// setRemote stores the product in the remote cache, a failure only skips it
+func (c *ProductCache) setRemote(product internal.Product) {
+	if c.shared.remote == nil {
+		return
+	}
+	data, err := json.Marshal(product)
+	if err == nil {
+		err = c.shared.remote.Set(productCacheKey(product.ID), data, c.shared.ttl)
+	}
+	if err != nil {
+		c.shared.remoteErrors.Add(1)
+	}
+}
+
+// productCacheKey is the key of the product in the remote cache
+func productCacheKey(id int) string {
+	return "products:" + strconv.Itoa(id)
+}
@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"storage/internal"
+	"storage/internal/cache"
+	"sync"
+	"testing"
+	"time"
+)
+
+// productRepositoryFake is an in-memory product repository implementing what the cache uses
+type productRepositoryFake struct {
+	internal.ProductRepository
+
+	mu       sync.Mutex
+	products map[int]internal.Product
+	loads    int
+	// loading, when set, is signaled by the loads and holds them until release is closed
+	loading chan struct{}
+	release chan struct{}
+}
+
+func newProductRepositoryFake(products ...internal.Product) *productRepositoryFake {
+	f := &productRepositoryFake{products: make(map[int]internal.Product)}
+	for _, product := range products {
+		f.products[product.ID] = product
+	}
+	return f
+}
+
+func (f *productRepositoryFake) FindByID(id int) (product internal.Product, err error) {
+	f.mu.Lock()
+	f.loads++
+	product, ok := f.products[id]
+	loading, release := f.loading, f.release
+	f.mu.Unlock()
+
+	if loading != nil {
+		loading <- struct{}{}
+		<-release
+	}
+	if !ok {
+		err = internal.ErrProductRepositoryNotFound
+	}
+	return
+}
+
+func (f *productRepositoryFake) Update(product *internal.Product) (err error) {
+	f.set(*product)
+	return
+}
+
+func (f *productRepositoryFake) Patch(id int, apply func(product *internal.Product) error) (product internal.Product, err error) {
+	f.mu.Lock()
+	product = f.products[id]
+	f.mu.Unlock()
+	err = apply(&product)
+	if err != nil {
+		return
+	}
+	f.set(product)
+	return
+}
+
+func (f *productRepositoryFake) Delete(id int) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.products, id)
+	return
+}
+
+func (f *productRepositoryFake) set(product internal.Product) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.products[product.ID] = product
+}
+
+func (f *productRepositoryFake) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.loads
+}
+
+// remoteFake is a remote cache whose stores, when loading is set, signal it and wait until release is closed
+type remoteFake struct {
+	*cache.Memory
+
+	loading chan struct{}
+	release chan struct{}
+}
+
+func (r *remoteFake) Set(key string, value []byte, ttl time.Duration) error {
+	if r.loading != nil {
+		r.loading <- struct{}{}
+		<-r.release
+	}
+	return r.Memory.Set(key, value, ttl)
+}
+
+func TestProductCache_FindByID(t *testing.T) {
+	rp := newProductRepositoryFake(internal.Product{ID: 1, Name: "apple"})
+	c := NewProductCache(rp, 10, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		product, err := c.FindByID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if product.Name != "apple" {
+			t.Fatalf("expected apple, got %q", product.Name)
+		}
+	}
+	if rp.count() != 1 {
+		t.Fatalf("expected 1 load, got %d", rp.count())
+	}
+	if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 1 || stats.Loads != 1 || stats.Size != 1 {
+		t.Fatalf("expected 2 hits, 1 miss and 1 load, got %+v", stats)
+	}
+
+	// the missing products are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := c.FindByID(2); err != internal.ErrProductRepositoryNotFound {
+			t.Fatalf("expected %v, got %v", internal.ErrProductRepositoryNotFound, err)
+		}
+	}
+	if rp.count() != 3 {
+		t.Fatalf("expected 3 loads, got %d", rp.count())
+	}
+}
+
+func TestProductCache_FindByID_CollapsesConcurrentMisses(t *testing.T) {
+	rp := newProductRepositoryFake(internal.Product{ID: 1, Name: "apple"})
+	rp.loading, rp.release = make(chan struct{}, 1), make(chan struct{})
+	c := NewProductCache(rp, 10, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if product, err := c.FindByID(1); err != nil || product.Name != "apple" {
+				t.Errorf("expected apple, got %q and %v", product.Name, err)
+			}
+		}()
+	}
+
+	// hold the load until the other misses join it
+	<-rp.loading
+	time.Sleep(50 * time.Millisecond)
+	close(rp.release)
+	wg.Wait()
+
+	if rp.count() != 1 {
+		t.Fatalf("expected 1 load, got %d", rp.count())
+	}
+}
+
+func TestProductCache_InvalidatesOnWrite(t *testing.T) {
+	rp := newProductRepositoryFake(internal.Product{ID: 1, Name: "apple", Quantity: 5})
+	c := NewProductCache(rp, 10, time.Minute)
+
+	find := func() internal.Product {
+		t.Helper()
+		product, err := c.FindByID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return product
+	}
+	find()
+
+	if err := c.Update(&internal.Product{ID: 1, Name: "pear", Quantity: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if product := find(); product.Name != "pear" {
+		t.Fatalf("expected pear after the update, got %q", product.Name)
+	}
+
+	_, err := c.Patch(1, func(product *internal.Product) error {
+		product.Quantity = 3
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product := find(); product.Quantity != 3 {
+		t.Fatalf("expected 3 units after the patch, got %d", product.Quantity)
+	}
+
+	// the writes of other repositories are reported to the invalidator
+	rp.set(internal.Product{ID: 1, Name: "pear", Quantity: 10})
+	var invalidator ProductInvalidator = c
+	invalidateProducts(invalidator, 1)
+	if product := find(); product.Quantity != 10 {
+		t.Fatalf("expected 10 units after the invalidation, got %d", product.Quantity)
+	}
+	invalidateProducts(nil, 1)
+
+	if err := c.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.FindByID(1); err != internal.ErrProductRepositoryNotFound {
+		t.Fatalf("expected %v after the delete, got %v", internal.ErrProductRepositoryNotFound, err)
+	}
+}
+
+func TestProductCache_InvalidationDuringLoad(t *testing.T) {
+	rp := newProductRepositoryFake(internal.Product{ID: 1, Name: "apple"})
+	rp.loading, rp.release = make(chan struct{}, 1), make(chan struct{})
+	c := NewProductCache(rp, 10, time.Minute)
+
+	// a read loads the product before a write changes it
+	done := make(chan internal.Product)
+	go func() {
+		product, _ := c.FindByID(1)
+		done <- product
+	}()
+	<-rp.loading
+	if err := c.Update(&internal.Product{ID: 1, Name: "pear"}); err != nil {
+		t.Fatal(err)
+	}
+	close(rp.release)
+	<-done
+
+	// the stale load is not cached
+	rp.mu.Lock()
+	rp.loading = nil
+	rp.mu.Unlock()
+	product, err := c.FindByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Name != "pear" {
+		t.Fatalf("expected pear, got %q", product.Name)
+	}
+}
+
+func TestProductCache_InvalidationWhileCaching(t *testing.T) {
+	rp := newProductRepositoryFake(internal.Product{ID: 1, Name: "apple"})
+	remote := &remoteFake{Memory: cache.NewMemory(), loading: make(chan struct{}, 1), release: make(chan struct{})}
+	c := NewProductCache(rp, 10, time.Minute)
+	c.SetRemote(remote)
+
+	// a read is caching the product it loaded when a write changes it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.FindByID(1)
+	}()
+	<-remote.loading
+	invalidated := make(chan struct{})
+	go func() {
+		defer close(invalidated)
+		if err := c.Update(&internal.Product{ID: 1, Name: "pear"}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// give the invalidation time to run before the read caches the product
+	time.Sleep(50 * time.Millisecond)
+	close(remote.release)
+	<-done
+	<-invalidated
+
+	// the stale load is not cached in any of the caches
+	remote.loading = nil
+	product, err := c.FindByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Name != "pear" {
+		t.Fatalf("expected pear, got %q", product.Name)
+	}
+}
+
+func TestProductCache_Remote(t *testing.T) {
+	rp := newProductRepositoryFake(internal.Product{ID: 1, Name: "apple"})
+	remote := cache.NewMemory()
+
+	// an instance loads the product into the shared cache, another one reads it from there
+	first := NewProductCache(rp, 10, time.Minute)
+	first.SetRemote(remote)
+	second := NewProductCache(rp, 10, time.Minute)
+	second.SetRemote(remote)
+
+	if _, err := first.FindByID(1); err != nil {
+		t.Fatal(err)
+	}
+	product, err := second.FindByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Name != "apple" || rp.count() != 1 {
+		t.Fatalf("expected apple loaded once, got %q after %d loads", product.Name, rp.count())
+	}
+	if stats := second.Stats(); stats.RemoteHits != 1 || stats.Loads != 0 {
+		t.Fatalf("expected a remote hit, got %+v", stats)
+	}
+
+	// a write removes it from the shared cache too
+	if err := first.Update(&internal.Product{ID: 1, Name: "pear"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := remote.Get(productCacheKey(1)); found {
+		t.Fatal("expected the product removed from the remote cache")
+	}
+}
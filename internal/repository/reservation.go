@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"storage/internal"
+	"time"
+)
+
+// NewReservationMysql creates a new instance of the reservation repository
+func NewReservationMysql(db *sql.DB) *ReservationMysql {
+	return &ReservationMysql{db: db}
+}
+
+// ReservationMysql is the mysql implementation of the reservation repository
+// - the product row is locked with SELECT ... FOR UPDATE, so parallel requests for the last units are serialized
+type ReservationMysql struct {
+	db *sql.DB
+	// invalidator is notified of the products changed, nil if none
+	invalidator ProductInvalidator
+	// blockExpired rejects selling the products whose expiration date has passed
+	blockExpired bool
+}
+
+// SetBlockExpired enables or disables rejecting the sales of expired products
+func (rs *ReservationMysql) SetBlockExpired(block bool) {
+	rs.blockExpired = block
+}
+
+// SetInvalidator sets the invalidator notified of the products changed
+func (rs *ReservationMysql) SetInvalidator(invalidator ProductInvalidator) {
+	rs.invalidator = invalidator
+}
+
+func (rs *ReservationMysql) Create(reservation *internal.Reservation, now time.Time) (err error) {
+	// start the transaction
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the product and compute the available units
+	availability, err := availabilityForUpdate(tx, (*reservation).ProductID, now)
+	if err != nil {
+		return
+	}
+	if availability.Available < (*reservation).Quantity {
+		err = internal.ErrReservationRepositoryInsufficientStock
+		return
+	}
+
+	// execute the query
+	result, err := tx.Exec("INSERT INTO `reservations` (`product_id`, `quantity`, `status`, `created_at`, `expires_at`) VALUES (?, ?, ?, ?, ?)", (*reservation).ProductID, (*reservation).Quantity, (*reservation).Status, (*reservation).CreatedAt, (*reservation).ExpiresAt)
+	if err != nil {
+		return
+	}
+
+	// get the last inserted id
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	(*reservation).ID = int(id)
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+func (rs *ReservationMysql) FindByID(id int) (reservation internal.Reservation, err error) {
+	// query
+	row := rs.db.QueryRow("SELECT r.`id`, r.`product_id`, r.`quantity`, r.`status`, r.`created_at`, r.`expires_at` FROM `reservations` AS `r` WHERE r.`id` = ?", id)
+
+	// serialize the reservation
+	err = row.Scan(&reservation.ID, &reservation.ProductID, &reservation.Quantity, &reservation.Status, &reservation.CreatedAt, &reservation.ExpiresAt)
+
+	// check errors
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrReservationRepositoryNotFound
+			return
+		}
+		return
+	}
+	return
+}
+
+func (rs *ReservationMysql) Confirm(id int, now time.Time) (reservation internal.Reservation, err error) {
+	// start the transaction
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// get the product of the reservation, the product is locked before the reservation as in Create
+	var productID int
+	row := tx.QueryRow("SELECT `product_id` FROM `reservations` WHERE `id` = ?", id)
+	err = row.Scan(&productID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrReservationRepositoryNotFound
+		}
+		return
+	}
+
+	// lock the product
+	var quantity int
+	row = tx.QueryRow("SELECT `quantity` FROM `products` WHERE `id` = ? FOR UPDATE", productID)
+	err = row.Scan(&quantity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrProductRepositoryNotFound
+		}
+		return
+	}
+
+	// an expired product can not be sold when blocked
+	if rs.blockExpired {
+		err = checkNotExpired(tx, productID, now)
+		if err != nil {
+			return
+		}
+	}
+
+	// lock the reservation, it must still be active
+	reservation, err = activeReservationForUpdate(tx, id, now)
+	if err != nil {
+		return
+	}
+
+	// take the units from the stock
+	if quantity < reservation.Quantity {
+		err = internal.ErrReservationRepositoryInsufficientStock
+		return
+	}
+	_, err = tx.Exec("UPDATE `products` SET `quantity` = ? WHERE `id` = ?", quantity-reservation.Quantity, reservation.ProductID)
+	if err != nil {
+		return
+	}
+
+	// record the sale in the stock ledger
+	movement := internal.StockMovement{
+		ProductID:     reservation.ProductID,
+		Type:          internal.StockMovementSale,
+		Quantity:      -reservation.Quantity,
+		QuantityAfter: quantity - reservation.Quantity,
+		Reason:        fmt.Sprintf("reservation %d confirmed", reservation.ID),
+		Actor:         "reservation",
+		CreatedAt:     now,
+	}
+	err = insertStockMovement(tx, &movement)
+	if err != nil {
+		return
+	}
+
+	// update the status
+	reservation.Status = internal.ReservationConfirmed
+	_, err = tx.Exec("UPDATE `reservations` SET `status` = ? WHERE `id` = ?", reservation.Status, reservation.ID)
+	if err != nil {
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	invalidateProducts(rs.invalidator, reservation.ProductID)
+	return
+}
+
+func (rs *ReservationMysql) Release(id int, now time.Time) (reservation internal.Reservation, err error) {
+	// start the transaction
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the reservation, it must still be active
+	reservation, err = activeReservationForUpdate(tx, id, now)
+	if err != nil {
+		return
+	}
+
+	// update the status
+	reservation.Status = internal.ReservationReleased
+	_, err = tx.Exec("UPDATE `reservations` SET `status` = ? WHERE `id` = ?", reservation.Status, reservation.ID)
+	if err != nil {
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+func (rs *ReservationMysql) ExpireBefore(now time.Time) (affected int, err error) {
+	// execute the query
+	result, err := rs.db.Exec("UPDATE `reservations` SET `status` = ? WHERE `status` = ? AND `expires_at` <= ?", internal.ReservationExpired, internal.ReservationActive, now)
+	if err != nil {
+		return
+	}
+
+	// get the amount of expired reservations
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	affected = int(rowsAffected)
+	return
+}
+
+func (rs *ReservationMysql) Availability(productID int, now time.Time) (availability internal.ProductAvailability, err error) {
+	// query
+	row := rs.db.QueryRow("SELECT p.`quantity`, COALESCE((SELECT SUM(r.`quantity`) FROM `reservations` AS `r` WHERE r.`product_id` = p.`id` AND r.`status` = ? AND r.`expires_at` > ?), 0) FROM `products` AS `p` WHERE p.`id` = ?", internal.ReservationActive, now, productID)
+
+	// serialize the availability
+	availability.ProductID = productID
+	err = row.Scan(&availability.Quantity, &availability.Reserved)
+
+	// check errors
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrProductRepositoryNotFound
+			return
+		}
+		return
+	}
+	availability.Available = availability.Quantity - availability.Reserved
+	return
+}
+
+// availabilityForUpdate locks the product row and returns its available stock
+func availabilityForUpdate(tx *sql.Tx, productID int, now time.Time) (availability internal.ProductAvailability, err error) {
+	availability.ProductID = productID
+
+	// lock the product
+	row := tx.QueryRow("SELECT `quantity` FROM `products` WHERE `id` = ? FOR UPDATE", productID)
+	err = row.Scan(&availability.Quantity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrProductRepositoryNotFound
+		}
+		return
+	}
+
+	// sum the active reservations
+	availability.Reserved, err = reservedQuantity(tx, productID, now)
+	if err != nil {
+		return
+	}
+	availability.Available = availability.Quantity - availability.Reserved
+	return
+}
+
+// reservedQuantity returns the units held by the active reservations of the product
+// - the product row must be locked, the reservations are created holding its lock
+func reservedQuantity(tx *sql.Tx, productID int, now time.Time) (reserved int, err error) {
+	row := tx.QueryRow("SELECT COALESCE(SUM(`quantity`), 0) FROM `reservations` WHERE `product_id` = ? AND `status` = ? AND `expires_at` > ?", productID, internal.ReservationActive, now)
+	err = row.Scan(&reserved)
+	return
+}
+
+// activeReservationForUpdate locks the reservation row and checks it is still active
+func activeReservationForUpdate(tx *sql.Tx, id int, now time.Time) (reservation internal.Reservation, err error) {
+	row := tx.QueryRow("SELECT `id`, `product_id`, `quantity`, `status`, `created_at`, `expires_at` FROM `reservations` WHERE `id` = ? FOR UPDATE", id)
+	err = row.Scan(&reservation.ID, &reservation.ProductID, &reservation.Quantity, &reservation.Status, &reservation.CreatedAt, &reservation.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrReservationRepositoryNotFound
+		}
+		return
+	}
+
+	if reservation.Status != internal.ReservationActive || !reservation.ExpiresAt.After(now) {
+		err = internal.ErrReservationRepositoryNotActive
+		return
+	}
+	return
+}
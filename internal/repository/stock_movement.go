@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"storage/internal"
+	"time"
+)
+
+// NewStockMovementMysql creates a new instance of the stock movement repository
+func NewStockMovementMysql(db *sql.DB) *StockMovementMysql {
+	return &StockMovementMysql{db: db}
+}
+
+// StockMovementMysql is the mysql implementation of the stock movement repository
+type StockMovementMysql struct {
+	db *sql.DB
+	// invalidator is notified of the products changed, nil if none
+	invalidator ProductInvalidator
+	// blockExpired rejects selling the products whose expiration date has passed
+	blockExpired bool
+}
+
+// SetBlockExpired enables or disables rejecting the sales of expired products
+func (s *StockMovementMysql) SetBlockExpired(block bool) {
+	s.blockExpired = block
+}
+
+// SetInvalidator sets the invalidator notified of the products changed
+func (s *StockMovementMysql) SetInvalidator(invalidator ProductInvalidator) {
+	s.invalidator = invalidator
+}
+
+func (s *StockMovementMysql) Create(movement *internal.StockMovement) (err error) {
+	// start the transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the product row and get the current quantity and the units reserved
+	availability, err := availabilityForUpdate(tx, (*movement).ProductID, (*movement).CreatedAt)
+	if err != nil {
+		return
+	}
+	quantity := availability.Quantity
+
+	// an expired product can not be sold when blocked
+	if s.blockExpired && (*movement).Type == internal.StockMovementSale {
+		err = checkNotExpired(tx, (*movement).ProductID, (*movement).CreatedAt)
+		if err != nil {
+			return
+		}
+	}
+
+	// prevent negative stock, the units reserved can not be taken
+	if (*movement).Quantity < 0 && availability.Available+(*movement).Quantity < 0 {
+		err = internal.ErrStockMovementRepositoryNegativeStock
+		return
+	}
+	(*movement).QuantityAfter = quantity + (*movement).Quantity
+
+	// apply the movement
+	_, err = tx.Exec("UPDATE `products` SET `quantity` = ? WHERE `id` = ?", (*movement).QuantityAfter, (*movement).ProductID)
+	if err != nil {
+		return
+	}
+	err = insertStockMovement(tx, movement)
+	if err != nil {
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	invalidateProducts(s.invalidator, (*movement).ProductID)
+	return
+}
+
+func (s *StockMovementMysql) FindByProductID(productID int) (movements []internal.StockMovement, err error) {
+	// query
+	rows, err := s.db.Query("SELECT m.`id`, m.`product_id`, m.`type`, m.`quantity`, m.`quantity_after`, m.`reason`, m.`actor`, m.`created_at` FROM `stock_movements` AS `m` WHERE m.`product_id` = ? ORDER BY m.`id`", productID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the movements
+	for rows.Next() {
+		var movement internal.StockMovement
+		err = rows.Scan(&movement.ID, &movement.ProductID, &movement.Type, &movement.Quantity, &movement.QuantityAfter, &movement.Reason, &movement.Actor, &movement.CreatedAt)
+		if err != nil {
+			return
+		}
+		movements = append(movements, movement)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+
+	// attach the lots taken by each movement
+	allocations, err := s.findLotAllocations(productID)
+	if err != nil {
+		return
+	}
+	for i := range movements {
+		movements[i].Lots = allocations[movements[i].ID]
+	}
+	return
+}
+
+// findLotAllocations returns the lots taken by the movements of the product indexed by movement id
+func (s *StockMovementMysql) findLotAllocations(productID int) (allocations map[int][]internal.LotAllocation, err error) {
+	// query
+	rows, err := s.db.Query("SELECT a.`stock_movement_id`, a.`lot_id`, l.`lot_number`, a.`quantity` FROM `stock_movement_lots` AS `a` INNER JOIN `lots` AS `l` ON l.`id` = a.`lot_id` WHERE l.`product_id` = ? ORDER BY a.`stock_movement_id`, a.`id`", productID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the allocations
+	allocations = make(map[int][]internal.LotAllocation)
+	for rows.Next() {
+		var movementID int
+		var allocation internal.LotAllocation
+		err = rows.Scan(&movementID, &allocation.LotID, &allocation.LotNumber, &allocation.Quantity)
+		if err != nil {
+			return
+		}
+		allocations[movementID] = append(allocations[movementID], allocation)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+// checkNotExpired returns ErrProductRepositoryExpired when the product expired before the day of now
+// - the product row must be locked, it is read again with the lock to see the last expiration
+func checkNotExpired(tx *sql.Tx, productID int, now time.Time) (err error) {
+	var expiration sql.NullString
+	row := tx.QueryRow("SELECT DATE_FORMAT(`expiration`, '%Y-%m-%d') FROM `products` WHERE `id` = ? FOR UPDATE", productID)
+	err = row.Scan(&expiration)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrProductRepositoryNotFound
+		}
+		return
+	}
+	if expiration.Valid && expiration.String < now.UTC().Format(time.DateOnly) {
+		err = internal.ErrProductRepositoryExpired
+	}
+	return
+}
+
+// insertStockMovement records the movement within the transaction and sets its id
+// - the units removed by the movement are taken from the lots of the product, whose quantity must be already updated
+func insertStockMovement(tx *sql.Tx, movement *internal.StockMovement) (err error) {
+	result, err := tx.Exec("INSERT INTO `stock_movements` (`product_id`, `type`, `quantity`, `quantity_after`, `reason`, `actor`, `created_at`) VALUES (?, ?, ?, ?, ?, ?, ?)", (*movement).ProductID, (*movement).Type, (*movement).Quantity, (*movement).QuantityAfter, (*movement).Reason, (*movement).Actor, (*movement).CreatedAt)
+	if err != nil {
+		return
+	}
+
+	// get the last inserted id
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	(*movement).ID = int(id)
+
+	// take the units removed from the lots, the stock allocated to the warehouses can not exceed the units left
+	if (*movement).Quantity < 0 {
+		err = allocateLots(tx, movement)
+		if err != nil {
+			return
+		}
+		err = checkAllocation(tx, (*movement).ProductID)
+		if err != nil {
+			if errors.Is(err, internal.ErrWarehouseRepositoryInsufficientStock) {
+				err = internal.ErrStockMovementRepositoryAllocatedStock
+			}
+			return
+		}
+	}
+
+	// emit the change of stock
+	err = enqueueStockEvent(tx, movement)
+	return
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"storage/internal"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// NewSupplierMysql creates a new instance of the supplier repository
+func NewSupplierMysql(db *sql.DB) *SupplierMysql {
+	return &SupplierMysql{db}
+}
+
+// SupplierMysql is the mysql implementation of the supplier repository
+type SupplierMysql struct {
+	db *sql.DB
+}
+
+func (s *SupplierMysql) FindAll() (suppliers []internal.Supplier, err error) {
+	// query
+	rows, err := s.db.Query("SELECT s.`id`, s.`name`, s.`email`, s.`phone` FROM `suppliers` AS `s` ORDER BY s.`id`")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the suppliers
+	for rows.Next() {
+		var supplier internal.Supplier
+		err = rows.Scan(&supplier.ID, &supplier.Name, &supplier.Email, &supplier.Phone)
+		if err != nil {
+			return
+		}
+		suppliers = append(suppliers, supplier)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *SupplierMysql) FindByID(id int) (supplier internal.Supplier, err error) {
+	// query
+	row := s.db.QueryRow("SELECT s.`id`, s.`name`, s.`email`, s.`phone` FROM `suppliers` AS `s` WHERE s.`id` = ?", id)
+
+	// serialize the supplier
+	err = row.Scan(&supplier.ID, &supplier.Name, &supplier.Email, &supplier.Phone)
+
+	// check errors
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrSupplierRepositoryNotFound
+			return
+		}
+		return
+	}
+	return
+}
+
+func (s *SupplierMysql) Create(supplier *internal.Supplier) (err error) {
+	// execute the query
+	result, err := s.db.Exec("INSERT INTO `suppliers` (`name`, `email`, `phone`) VALUES (?, ?, ?)", (*supplier).Name, (*supplier).Email, (*supplier).Phone)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			err = internal.ErrSupplierRepositoryDuplicated
+		}
+		return
+	}
+
+	// get the last inserted id
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	// set the id of the supplier
+	(*supplier).ID = int(id)
+	return
+}
+
+func (s *SupplierMysql) Update(supplier *internal.Supplier) (err error) {
+	// execute the query
+	result, err := s.db.Exec("UPDATE `suppliers` SET `name` = ?, `email` = ?, `phone` = ? WHERE `id` = ?", (*supplier).Name, (*supplier).Email, (*supplier).Phone, (*supplier).ID)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			err = internal.ErrSupplierRepositoryDuplicated
+		}
+		return
+	}
+
+	// check the supplier exists, rows are not affected when nothing changed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		_, err = s.FindByID((*supplier).ID)
+	}
+	return
+}
+
+func (s *SupplierMysql) Delete(id int) (err error) {
+	// execute the query, the product links are deleted in cascade
+	result, err := s.db.Exec("DELETE FROM `suppliers` WHERE `id` = ?", id)
+	if err != nil {
+		return
+	}
+
+	// check the supplier existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = internal.ErrSupplierRepositoryNotFound
+		return
+	}
+	return
+}
+
+func (s *SupplierMysql) FindByProductID(productID int) (links []internal.ProductSupplier, err error) {
+	// query
+	rows, err := s.db.Query("SELECT ps.`product_id`, ps.`supplier_id`, ps.`supplier_sku`, ps.`cost_price`, ps.`lead_time_days`, ps.`preferred` FROM `product_suppliers` AS `ps` WHERE ps.`product_id` = ? ORDER BY ps.`preferred` DESC, ps.`cost_price`", productID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the links
+	for rows.Next() {
+		var link internal.ProductSupplier
+		err = rows.Scan(&link.ProductID, &link.SupplierID, &link.SupplierSKU, &link.CostPrice, &link.LeadTimeDays, &link.Preferred)
+		if err != nil {
+			return
+		}
+		links = append(links, link)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *SupplierMysql) SetProductSupplier(link *internal.ProductSupplier) (err error) {
+	// start the transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the product, so the preferred flag changes are serialized
+	_, err = lockProductQuantity(tx, (*link).ProductID)
+	if err != nil {
+		return
+	}
+
+	// only one preferred supplier per product
+	if (*link).Preferred {
+		_, err = tx.Exec("UPDATE `product_suppliers` SET `preferred` = FALSE WHERE `product_id` = ? AND `supplier_id` <> ?", (*link).ProductID, (*link).SupplierID)
+		if err != nil {
+			return
+		}
+	}
+
+	// execute the query
+	_, err = tx.Exec("INSERT INTO `product_suppliers` (`product_id`, `supplier_id`, `supplier_sku`, `cost_price`, `lead_time_days`, `preferred`) VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE `supplier_sku` = VALUES(`supplier_sku`), `cost_price` = VALUES(`cost_price`), `lead_time_days` = VALUES(`lead_time_days`), `preferred` = VALUES(`preferred`)", (*link).ProductID, (*link).SupplierID, (*link).SupplierSKU, (*link).CostPrice, (*link).LeadTimeDays, (*link).Preferred)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 {
+			err = internal.ErrSupplierRepositoryNotFound
+		}
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+func (s *SupplierMysql) DeleteProductSupplier(productID, supplierID int) (err error) {
+	// execute the query
+	result, err := s.db.Exec("DELETE FROM `product_suppliers` WHERE `product_id` = ? AND `supplier_id` = ?", productID, supplierID)
+	if err != nil {
+		return
+	}
+
+	// check the link existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = internal.ErrProductSupplierRepositoryNotFound
+		return
+	}
+	return
+}
+
+func (s *SupplierMysql) FindMargins() (margins []internal.ProductMargin, err error) {
+	return s.findMargins("")
+}
+
+func (s *SupplierMysql) FindMarginByProductID(productID int) (margin internal.ProductMargin, err error) {
+	margins, err := s.findMargins("WHERE ps.`product_id` = ?", productID)
+	if err != nil {
+		return
+	}
+	if len(margins) == 0 {
+		err = internal.ErrProductSupplierRepositoryNotFound
+		return
+	}
+	margin = margins[0]
+	return
+}
+
+// findMargins returns the price and cost of the products with suppliers matching the condition
+// - the cost is taken from the preferred supplier, or else from the cheapest one
+func (s *SupplierMysql) findMargins(condition string, args ...any) (margins []internal.ProductMargin, err error) {
+	// query
+	rows, err := s.db.Query("SELECT m.`product_id`, m.`supplier_id`, m.`price`, m.`cost_price` FROM (SELECT ps.`product_id`, ps.`supplier_id`, p.`price`, ps.`cost_price`, ROW_NUMBER() OVER (PARTITION BY ps.`product_id` ORDER BY ps.`preferred` DESC, ps.`cost_price`, ps.`supplier_id`) AS `rank` FROM `product_suppliers` AS `ps` INNER JOIN `products` AS `p` ON p.`id` = ps.`product_id` "+condition+") AS `m` WHERE m.`rank` = 1 ORDER BY m.`product_id`", args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the margins
+	for rows.Next() {
+		var margin internal.ProductMargin
+		err = rows.Scan(&margin.ProductID, &margin.SupplierID, &margin.Price, &margin.CostPrice)
+		if err != nil {
+			return
+		}
+		margins = append(margins, margin)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,422 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"storage/internal"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// NewWarehouseMysql creates a new instance of the warehouse repository
+func NewWarehouseMysql(db *sql.DB) *WarehouseMysql {
+	return &WarehouseMysql{db: db}
+}
+
+// WarehouseMysql is the mysql implementation of the warehouse repository
+// - stock changes lock the product row first, so every change of stock of a product is serialized
+type WarehouseMysql struct {
+	db *sql.DB
+	// invalidator is notified of the products changed, nil if none
+	invalidator ProductInvalidator
+}
+
+// SetInvalidator sets the invalidator notified of the products changed
+func (wh *WarehouseMysql) SetInvalidator(invalidator ProductInvalidator) {
+	wh.invalidator = invalidator
+}
+
+func (wh *WarehouseMysql) FindAll() (warehouses []internal.Warehouse, err error) {
+	// query
+	rows, err := wh.db.Query("SELECT w.`id`, w.`name`, w.`address` FROM `warehouses` AS `w` ORDER BY w.`id`")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the warehouses
+	for rows.Next() {
+		var warehouse internal.Warehouse
+		err = rows.Scan(&warehouse.ID, &warehouse.Name, &warehouse.Address)
+		if err != nil {
+			return
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (wh *WarehouseMysql) FindByID(id int) (warehouse internal.Warehouse, err error) {
+	// query
+	row := wh.db.QueryRow("SELECT w.`id`, w.`name`, w.`address` FROM `warehouses` AS `w` WHERE w.`id` = ?", id)
+
+	// serialize the warehouse
+	err = row.Scan(&warehouse.ID, &warehouse.Name, &warehouse.Address)
+
+	// check errors
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrWarehouseRepositoryNotFound
+			return
+		}
+		return
+	}
+	return
+}
+
+func (wh *WarehouseMysql) Create(warehouse *internal.Warehouse) (err error) {
+	// execute the query
+	result, err := wh.db.Exec("INSERT INTO `warehouses` (`name`, `address`) VALUES (?, ?)", (*warehouse).Name, (*warehouse).Address)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			err = internal.ErrWarehouseRepositoryDuplicated
+		}
+		return
+	}
+
+	// get the last inserted id
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	// set the id of the warehouse
+	(*warehouse).ID = int(id)
+	return
+}
+
+func (wh *WarehouseMysql) Update(warehouse *internal.Warehouse) (err error) {
+	// execute the query
+	result, err := wh.db.Exec("UPDATE `warehouses` SET `name` = ?, `address` = ? WHERE `id` = ?", (*warehouse).Name, (*warehouse).Address, (*warehouse).ID)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			err = internal.ErrWarehouseRepositoryDuplicated
+		}
+		return
+	}
+
+	// check the warehouse exists, rows are not affected when nothing changed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		_, err = wh.FindByID((*warehouse).ID)
+	}
+	return
+}
+
+func (wh *WarehouseMysql) Delete(id int) (err error) {
+	// start the transaction
+	tx, err := wh.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the products stocked in the warehouse first, as every change of stock does
+	rows, err := tx.Query("SELECT `product_id` FROM `warehouse_stocks` WHERE `warehouse_id` = ? ORDER BY `product_id`", id)
+	if err != nil {
+		return
+	}
+	var productIDs []int
+	for rows.Next() {
+		var productID int
+		err = rows.Scan(&productID)
+		if err != nil {
+			rows.Close()
+			return
+		}
+		productIDs = append(productIDs, productID)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	for _, productID := range productIDs {
+		_, err = lockProductQuantity(tx, productID)
+		if err != nil {
+			return
+		}
+	}
+
+	// a warehouse with stock can not be deleted
+	var quantity int
+	row := tx.QueryRow("SELECT COALESCE(SUM(`quantity`), 0) FROM `warehouse_stocks` WHERE `warehouse_id` = ? FOR UPDATE", id)
+	err = row.Scan(&quantity)
+	if err != nil {
+		return
+	}
+	if quantity > 0 {
+		err = internal.ErrWarehouseRepositoryNotEmpty
+		return
+	}
+
+	// the stocks of the products change with the rows deleted, so they are modified
+	_, err = tx.Exec("UPDATE `products` AS `p` INNER JOIN `warehouse_stocks` AS `s` ON s.`product_id` = p.`id` SET p.`updated_at` = CURRENT_TIMESTAMP(6) WHERE s.`warehouse_id` = ?", id)
+	if err != nil {
+		return
+	}
+
+	// delete the empty stock rows and the warehouse
+	_, err = tx.Exec("DELETE FROM `warehouse_stocks` WHERE `warehouse_id` = ?", id)
+	if err != nil {
+		return
+	}
+	result, err := tx.Exec("DELETE FROM `warehouses` WHERE `id` = ?", id)
+	if err != nil {
+		return
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = internal.ErrWarehouseRepositoryNotFound
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	invalidateProducts(wh.invalidator, productIDs...)
+	return
+}
+
+func (wh *WarehouseMysql) FindStockByWarehouseID(warehouseID int) (stocks []internal.WarehouseStock, err error) {
+	// check the warehouse exists
+	_, err = wh.FindByID(warehouseID)
+	if err != nil {
+		return
+	}
+
+	// query
+	rows, err := wh.db.Query("SELECT s.`product_id`, s.`warehouse_id`, s.`quantity` FROM `warehouse_stocks` AS `s` WHERE s.`warehouse_id` = ? AND s.`quantity` > 0 ORDER BY s.`product_id`", warehouseID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the stocks
+	return scanWarehouseStocks(rows)
+}
+
+func (wh *WarehouseMysql) FindStockByProductID(productID int) (stock internal.ProductStock, err error) {
+	// get the total stock of the product
+	stock.ProductID = productID
+	row := wh.db.QueryRow("SELECT `quantity` FROM `products` WHERE `id` = ?", productID)
+	err = row.Scan(&stock.Quantity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrProductRepositoryNotFound
+		}
+		return
+	}
+
+	// query the stock per warehouse
+	rows, err := wh.db.Query("SELECT s.`product_id`, s.`warehouse_id`, s.`quantity` FROM `warehouse_stocks` AS `s` WHERE s.`product_id` = ? AND s.`quantity` > 0 ORDER BY s.`warehouse_id`", productID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the stocks
+	stock.Warehouses, err = scanWarehouseStocks(rows)
+	if err != nil {
+		return
+	}
+
+	// aggregate the totals
+	for _, s := range stock.Warehouses {
+		stock.Allocated += s.Quantity
+	}
+	stock.Unallocated = stock.Quantity - stock.Allocated
+	return
+}
+
+func (wh *WarehouseMysql) SetStock(stock *internal.WarehouseStock) (err error) {
+	// start the transaction
+	tx, err := wh.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the product and check the warehouse exists
+	_, err = lockProductQuantity(tx, (*stock).ProductID)
+	if err != nil {
+		return
+	}
+	err = checkWarehouseExists(tx, (*stock).WarehouseID)
+	if err != nil {
+		return
+	}
+
+	// execute the query
+	result, err := tx.Exec("INSERT INTO `warehouse_stocks` (`product_id`, `warehouse_id`, `quantity`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `quantity` = VALUES(`quantity`)", (*stock).ProductID, (*stock).WarehouseID, (*stock).Quantity)
+	if err != nil {
+		return
+	}
+
+	// the stock allocated to all the warehouses can not exceed the product quantity
+	err = checkAllocation(tx, (*stock).ProductID)
+	if err != nil {
+		return
+	}
+
+	// the stock allocated to the warehouses is part of the product, so a change of it modifies the product
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected > 0 {
+		_, err = tx.Exec("UPDATE `products` SET `updated_at` = CURRENT_TIMESTAMP(6) WHERE `id` = ?", (*stock).ProductID)
+		if err != nil {
+			return
+		}
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	invalidateProducts(wh.invalidator, (*stock).ProductID)
+	return
+}
+
+func (wh *WarehouseMysql) Transfer(productID, fromWarehouseID, toWarehouseID, quantity int) (err error) {
+	// start the transaction
+	tx, err := wh.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the product and check the warehouses exist
+	_, err = lockProductQuantity(tx, productID)
+	if err != nil {
+		return
+	}
+	err = checkWarehouseExists(tx, fromWarehouseID)
+	if err != nil {
+		return
+	}
+	err = checkWarehouseExists(tx, toWarehouseID)
+	if err != nil {
+		return
+	}
+
+	// check the origin has enough units
+	var available int
+	row := tx.QueryRow("SELECT `quantity` FROM `warehouse_stocks` WHERE `product_id` = ? AND `warehouse_id` = ? FOR UPDATE", productID, fromWarehouseID)
+	err = row.Scan(&available)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	err = nil
+	if available < quantity {
+		err = internal.ErrWarehouseRepositoryInsufficientStock
+		return
+	}
+
+	// move the units
+	_, err = tx.Exec("UPDATE `warehouse_stocks` SET `quantity` = `quantity` - ? WHERE `product_id` = ? AND `warehouse_id` = ?", quantity, productID, fromWarehouseID)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec("INSERT INTO `warehouse_stocks` (`product_id`, `warehouse_id`, `quantity`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `quantity` = `quantity` + VALUES(`quantity`)", productID, toWarehouseID, quantity)
+	if err != nil {
+		return
+	}
+
+	// the units allocated in total are the same, so only the origin is checked and the product is not modified
+
+	// commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	invalidateProducts(wh.invalidator, productID)
+	return
+}
+
+// lockProductQuantity locks the product row and returns its quantity
+func lockProductQuantity(tx *sql.Tx, productID int) (quantity int, err error) {
+	row := tx.QueryRow("SELECT `quantity` FROM `products` WHERE `id` = ? FOR UPDATE", productID)
+	err = row.Scan(&quantity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrProductRepositoryNotFound
+		}
+		return
+	}
+	return
+}
+
+// checkAllocation returns ErrWarehouseRepositoryInsufficientStock when the stock allocated to the warehouses
+// exceeds the quantity of the product, whose row must be locked
+func checkAllocation(tx *sql.Tx, productID int) (err error) {
+	var quantity, allocated int
+	row := tx.QueryRow("SELECT p.`quantity`, COALESCE((SELECT SUM(ws.`quantity`) FROM `warehouse_stocks` AS `ws` WHERE ws.`product_id` = p.`id`), 0) FROM `products` AS `p` WHERE p.`id` = ?", productID)
+	err = row.Scan(&quantity, &allocated)
+	if err != nil {
+		return
+	}
+	if allocated > quantity {
+		err = internal.ErrWarehouseRepositoryInsufficientStock
+	}
+	return
+}
+
+// checkWarehouseExists returns ErrWarehouseRepositoryNotFound if the warehouse does not exist
+func checkWarehouseExists(tx *sql.Tx, warehouseID int) (err error) {
+	var id int
+	row := tx.QueryRow("SELECT `id` FROM `warehouses` WHERE `id` = ?", warehouseID)
+	err = row.Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrWarehouseRepositoryNotFound
+		}
+		return
+	}
+	return
+}
+
+// scanWarehouseStocks serializes the rows of a warehouse stocks query
+func scanWarehouseStocks(rows *sql.Rows) (stocks []internal.WarehouseStock, err error) {
+	for rows.Next() {
+		var stock internal.WarehouseStock
+		err = rows.Scan(&stock.ProductID, &stock.WarehouseID, &stock.Quantity)
+		if err != nil {
+			return
+		}
+		stocks = append(stocks, stock)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,346 @@
+package repository
+
+import (
+	"database/sql"
+	"storage/internal"
+	"strings"
+	"time"
+)
+
+// NewWebhookMysql creates a new instance of the webhook repository
+func NewWebhookMysql(db *sql.DB) *WebhookMysql {
+	return &WebhookMysql{db}
+}
+
+// WebhookMysql is the mysql implementation of the webhook repository
+type WebhookMysql struct {
+	db *sql.DB
+}
+
+func (wh *WebhookMysql) FindAll() (webhooks []internal.Webhook, err error) {
+	// query
+	rows, err := wh.db.Query("SELECT w.`id`, w.`url`, w.`event_types`, w.`secret`, w.`active`, w.`created_at` FROM `webhooks` AS `w` ORDER BY w.`id`")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the webhooks
+	return scanWebhooks(rows)
+}
+
+func (wh *WebhookMysql) FindByID(id int) (webhook internal.Webhook, err error) {
+	// query
+	row := wh.db.QueryRow("SELECT w.`id`, w.`url`, w.`event_types`, w.`secret`, w.`active`, w.`created_at` FROM `webhooks` AS `w` WHERE w.`id` = ?", id)
+
+	// serialize the webhook
+	var eventTypes string
+	err = row.Scan(&webhook.ID, &webhook.URL, &eventTypes, &webhook.Secret, &webhook.Active, &webhook.CreatedAt)
+	webhook.EventTypes = splitEventTypes(eventTypes)
+
+	// check errors
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = internal.ErrWebhookRepositoryNotFound
+			return
+		}
+		return
+	}
+	return
+}
+
+func (wh *WebhookMysql) FindActive() (webhooks []internal.Webhook, err error) {
+	// query
+	rows, err := wh.db.Query("SELECT w.`id`, w.`url`, w.`event_types`, w.`secret`, w.`active`, w.`created_at` FROM `webhooks` AS `w` WHERE w.`active` = TRUE ORDER BY w.`id`")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the webhooks
+	return scanWebhooks(rows)
+}
+
+func (wh *WebhookMysql) Create(webhook *internal.Webhook) (err error) {
+	// execute the query
+	result, err := wh.db.Exec("INSERT INTO `webhooks` (`url`, `event_types`, `secret`, `active`, `created_at`) VALUES (?, ?, ?, ?, ?)", (*webhook).URL, joinEventTypes((*webhook).EventTypes), (*webhook).Secret, (*webhook).Active, (*webhook).CreatedAt)
+	if err != nil {
+		return
+	}
+
+	// get the last inserted id
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	// set the id of the webhook
+	(*webhook).ID = int(id)
+	return
+}
+
+func (wh *WebhookMysql) Update(webhook *internal.Webhook) (err error) {
+	// execute the query
+	result, err := wh.db.Exec("UPDATE `webhooks` SET `url` = ?, `event_types` = ?, `active` = ? WHERE `id` = ?", (*webhook).URL, joinEventTypes((*webhook).EventTypes), (*webhook).Active, (*webhook).ID)
+	if err != nil {
+		return
+	}
+
+	// check the webhook exists, an update changing nothing affects no rows
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		_, err = wh.FindByID((*webhook).ID)
+	}
+	return
+}
+
+func (wh *WebhookMysql) Delete(id int) (err error) {
+	// execute the query
+	result, err := wh.db.Exec("DELETE FROM `webhooks` WHERE `id` = ?", id)
+	if err != nil {
+		return
+	}
+
+	// check the webhook existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = internal.ErrWebhookRepositoryNotFound
+	}
+	return
+}
+
+func (wh *WebhookMysql) CreateDeliveries(deliveries []internal.WebhookDelivery) (err error) {
+	// start the transaction
+	tx, err := wh.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// execute the queries
+	// - the unique key of webhook and event ignores the events relayed again
+	for _, delivery := range deliveries {
+		_, err = tx.Exec("INSERT IGNORE INTO `webhook_deliveries` (`webhook_id`, `event_id`, `event_type`, `body`, `status`, `next_attempt_at`, `created_at`) VALUES (?, ?, ?, ?, ?, ?, ?)", delivery.WebhookID, delivery.EventID, delivery.EventType, delivery.Body, delivery.Status, delivery.NextAttemptAt, delivery.CreatedAt)
+		if err != nil {
+			return
+		}
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+func (wh *WebhookMysql) ClaimDueDeliveries(now, until time.Time, limit int) (deliveries []internal.WebhookDelivery, err error) {
+	// start the transaction
+	tx, err := wh.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// lock the due deliveries, skipping the ones claimed by a concurrent run
+	rows, err := tx.Query("SELECT d.`id`, d.`webhook_id`, d.`event_id`, d.`event_type`, d.`body`, d.`status`, d.`attempts`, d.`next_attempt_at`, d.`last_status_code`, d.`last_error`, d.`created_at`, d.`delivered_at` FROM `webhook_deliveries` AS `d` INNER JOIN `webhooks` AS `w` ON w.`id` = d.`webhook_id` WHERE d.`status` = ? AND d.`next_attempt_at` <= ? AND w.`active` = TRUE ORDER BY d.`next_attempt_at`, d.`id` LIMIT ? FOR UPDATE OF `d` SKIP LOCKED", internal.WebhookDeliveryPending, now, limit)
+	if err != nil {
+		return
+	}
+	deliveries, err = scanWebhookDeliveries(rows)
+	rows.Close()
+	if err != nil {
+		return
+	}
+
+	// postpone them until the claim expires
+	if len(deliveries) > 0 {
+		args := make([]any, 0, len(deliveries)+1)
+		args = append(args, until)
+		for _, delivery := range deliveries {
+			args = append(args, delivery.ID)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(deliveries)), ", ")
+		_, err = tx.Exec("UPDATE `webhook_deliveries` SET `next_attempt_at` = ? WHERE `id` IN ("+placeholders+")", args...)
+		if err != nil {
+			return
+		}
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+func (wh *WebhookMysql) RecordAttempt(delivery *internal.WebhookDelivery, attempt *internal.WebhookAttempt) (err error) {
+	// start the transaction
+	tx, err := wh.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// store the attempt
+	result, err := tx.Exec("INSERT INTO `webhook_attempts` (`delivery_id`, `status_code`, `error`, `duration_ms`, `attempted_at`) VALUES (?, ?, LEFT(?, 1024), ?, ?)", (*attempt).DeliveryID, (*attempt).StatusCode, (*attempt).Error, (*attempt).Duration.Milliseconds(), (*attempt).AttemptedAt)
+	if err != nil {
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	(*attempt).ID = int(id)
+
+	// store the state of the delivery
+	_, err = tx.Exec("UPDATE `webhook_deliveries` SET `status` = ?, `attempts` = ?, `next_attempt_at` = ?, `last_status_code` = ?, `last_error` = LEFT(?, 1024), `delivered_at` = ? WHERE `id` = ?", (*delivery).Status, (*delivery).Attempts, (*delivery).NextAttemptAt, (*delivery).LastStatusCode, (*delivery).LastError, (*delivery).DeliveredAt, (*delivery).ID)
+	if err != nil {
+		return
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	return
+}
+
+func (wh *WebhookMysql) FindDeliveries(webhookID int, status internal.WebhookDeliveryStatus, limit int) (deliveries []internal.WebhookDelivery, err error) {
+	// query
+	rows, err := wh.db.Query("SELECT d.`id`, d.`webhook_id`, d.`event_id`, d.`event_type`, d.`body`, d.`status`, d.`attempts`, d.`next_attempt_at`, d.`last_status_code`, d.`last_error`, d.`created_at`, d.`delivered_at` FROM `webhook_deliveries` AS `d` WHERE d.`webhook_id` = ? AND (? = '' OR d.`status` = ?) ORDER BY d.`id` DESC LIMIT ?", webhookID, status, status, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the deliveries
+	return scanWebhookDeliveries(rows)
+}
+
+func (wh *WebhookMysql) FindDelivery(webhookID, id int) (delivery internal.WebhookDelivery, err error) {
+	// query
+	rows, err := wh.db.Query("SELECT d.`id`, d.`webhook_id`, d.`event_id`, d.`event_type`, d.`body`, d.`status`, d.`attempts`, d.`next_attempt_at`, d.`last_status_code`, d.`last_error`, d.`created_at`, d.`delivered_at` FROM `webhook_deliveries` AS `d` WHERE d.`webhook_id` = ? AND d.`id` = ?", webhookID, id)
+	if err != nil {
+		return
+	}
+	deliveries, err := scanWebhookDeliveries(rows)
+	rows.Close()
+	if err != nil {
+		return
+	}
+	if len(deliveries) == 0 {
+		err = internal.ErrWebhookRepositoryNotFound
+		return
+	}
+	delivery = deliveries[0]
+
+	// get the attempts
+	rows, err = wh.db.Query("SELECT a.`id`, a.`delivery_id`, a.`status_code`, a.`error`, a.`duration_ms`, a.`attempted_at` FROM `webhook_attempts` AS `a` WHERE a.`delivery_id` = ? ORDER BY a.`id`", id)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	// serialize the attempts
+	for rows.Next() {
+		var attempt internal.WebhookAttempt
+		var durationMs int64
+		err = rows.Scan(&attempt.ID, &attempt.DeliveryID, &attempt.StatusCode, &attempt.Error, &durationMs, &attempt.AttemptedAt)
+		if err != nil {
+			return
+		}
+		attempt.Duration = time.Duration(durationMs) * time.Millisecond
+		delivery.History = append(delivery.History, attempt)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (wh *WebhookMysql) Reschedule(webhookID, id int, at time.Time) (err error) {
+	// execute the query
+	result, err := wh.db.Exec("UPDATE `webhook_deliveries` SET `status` = ?, `attempts` = 0, `next_attempt_at` = ? WHERE `webhook_id` = ? AND `id` = ?", internal.WebhookDeliveryPending, at, webhookID, id)
+	if err != nil {
+		return
+	}
+
+	// check the delivery exists
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = internal.ErrWebhookRepositoryNotFound
+	}
+	return
+}
+
+// scanWebhooks serializes the rows of a webhooks query
+func scanWebhooks(rows *sql.Rows) (webhooks []internal.Webhook, err error) {
+	for rows.Next() {
+		var webhook internal.Webhook
+		var eventTypes string
+		err = rows.Scan(&webhook.ID, &webhook.URL, &eventTypes, &webhook.Secret, &webhook.Active, &webhook.CreatedAt)
+		if err != nil {
+			return
+		}
+		webhook.EventTypes = splitEventTypes(eventTypes)
+		webhooks = append(webhooks, webhook)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+// scanWebhookDeliveries serializes the rows of a webhook deliveries query
+func scanWebhookDeliveries(rows *sql.Rows) (deliveries []internal.WebhookDelivery, err error) {
+	for rows.Next() {
+		var delivery internal.WebhookDelivery
+		err = rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &delivery.Body, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt)
+		if err != nil {
+			return
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+// joinEventTypes returns the event types separated by commas
+func joinEventTypes(eventTypes []internal.EventType) string {
+	types := make([]string, len(eventTypes))
+	for i, eventType := range eventTypes {
+		types[i] = string(eventType)
+	}
+	return strings.Join(types, ",")
+}
+
+// splitEventTypes returns the event types separated by commas, none when empty
+func splitEventTypes(eventTypes string) (types []internal.EventType) {
+	if eventTypes == "" {
+		return
+	}
+	for _, eventType := range strings.Split(eventTypes, ",") {
+		types = append(types, internal.EventType(eventType))
+	}
+	return
+}
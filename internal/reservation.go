@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+// ReservationStatus is the state of a reservation
+type ReservationStatus string
+
+const (
+	// ReservationActive holds the units until the reservation expires
+	ReservationActive ReservationStatus = "active"
+	// ReservationConfirmed took the units from the stock
+	ReservationConfirmed ReservationStatus = "confirmed"
+	// ReservationReleased gave the units back before expiring
+	ReservationReleased ReservationStatus = "released"
+	// ReservationExpired gave the units back after its ttl
+	ReservationExpired ReservationStatus = "expired"
+)
+
+// Reservation is a struct that contains units of a product held for an order
+type Reservation struct {
+	// ID is the unique identifier of the reservation
+	ID int
+	// ProductID is the identifier of the product
+	ProductID int
+	// Quantity is the amount of units held
+	Quantity int
+	// Status is the state of the reservation
+	Status ReservationStatus
+	// CreatedAt is the moment the reservation was created
+	CreatedAt time.Time
+	// ExpiresAt is the moment the units are no longer held if not confirmed
+	ExpiresAt time.Time
+}
+
+// ProductAvailability is a struct that contains the stock of a product available to reserve
+type ProductAvailability struct {
+	// ProductID is the identifier of the product
+	ProductID int
+	// Quantity is the stock of the product
+	Quantity int
+	// Reserved is the amount of units held by active reservations
+	Reserved int
+	// Available is the quantity minus the reserved units
+	Available int
+}
+
+var (
+	// ErrReservationRepositoryNotFound is the error returned when the reservation is not found
+	ErrReservationRepositoryNotFound = errors.New("repository: reservation not found")
+	// ErrReservationRepositoryInsufficientStock is the error returned when there are not enough available units
+	ErrReservationRepositoryInsufficientStock = errors.New("repository: insufficient available stock")
+	// ErrReservationRepositoryNotActive is the error returned when the reservation is no longer active
+	ErrReservationRepositoryNotActive = errors.New("repository: reservation not active")
+	// ErrReservationServiceInvalidField is the error returned when the reservation has an invalid field
+	ErrReservationServiceInvalidField = errors.New("service: invalid field")
+)
+
+// ReservationRepository is an interface that contains the methods that the reservation repository should support
+// - now is the current time, reservations expiring before it are not active anymore
+type ReservationRepository interface {
+	// Create holds the units locking the product, fails if there are not enough available units
+	Create(reservation *Reservation, now time.Time) error
+	// FindByID returns the reservation with the given ID
+	FindByID(id int) (Reservation, error)
+	// Confirm takes the reserved units from the product quantity
+	// - it returns ErrProductRepositoryExpired for an expired product when selling them is blocked
+	Confirm(id int, now time.Time) (Reservation, error)
+	// Release gives back the reserved units
+	Release(id int, now time.Time) (Reservation, error)
+	// ExpireBefore marks the active reservations expiring before now as expired
+	ExpireBefore(now time.Time) (affected int, err error)
+	// Availability returns the available stock of the product
+	Availability(productID int, now time.Time) (ProductAvailability, error)
+}
+
+// ReservationService is an interface that contains the methods that the reservation service should support
+type ReservationService interface {
+	// Create holds units of a product during ttl
+	Create(reservation *Reservation, ttl time.Duration) error
+	// FindByID returns the reservation with the given ID
+	FindByID(id int) (Reservation, error)
+	// Confirm takes the reserved units from the product quantity
+	Confirm(id int) (Reservation, error)
+	// Release gives back the reserved units
+	Release(id int) (Reservation, error)
+	// ExpireStale marks the reservations past their ttl as expired
+	ExpireStale() (affected int, err error)
+	// Availability returns the available stock of the product
+	Availability(productID int) (ProductAvailability, error)
+}
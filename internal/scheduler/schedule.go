@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrScheduleInvalid is the error returned when a schedule expression can not be parsed
+	ErrScheduleInvalid = errors.New("scheduler: invalid schedule")
+)
+
+// Schedule returns the next time a job should run after the given time
+type Schedule interface {
+	// Next returns the next activation time, later than t
+	Next(t time.Time) time.Time
+}
+
+// Parse parses a schedule expression
+// - five cron fields: minute hour day-of-month month day-of-week, supporting *, lists, ranges and steps
+// - the descriptors @hourly, @daily, @weekly and @monthly
+// - @every <duration>, e.g. @every 5m
+func Parse(spec string) (s Schedule, err error) {
+	spec = strings.TrimSpace(spec)
+
+	// descriptors
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+	if strings.HasPrefix(spec, "@every ") {
+		var every time.Duration
+		every, err = time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || every < time.Second {
+			err = fmt.Errorf("%w: %s", ErrScheduleInvalid, spec)
+			return
+		}
+		s = everySchedule{every: every}
+		return
+	}
+
+	// cron fields
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		err = fmt.Errorf("%w: expected 5 fields in %q", ErrScheduleInvalid, spec)
+		return
+	}
+	var c cronSchedule
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	sets := [5]*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, field := range fields {
+		*sets[i], err = parseField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			err = fmt.Errorf("%w: %q: %v", ErrScheduleInvalid, spec, err)
+			return
+		}
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	s = c
+	return
+}
+
+// parseField parses a cron field into a bit set of the allowed values
+func parseField(field string, min, max int) (set uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		// step
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		// range
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			lo, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid range in %q", part)
+			}
+			hi, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return 0, fmt.Errorf("invalid range in %q", part)
+			}
+		default:
+			lo, err = strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			if step == 1 {
+				hi = lo
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range in %q", part)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+// cronSchedule is a schedule defined by cron fields
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny tell whether day-of-month and day-of-week were "*"
+	domAny, dowAny bool
+}
+
+// Next returns the next minute matching the fields, searching up to five years ahead
+func (c cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron semantics: when both day fields are restricted either may match
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// everySchedule is a schedule running at a fixed interval
+type everySchedule struct {
+	every time.Duration
+}
+
+// Next returns t plus the interval
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(e.every)
+}
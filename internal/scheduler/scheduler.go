@@ -0,0 +1,193 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"storage/internal"
+	"sync"
+	"time"
+)
+
+// Job is the function run by the scheduler
+type Job func(ctx context.Context) error
+
+// NewScheduler creates a new instance of the scheduler
+// - owner identifies this instance when taking leases
+// - lease may be nil, then jobs run in every instance
+func NewScheduler(lease internal.JobLeaseRepository, owner string) *Scheduler {
+	return &Scheduler{
+		lease: lease,
+		owner: owner,
+		jobs:  make(map[string]*entry),
+		now:   time.Now,
+	}
+}
+
+// Scheduler runs registered jobs on their schedules
+type Scheduler struct {
+	// lease coordinates the runs between replicas
+	lease internal.JobLeaseRepository
+	// owner identifies this instance
+	owner string
+	// now returns the current time
+	now func() time.Time
+
+	// mu protects the jobs
+	mu   sync.Mutex
+	jobs map[string]*entry
+	// wg tracks the running jobs
+	wg sync.WaitGroup
+}
+
+// entry is a registered job and its state
+type entry struct {
+	job      Job
+	schedule Schedule
+	timeout  time.Duration
+	status   internal.JobStatus
+}
+
+// Register adds a job with a schedule expression, see Parse
+// - timeout bounds every run and is also the duration of the lease
+func (s *Scheduler) Register(name, spec string, timeout time.Duration, job Job) (err error) {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("scheduler: job %s already registered", name)
+	}
+	s.jobs[name] = &entry{
+		job:      job,
+		schedule: schedule,
+		timeout:  timeout,
+		status: internal.JobStatus{
+			Name:     name,
+			Schedule: spec,
+			NextRun:  schedule.Next(s.now()),
+		},
+	}
+	return
+}
+
+// Start runs the due jobs every tick until the context is done, then waits for the running jobs
+func (s *Scheduler) Start(ctx context.Context, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+			s.runDue(ctx)
+		}
+	}
+}
+
+// runDue launches the jobs whose next run has passed
+func (s *Scheduler) runDue(ctx context.Context) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, e := range s.jobs {
+		if now.Before(e.status.NextRun) {
+			continue
+		}
+		e.status.NextRun = e.schedule.Next(now)
+
+		// avoid overlapping runs
+		if e.status.Running {
+			e.status.Skipped++
+			continue
+		}
+		e.status.Running = true
+
+		s.wg.Add(1)
+		go s.run(ctx, name, e, e.status.NextRun)
+	}
+}
+
+// run executes a job holding its lease, kept until the next tick so no other replica runs this one again
+func (s *Scheduler) run(ctx context.Context, name string, e *entry, nextRun time.Time) {
+	defer s.wg.Done()
+
+	// take the lease so only one replica runs the job
+	acquired := true
+	if s.lease != nil {
+		var err error
+		acquired, err = s.lease.Acquire(name, s.owner, e.timeout)
+		if err != nil {
+			log.Printf("scheduler: acquire lease of %s: %v", name, err)
+			acquired = false
+		}
+	}
+	if !acquired {
+		s.mu.Lock()
+		e.status.Running = false
+		e.status.Skipped++
+		s.mu.Unlock()
+		return
+	}
+
+	// run the job
+	start := s.now()
+	runCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	err := e.job(runCtx)
+	cancel()
+
+	// release the lease
+	if s.lease != nil {
+		if err := s.lease.Release(name, s.owner, nextRun.Sub(s.now())); err != nil {
+			log.Printf("scheduler: release lease of %s: %v", name, err)
+		}
+	}
+
+	// update the status
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.status.Running = false
+	e.status.Runs++
+	e.status.LastRun = start
+	e.status.LastDuration = s.now().Sub(start)
+	e.status.LastError = ""
+	if err != nil {
+		e.status.LastError = err.Error()
+		log.Printf("scheduler: job %s: %v", name, err)
+	}
+}
+
+// Statuses returns the status of all the jobs ordered by name
+func (s *Scheduler) Statuses() (statuses []internal.JobStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.jobs {
+		statuses = append(statuses, e.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return
+}
+
+// Status returns the status of the job with the given name
+func (s *Scheduler) Status(name string) (status internal.JobStatus, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.jobs[name]
+	if !ok {
+		err = internal.ErrJobNotFound
+		return
+	}
+	status = e.status
+	return
+}
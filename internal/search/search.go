@@ -0,0 +1,123 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits the text into lowercase alphanumeric tokens
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Score returns the relevance of the document tokens for the query tokens
+// - an exact token match scores 1, a prefix match 0.8 and a match within the allowed typos less
+// - the result is the average of the best score of every query token, 0 means no match
+func Score(query []string, document []string) (score float64) {
+	if len(query) == 0 {
+		return
+	}
+
+	for _, q := range query {
+		best := 0.0
+		for _, d := range document {
+			if s := tokenScore(q, d); s > best {
+				best = s
+			}
+		}
+		score += best
+	}
+	score /= float64(len(query))
+	return
+}
+
+// Match is a document that matched a query with its score
+type Match struct {
+	// Index is the position of the document in the ranked slice
+	Index int
+	// Score is the relevance of the document
+	Score float64
+}
+
+// Rank scores the documents against the query and returns the matches ordered by relevance
+func Rank(query string, documents []string) (matches []Match) {
+	q := Tokenize(query)
+	for i, document := range documents {
+		score := Score(q, Tokenize(document))
+		if score > 0 {
+			matches = append(matches, Match{Index: i, Score: score})
+		}
+	}
+
+	// order by score, keeping the original order on ties
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return
+}
+
+// tokenScore returns the score of a single query token against a document token
+func tokenScore(q, d string) float64 {
+	switch {
+	case q == d:
+		return 1
+	case strings.HasPrefix(d, q):
+		return 0.8
+	}
+
+	// typo tolerance depends on the length of the query token
+	maxEdits := allowedEdits(q)
+	if maxEdits == 0 {
+		return 0
+	}
+
+	// compare against the whole token and against a prefix of the same length
+	distance := levenshtein(q, d)
+	if len([]rune(d)) > len([]rune(q)) {
+		if prefixDistance := levenshtein(q, string([]rune(d)[:len([]rune(q))])); prefixDistance < distance {
+			distance = prefixDistance
+		}
+	}
+	if distance > maxEdits {
+		return 0
+	}
+	return 0.6 - 0.2*float64(distance-1)
+}
+
+// allowedEdits returns how many typos are tolerated for a token
+func allowedEdits(token string) int {
+	switch n := len([]rune(token)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
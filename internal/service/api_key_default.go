@@ -0,0 +1,177 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"storage/internal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts every key, so leaked keys are easy to recognize
+const APIKeyPrefix = "sk"
+
+// APIKeyTouchInterval is how often the last use of a key is recorded, so requests do not write on every call
+const APIKeyTouchInterval = time.Minute
+
+// NewAPIKeyDefault creates a new instance of the api key service
+func NewAPIKeyDefault(rp internal.APIKeyRepository) *APIKeyDefault {
+	return &APIKeyDefault{
+		rp: rp,
+	}
+}
+
+// APIKeyDefault is the default implementation of the api key service
+// - a key is sk_<prefix>_<secret>, the prefix looks the key up and the whole key is compared by its SHA-256 hash
+type APIKeyDefault struct {
+	// rp is the repository used by the service
+	rp internal.APIKeyRepository
+}
+
+// FindAll returns all the keys
+func (s *APIKeyDefault) FindAll() (keys []internal.APIKey, err error) {
+
+	// get the keys from the repository
+	keys, err = s.rp.FindAll()
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// Issue creates a key for the client with the roles, the secret is returned only once
+func (s *APIKeyDefault) Issue(name string, roles []string) (key internal.APIKey, secret string, err error) {
+
+	// validate the name and roles
+	if strings.TrimSpace(name) == "" {
+		err = fmt.Errorf("%w: name", internal.ErrAPIKeyServiceInvalidField)
+		return
+	}
+	if len(roles) == 0 {
+		err = fmt.Errorf("%w: roles", internal.ErrAPIKeyServiceInvalidField)
+		return
+	}
+	for _, role := range roles {
+		if role == "" || strings.ContainsAny(role, ", ") {
+			err = fmt.Errorf("%w: roles", internal.ErrAPIKeyServiceInvalidField)
+			return
+		}
+	}
+
+	// generate the prefix and the secret
+	prefix, err := randomHex(4)
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	random, err := randomHex(24)
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	secret = APIKeyPrefix + "_" + prefix + "_" + random
+
+	// create the key in the repository
+	key = internal.APIKey{
+		Name:      strings.TrimSpace(name),
+		Prefix:    prefix,
+		Hash:      hashAPIKey(secret),
+		Roles:     roles,
+		CreatedAt: time.Now().UTC(),
+	}
+	err = s.rp.Create(&key)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		secret = ""
+		return
+	}
+	return
+}
+
+// Revoke revokes a key
+func (s *APIKeyDefault) Revoke(id int) (err error) {
+
+	// revoke the key in the repository
+	err = s.rp.Revoke(id, time.Now().UTC())
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrAPIKeyRepositoryNotFound):
+			err = internal.ErrAPIKeyRepositoryNotFound
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+	return
+}
+
+// Authenticate returns the principal of an active key
+func (s *APIKeyDefault) Authenticate(secret string) (principal internal.Principal, err error) {
+
+	// split the key in its parts
+	parts := strings.Split(secret, "_")
+	if len(parts) != 3 || parts[0] != APIKeyPrefix {
+		err = internal.ErrUnauthenticated
+		return
+	}
+
+	// get the key from the repository
+	key, err := s.rp.FindByPrefix(parts[1])
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrAPIKeyRepositoryNotFound):
+			err = internal.ErrUnauthenticated
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+
+	// compare the hashes in constant time and check the key is active
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(secret)), []byte(key.Hash)) != 1 || key.RevokedAt != nil {
+		err = internal.ErrUnauthenticated
+		return
+	}
+
+	// record the use at most once per interval, a failure does not prevent the request
+	now := time.Now().UTC()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= APIKeyTouchInterval {
+		_ = s.rp.Touch(key.ID, now)
+	}
+
+	principal = internal.Principal{
+		Subject: "api-key:" + strconv.Itoa(key.ID),
+		Name:    key.Name,
+		Method:  "api_key",
+		Roles:   key.Roles,
+	}
+	return
+}
+
+// hashAPIKey returns the hex encoded SHA-256 hash of the key
+// - keys are random, so a fast hash without salt is enough to protect them at rest
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"storage/internal"
+)
+
+const (
+	// DefaultAuditPageSize is the amount of audit entries per page when none is given
+	DefaultAuditPageSize = 50
+	// MaxAuditPageSize is the maximum amount of audit entries per page
+	MaxAuditPageSize = 500
+)
+
+// NewAuditDefault creates a new instance of the audit service
+func NewAuditDefault(rp internal.AuditRepository) *AuditDefault {
+	return &AuditDefault{
+		rp: rp,
+	}
+}
+
+// AuditDefault is the default implementation of the audit service
+type AuditDefault struct {
+	// rp is the repository used by the service
+	rp internal.AuditRepository
+}
+
+// Find returns the audit entries matching the query, the newest first
+func (s *AuditDefault) Find(query *internal.AuditQuery) (entries []internal.AuditEntry, total int, err error) {
+
+	// validate the query
+	switch query.Action {
+	case "", internal.AuditActionCreate, internal.AuditActionUpdate, internal.AuditActionDelete:
+	default:
+		err = fmt.Errorf("%w: action", internal.ErrAuditServiceInvalidField)
+		return
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		err = fmt.Errorf("%w: from must be before to", internal.ErrAuditServiceInvalidField)
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = DefaultAuditPageSize
+	}
+	if query.PageSize > MaxAuditPageSize {
+		query.PageSize = MaxAuditPageSize
+	}
+
+	// get the entries from the repository
+	entries, total, err = s.rp.Find(*query)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
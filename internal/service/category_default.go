@@ -0,0 +1,232 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"storage/internal"
+)
+
+// NewCategoryDefault creates a new instance of the category service
+func NewCategoryDefault(rp internal.CategoryRepository) *CategoryDefault {
+	return &CategoryDefault{
+		rp: rp,
+	}
+}
+
+// CategoryDefault is the default implementation of the category service
+type CategoryDefault struct {
+	// rp is the repository used by the service
+	rp internal.CategoryRepository
+}
+
+// FindAll returns all categories
+func (s *CategoryDefault) FindAll() (categories []internal.Category, err error) {
+
+	// get the categories from the repository
+	categories, err = s.rp.FindAll()
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// FindByID returns a category
+func (s *CategoryDefault) FindByID(id int) (category internal.Category, err error) {
+
+	// get the category from the repository
+	category, err = s.rp.FindByID(id)
+
+	// check for errors
+	if err != nil {
+		err = categoryError(err)
+		return
+	}
+	return
+}
+
+// Tree returns the root categories with their descendants and amount of products
+func (s *CategoryDefault) Tree() (roots []internal.CategoryNode, err error) {
+
+	// get the categories and counts from the repository
+	categories, err := s.rp.FindAll()
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	counts, err := s.rp.CountProducts()
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	countsByID := make(map[int]internal.CategoryCount, len(counts))
+	for _, count := range counts {
+		countsByID[count.CategoryID] = count
+	}
+
+	// group the categories by parent
+	children := make(map[int][]internal.Category)
+	for _, category := range categories {
+		children[category.ParentID] = append(children[category.ParentID], category)
+	}
+
+	// build the tree from the roots
+	var build func(parentID int) []internal.CategoryNode
+	build = func(parentID int) (nodes []internal.CategoryNode) {
+		for _, category := range children[parentID] {
+			nodes = append(nodes, internal.CategoryNode{
+				Category:      category,
+				Products:      countsByID[category.ID].Products,
+				TotalProducts: countsByID[category.ID].TotalProducts,
+				Children:      build(category.ID),
+			})
+		}
+		return
+	}
+	roots = build(0)
+	return
+}
+
+// Create creates a new category
+func (s *CategoryDefault) Create(category *internal.Category) (err error) {
+
+	// validate the category fields
+	err = s.validateCategoryFields(category)
+	if err != nil {
+		return
+	}
+
+	// create the category in the repository
+	err = s.rp.Create(category)
+
+	// check for errors
+	if err != nil {
+		err = categoryError(err)
+		return
+	}
+	return
+}
+
+// Update updates a category
+func (s *CategoryDefault) Update(category *internal.Category) (err error) {
+
+	// validate the category fields
+	err = s.validateCategoryFields(category)
+	if err != nil {
+		return
+	}
+
+	// update the category in the repository
+	err = s.rp.Update(category)
+
+	// check for errors
+	if err != nil {
+		err = categoryError(err)
+		return
+	}
+	return
+}
+
+// Delete deletes a category without subcategories
+func (s *CategoryDefault) Delete(id int) (err error) {
+
+	// delete the category from the repository
+	err = s.rp.Delete(id)
+
+	// check for errors
+	if err != nil {
+		err = categoryError(err)
+		return
+	}
+	return
+}
+
+// FindByProductID returns the categories of a product
+func (s *CategoryDefault) FindByProductID(productID int) (categories []internal.Category, err error) {
+
+	// get the categories from the repository
+	categories, err = s.rp.FindByProductID(productID)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// ContainsProduct reports whether a product belongs to any of the categories or their subcategories
+func (s *CategoryDefault) ContainsProduct(categoryIDs []int, productID int) (found bool, err error) {
+
+	// check the membership in the repository
+	found, err = s.rp.ContainsProduct(categoryIDs, productID)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// SetProductCategories replaces the categories of a product
+func (s *CategoryDefault) SetProductCategories(productID int, categoryIDs []int) (err error) {
+
+	// remove the duplicated ids
+	unique := make([]int, 0, len(categoryIDs))
+	seen := make(map[int]bool, len(categoryIDs))
+	for _, id := range categoryIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+
+	// set the categories in the repository
+	err = s.rp.SetProductCategories(productID, unique)
+
+	// check for errors
+	if err != nil {
+		err = categoryError(err)
+		return
+	}
+	return
+}
+
+// validateCategoryFields validates the category fields
+// - the repository checks the parent exists and does not create a cycle, with the ancestors locked
+func (s *CategoryDefault) validateCategoryFields(category *internal.Category) (err error) {
+
+	// validate the category name
+	if category.Name == "" {
+		return fmt.Errorf("%w: name", internal.ErrCategoryServiceInvalidField)
+	}
+
+	// a category can not be its own parent
+	if category.ParentID != 0 && category.ParentID == category.ID {
+		return fmt.Errorf("%w: parent_id", internal.ErrCategoryServiceInvalidField)
+	}
+
+	return nil
+}
+
+// categoryError maps the repository errors of the categories
+func categoryError(err error) error {
+	switch {
+	case errors.Is(err, internal.ErrCategoryRepositoryNotFound):
+		return internal.ErrCategoryRepositoryNotFound
+	case errors.Is(err, internal.ErrCategoryRepositoryDuplicated):
+		return internal.ErrCategoryRepositoryDuplicated
+	case errors.Is(err, internal.ErrCategoryRepositoryHasChildren):
+		return internal.ErrCategoryRepositoryHasChildren
+	case errors.Is(err, internal.ErrCategoryRepositoryInvalidParent):
+		return fmt.Errorf("%w: parent_id", internal.ErrCategoryServiceInvalidField)
+	case errors.Is(err, internal.ErrProductRepositoryNotFound):
+		return internal.ErrProductRepositoryNotFound
+	default:
+		return internal.ErrInternalServerError
+	}
+}
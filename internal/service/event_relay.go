@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"storage/internal"
+	"time"
+)
+
+const (
+	// RelayBatchSize is the maximum amount of events relayed by a run
+	RelayBatchSize = 100
+	// PruneBatchSize is the maximum amount of events deleted at once by a prune
+	PruneBatchSize = 1000
+	// relayMinBackoff is the wait before the first retry of a failed event, doubled on every failure
+	relayMinBackoff = 5 * time.Second
+	// relayMaxBackoff is the maximum wait before retrying a failed event
+	relayMaxBackoff = time.Hour
+)
+
+// NewEventRelay creates a new instance of the outbox relay delivering to the sinks
+func NewEventRelay(rp internal.OutboxRepository, sinks ...internal.EventSink) *EventRelay {
+	return &EventRelay{
+		rp:    rp,
+		sinks: sinks,
+	}
+}
+
+// EventRelay is the default implementation of the outbox relay
+// - an event is dispatched once every sink accepted it, a failure retries it in all of them (at least once delivery)
+// - the events of a product are delivered in order: after a failure the next ones of the product wait for the retry
+type EventRelay struct {
+	// rp is the repository used by the relay
+	rp internal.OutboxRepository
+	// sinks are the destinations of the events
+	sinks []internal.EventSink
+}
+
+// AddSink adds a destination of the events, it must be called before relaying
+func (s *EventRelay) AddSink(sink internal.EventSink) {
+	s.sinks = append(s.sinks, sink)
+}
+
+// Relay delivers the pending events to the sinks
+func (s *EventRelay) Relay(ctx context.Context) (dispatched int, err error) {
+
+	// get the pending events from the repository
+	now := time.Now().UTC()
+	events, err := s.rp.FindPending(now, RelayBatchSize)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+
+	blocked := make(map[int]bool)
+	for _, event := range events {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			return
+		}
+
+		// keep the order of the events of a product
+		if blocked[event.ProductID] {
+			continue
+		}
+
+		// deliver to every sink
+		if failure := s.publish(ctx, event); failure != nil {
+			blocked[event.ProductID] = true
+			err = s.rp.MarkFailed(event.ID, failure.Error(), now.Add(relayBackoff(event.Attempts+1)))
+			if err != nil {
+				err = internal.ErrInternalServerError
+				return
+			}
+			continue
+		}
+
+		err = s.rp.MarkDispatched(event.ID, time.Now().UTC())
+		if err != nil {
+			err = internal.ErrInternalServerError
+			return
+		}
+		dispatched++
+	}
+	return
+}
+
+// Prune deletes the events dispatched longer than retention ago, in batches until none is left
+// - the streams resuming from an event deleted are told to reset
+func (s *EventRelay) Prune(ctx context.Context, retention time.Duration) (deleted int, err error) {
+	before := time.Now().UTC().Add(-retention)
+	for {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			return
+		}
+
+		// delete a batch of events from the repository
+		var n int
+		n, err = s.rp.DeleteDispatchedBefore(before, PruneBatchSize)
+
+		// check for errors
+		if err != nil {
+			err = internal.ErrInternalServerError
+			return
+		}
+		deleted += n
+		if n < PruneBatchSize {
+			return
+		}
+	}
+}
+
+// publish delivers the event to all the sinks, returning the first failure
+func (s *EventRelay) publish(ctx context.Context, event internal.Event) (err error) {
+	for _, sink := range s.sinks {
+		err = sink.Publish(ctx, event)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", sink.Name(), err)
+			return
+		}
+	}
+	return
+}
+
+// relayBackoff returns the wait before the attempt-th retry of an event
+func relayBackoff(attempt int) time.Duration {
+	backoff := relayMinBackoff
+	for i := 1; i < attempt && backoff < relayMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, relayMaxBackoff)
+}
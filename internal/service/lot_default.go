@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"storage/internal"
+	"time"
+)
+
+// NewLotDefault creates a new instance of the lot service
+func NewLotDefault(rp internal.LotRepository) *LotDefault {
+	return &LotDefault{
+		rp: rp,
+	}
+}
+
+// LotDefault is the default implementation of the lot service
+type LotDefault struct {
+	// rp is the repository used by the service
+	rp internal.LotRepository
+}
+
+// FindByProductID returns the lots of a product
+func (s *LotDefault) FindByProductID(productID int, includeDepleted bool) (lots []internal.Lot, err error) {
+
+	// get the lots from the repository
+	lots, err = s.rp.FindByProductID(productID, includeDepleted)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// Receive records a lot received for a product
+// - the received date defaults to today
+func (s *LotDefault) Receive(receipt *internal.LotReceipt) (err error) {
+
+	// validate the lot fields
+	now := time.Now().UTC()
+	if receipt.Lot.ReceivedDate == "" {
+		receipt.Lot.ReceivedDate = now.Format(expirationLayout)
+	}
+	err = validateLotFields(receipt)
+
+	// check for errors
+	if err != nil {
+		return
+	}
+	receipt.Lot.ReceivedQuantity = receipt.Lot.Quantity
+	receipt.CreatedAt = now
+
+	// receive the lot in the repository
+	err = s.rp.Receive(receipt)
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrProductRepositoryNotFound):
+			err = internal.ErrProductRepositoryNotFound
+		case errors.Is(err, internal.ErrLotRepositoryDuplicated):
+			err = internal.ErrLotRepositoryDuplicated
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+	return
+}
+
+// StockReport returns the lots in stock, only the ones expiring before the date when given
+func (s *LotDefault) StockReport(before string) (stock []internal.LotStock, err error) {
+
+	// validate the date
+	if before != "" {
+		if _, err = time.Parse(expirationLayout, before); err != nil {
+			return nil, fmt.Errorf("%w: before", internal.ErrLotServiceInvalidField)
+		}
+	}
+
+	// get the report from the repository
+	stock, err = s.rp.StockReport(before)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// validateLotFields validates the fields of the lot received
+func validateLotFields(receipt *internal.LotReceipt) (err error) {
+
+	// validate the product id
+	if receipt.Lot.ProductID <= 0 {
+		return fmt.Errorf("%w: product_id", internal.ErrLotServiceInvalidField)
+	}
+
+	// validate the lot number
+	if receipt.Lot.LotNumber == "" {
+		return fmt.Errorf("%w: lot_number", internal.ErrLotServiceInvalidField)
+	}
+
+	// validate the quantity
+	if receipt.Lot.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity", internal.ErrLotServiceInvalidField)
+	}
+
+	// validate the dates
+	expiration, err := time.Parse(expirationLayout, receipt.Lot.Expiration)
+	if err != nil {
+		return fmt.Errorf("%w: expiration", internal.ErrLotServiceInvalidField)
+	}
+	received, err := time.Parse(expirationLayout, receipt.Lot.ReceivedDate)
+	if err != nil {
+		return fmt.Errorf("%w: received_date", internal.ErrLotServiceInvalidField)
+	}
+	if expiration.Before(received) {
+		return fmt.Errorf("%w: expiration before received_date", internal.ErrLotServiceInvalidField)
+	}
+
+	// validate the actor
+	if receipt.Actor == "" {
+		return fmt.Errorf("%w: actor", internal.ErrLotServiceInvalidField)
+	}
+
+	return nil
+}
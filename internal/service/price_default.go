@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"storage/internal"
+	"time"
+)
+
+// NewPriceDefault creates a new instance of the price service
+func NewPriceDefault(rp internal.PriceRepository) *PriceDefault {
+	return &PriceDefault{
+		rp: rp,
+	}
+}
+
+// PriceDefault is the default implementation of the price service
+type PriceDefault struct {
+	// rp is the repository used by the service
+	rp internal.PriceRepository
+}
+
+// FindByProductID returns the price history of a product
+func (s *PriceDefault) FindByProductID(productID int) (changes []internal.PriceChange, err error) {
+
+	// get the changes from the repository
+	changes, err = s.rp.FindByProductID(productID)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// FindAsOf returns the price of a product at the given time
+func (s *PriceDefault) FindAsOf(productID int, at time.Time) (change internal.PriceChange, err error) {
+
+	// get the change from the repository
+	change, err = s.rp.FindAsOf(productID, at)
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrPriceRepositoryNotFound):
+			err = internal.ErrPriceRepositoryNotFound
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+	return
+}
+
+// Schedule records a price change taking effect in the future
+func (s *PriceDefault) Schedule(change *internal.PriceChange) (err error) {
+
+	// validate the change fields
+	now := time.Now().UTC()
+	if change.Price <= 0 {
+		return fmt.Errorf("%w: price", internal.ErrPriceServiceInvalidField)
+	}
+	if !change.EffectiveFrom.After(now) {
+		return fmt.Errorf("%w: effective_from must be in the future", internal.ErrPriceServiceInvalidField)
+	}
+	change.EffectiveFrom = change.EffectiveFrom.UTC()
+	change.CreatedAt = now
+
+	// schedule the change in the repository
+	err = s.rp.Schedule(change)
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrProductRepositoryNotFound):
+			err = internal.ErrProductRepositoryNotFound
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+	return
+}
+
+// ApplyDue sets on the products the scheduled prices already in effect
+func (s *PriceDefault) ApplyDue() (applied int, err error) {
+
+	// apply the changes in the repository
+	applied, err = s.rp.ApplyDue(time.Now().UTC())
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// FindProductIDsChangedSince returns the ids of the products whose price changed since the given time
+func (s *PriceDefault) FindProductIDsChangedSince(since time.Time) (ids []int, err error) {
+
+	// get the ids from the repository
+	ids, err = s.rp.FindProductIDsChangedSince(since)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
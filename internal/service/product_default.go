@@ -4,12 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"storage/internal"
+	"storage/internal/barcode"
+	"strings"
+	"time"
 )
 
+// expirationLayout is the layout of the expiration dates
+const expirationLayout = "2006-01-02"
+
 // NewProductDefault creates a new instance of the product service
 func NewProductDefault(rp internal.ProductRepository) *ProductDefault {
 	return &ProductDefault{
-		rp: rp,
+		rp:    rp,
+		codes: barcode.NewValidator(barcode.KnownFormats...),
 	}
 }
 
@@ -17,6 +24,35 @@ func NewProductDefault(rp internal.ProductRepository) *ProductDefault {
 type ProductDefault struct {
 	// rp is the repository used by the service
 	rp internal.ProductRepository
+	// blockExpired rejects publishing products whose expiration date has passed
+	blockExpired bool
+	// codes validates the code values and normalizes them to their canonical form
+	codes *barcode.Validator
+}
+
+// SetBlockExpired enables or disables rejecting the publication of expired products
+func (s *ProductDefault) SetBlockExpired(block bool) {
+	s.blockExpired = block
+}
+
+// SetCodeFormats sets the formats accepted for the code values, all the known formats by default
+func (s *ProductDefault) SetCodeFormats(formats ...barcode.Format) {
+	s.codes = barcode.NewValidator(formats...)
+}
+
+// WithActor returns a copy of the service whose mutations are audited as performed by the actor
+func (s *ProductDefault) WithActor(actor internal.Actor) internal.ProductService {
+	copy := *s
+	copy.rp = s.rp.WithActor(actor)
+	return &copy
+}
+
+// WithFixedPrice returns a copy of the service whose updates can not change the price of an existing product
+// - the price is compared with the row locked by the update, so a concurrent change can not be overwritten
+func (s *ProductDefault) WithFixedPrice() internal.ProductService {
+	copy := *s
+	copy.rp = s.rp.WithFixedPrice()
+	return &copy
 }
 
 // FindAll returns all products
@@ -35,6 +71,35 @@ func (s *ProductDefault) FindAll() (products []internal.Product, err error) {
 
 }
 
+// FindByCategoryID returns the products of a category, including its subcategories
+func (s *ProductDefault) FindByCategoryID(categoryID int) (products []internal.Product, err error) {
+
+	// get the products from the repository
+	products, err = s.rp.FindByCategoryID(categoryID)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	// return the products
+	return
+}
+
+// CountByCategoryID returns the amount of products of a category, including its subcategories
+func (s *ProductDefault) CountByCategoryID(categoryID int) (count int, err error) {
+
+	// count the products in the repository
+	count, err = s.rp.CountByCategoryID(categoryID)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
 // FindByID returns a product
 func (s *ProductDefault) FindByID(id int) (product internal.Product, err error) {
 
@@ -55,6 +120,225 @@ func (s *ProductDefault) FindByID(id int) (product internal.Product, err error)
 	return
 }
 
+// FindByCodeValue returns a product by its code value
+func (s *ProductDefault) FindByCodeValue(codeValue string) (product internal.Product, err error) {
+
+	// get the product from the repository
+	product, err = s.rp.FindByCodeValue(s.lookupCode(codeValue))
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrProductRepositoryNotFound):
+			err = internal.ErrProductRepositoryNotFound
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+	// return the product
+	return
+}
+
+// FindByIDs returns the products with the given ids
+func (s *ProductDefault) FindByIDs(ids []int) (products []internal.Product, err error) {
+
+	// get the products from the repository
+	products, err = s.rp.FindByIDs(ids)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	// return the products
+	return
+}
+
+// MaxBatchCodeValues is the maximum amount of code values accepted by FindByCodeValues
+const MaxBatchCodeValues = 100
+
+// FindByCodeValues returns the products matching the given code values
+func (s *ProductDefault) FindByCodeValues(codeValues []string) (products []internal.Product, err error) {
+
+	// validate the amount of codes
+	if len(codeValues) > MaxBatchCodeValues {
+		err = fmt.Errorf("%w: max %d", internal.ErrProductServiceTooManyCodes, MaxBatchCodeValues)
+		return
+	}
+
+	// get the products from the repository
+	lookup := make([]string, 0, len(codeValues))
+	for _, codeValue := range codeValues {
+		lookup = append(lookup, s.lookupCode(codeValue))
+	}
+	products, err = s.rp.FindByCodeValues(lookup)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	// return the products
+	return
+}
+
+// NormalizeCode validates a code against the accepted formats and returns its canonical form
+func (s *ProductDefault) NormalizeCode(code string) (productCode internal.ProductCode, err error) {
+
+	// normalize the code
+	normalized, err := s.codes.Normalize(code)
+
+	// check for errors
+	if err != nil {
+		err = fmt.Errorf("%w: code_value: %w", internal.ErrProductServiceInvalidField, err)
+		return
+	}
+	productCode = internal.ProductCode{
+		Format: string(normalized.Format),
+		Value:  normalized.Value,
+	}
+	return
+}
+
+// lookupCode returns the canonical form of a code to look it up, or the code as given when it is not valid
+func (s *ProductDefault) lookupCode(code string) string {
+	normalized, err := s.codes.Normalize(code)
+	if err != nil {
+		return code
+	}
+	return normalized.Value
+}
+
+// normalizeCodeValue validates the code value of the product and replaces it by its canonical form
+// - it runs before the uniqueness check of the repository, so the same code in different forms is a duplicate
+func (s *ProductDefault) normalizeCodeValue(product *internal.Product) (err error) {
+	code, err := s.NormalizeCode(product.CodeValue)
+	if err != nil {
+		return
+	}
+	product.CodeValue = code.Value
+	return
+}
+
+const (
+	// DefaultSearchPageSize is the amount of results per page when none is given
+	DefaultSearchPageSize = 20
+	// MaxSearchPageSize is the maximum amount of results per page
+	MaxSearchPageSize = 100
+)
+
+// Search returns the products matching the query ordered by relevance
+func (s *ProductDefault) Search(query *internal.ProductSearchQuery) (results []internal.ProductSearchResult, total int, err error) {
+
+	// validate the query
+	if strings.TrimSpace(query.Text) == "" {
+		err = fmt.Errorf("%w: q", internal.ErrProductServiceInvalidField)
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = DefaultSearchPageSize
+	}
+	if query.PageSize > MaxSearchPageSize {
+		query.PageSize = MaxSearchPageSize
+	}
+
+	// search the products in the repository
+	results, total, err = s.rp.Search(*query)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	// return the results
+	return
+}
+
+// FindExpiring returns the products expiring from today within the given amount of days
+func (s *ProductDefault) FindExpiring(days int) (products []internal.Product, err error) {
+
+	// validate the days
+	if days < 0 {
+		err = fmt.Errorf("%w: days", internal.ErrProductServiceInvalidField)
+		return
+	}
+
+	// get the products from the repository
+	today := time.Now()
+	products, err = s.rp.FindByExpirationRange(today.Format(expirationLayout), today.AddDate(0, 0, days).Format(expirationLayout))
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	// return the products
+	return
+}
+
+// FindExpired returns the products whose expiration date is before today
+func (s *ProductDefault) FindExpired() (products []internal.Product, err error) {
+
+	// get the products from the repository
+	products, err = s.rp.FindExpiredBefore(time.Now().Format(expirationLayout))
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	// return the products
+	return
+}
+
+// ExpirationSummary returns the products aggregated by week of expiration
+func (s *ProductDefault) ExpirationSummary() (weeks []internal.ProductExpirationWeek, err error) {
+
+	// get the summary from the repository
+	weeks, err = s.rp.ExpirationSummary()
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	// return the summary
+	return
+}
+
+// CatalogVersion returns the version of the whole catalog
+func (s *ProductDefault) CatalogVersion() (version internal.ProductCatalogVersion, err error) {
+
+	// get the version from the repository
+	version, err = s.rp.CatalogVersion()
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	// return the version
+	return
+}
+
+// UnpublishExpired unpublishes the products whose expiration date is before today
+func (s *ProductDefault) UnpublishExpired() (affected int, err error) {
+
+	// unpublish the products in the repository
+	affected, err = s.rp.UnpublishExpiredBefore(time.Now().Format(expirationLayout))
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
 // Delete deletes a product
 func (s *ProductDefault) Delete(id int) (err error) {
 
@@ -86,6 +370,18 @@ func (s *ProductDefault) Create(product *internal.Product) (err error) {
 		return err
 	}
 
+	// normalize the code value
+	err = s.normalizeCodeValue(product)
+	if err != nil {
+		return err
+	}
+
+	// validate the product is not published once expired
+	err = s.validateNotExpired(product)
+	if err != nil {
+		return err
+	}
+
 	// create the product in the repository
 	err = s.rp.Create(product)
 
@@ -106,6 +402,24 @@ func (s *ProductDefault) Create(product *internal.Product) (err error) {
 // Update updates a product
 func (p *ProductDefault) Update(product *internal.Product) (err error) {
 
+	// validate the stock is not negative
+	err = validateQuantity(product)
+	if err != nil {
+		return
+	}
+
+	// normalize the code value
+	err = p.normalizeCodeValue(product)
+	if err != nil {
+		return
+	}
+
+	// validate the product is not published once expired
+	err = p.validateNotExpired(product)
+	if err != nil {
+		return
+	}
+
 	err = p.rp.Update(product)
 
 	// check for errors
@@ -113,12 +427,111 @@ func (p *ProductDefault) Update(product *internal.Product) (err error) {
 		switch {
 		case errors.Is(err, internal.ErrProductRepositoryDuplicated):
 			err = internal.ErrProductRepositoryDuplicated
+		case errors.Is(err, internal.ErrProductRepositoryNotFound):
+			err = internal.ErrProductRepositoryNotFound
+		case errors.Is(err, internal.ErrProductRepositoryReservedStock):
+			err = internal.ErrProductRepositoryReservedStock
+		case errors.Is(err, internal.ErrStockMovementRepositoryAllocatedStock):
+			err = internal.ErrStockMovementRepositoryAllocatedStock
+		case errors.Is(err, internal.ErrProductRepositoryPriceForbidden):
+			err = internal.ErrProductRepositoryPriceForbidden
+		default:
+			err = internal.ErrInternalServerError
+
+		}
+		return
+	}
+	return
+}
+
+// Patch applies the changes to the current version of a product and updates it
+// - the changes are applied and validated in the transaction of the update, on the row it locks
+func (p *ProductDefault) Patch(id int, apply func(product *internal.Product)) (product internal.Product, err error) {
+
+	// update the product in the repository
+	product, err = p.rp.Patch(id, func(product *internal.Product) (err error) {
+		apply(product)
+
+		// validate the stock is not negative
+		err = validateQuantity(product)
+		if err != nil {
+			return
+		}
+
+		// normalize the code value
+		err = p.normalizeCodeValue(product)
+		if err != nil {
+			return
+		}
+
+		// validate the product is not published once expired
+		err = p.validateNotExpired(product)
+		return
+	})
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrProductServiceInvalidField), errors.Is(err, internal.ErrProductServiceExpired):
+		case errors.Is(err, internal.ErrProductRepositoryDuplicated):
+			err = internal.ErrProductRepositoryDuplicated
+		case errors.Is(err, internal.ErrProductRepositoryNotFound):
+			err = internal.ErrProductRepositoryNotFound
+		case errors.Is(err, internal.ErrProductRepositoryReservedStock):
+			err = internal.ErrProductRepositoryReservedStock
+		case errors.Is(err, internal.ErrStockMovementRepositoryAllocatedStock):
+			err = internal.ErrStockMovementRepositoryAllocatedStock
+		case errors.Is(err, internal.ErrProductRepositoryPriceForbidden):
+			err = internal.ErrProductRepositoryPriceForbidden
 		default:
 			err = internal.ErrInternalServerError
+		}
+		return
+	}
+	return
+}
+
+// Upsert creates or updates a product by its code value
+func (s *ProductDefault) Upsert(product *internal.Product) (created bool, err error) {
+
+	// validate the product fields
+	err = validateProductFields(product)
+
+	// check for errors
+	if err != nil {
+		return
+	}
+
+	// normalize the code value
+	err = s.normalizeCodeValue(product)
+	if err != nil {
+		return
+	}
+
+	// validate the product is not published once expired
+	err = s.validateNotExpired(product)
+	if err != nil {
+		return
+	}
+
+	// upsert the product in the repository
+	created, err = s.rp.Upsert(product)
 
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrProductRepositoryReservedStock):
+			err = internal.ErrProductRepositoryReservedStock
+		case errors.Is(err, internal.ErrStockMovementRepositoryAllocatedStock):
+			err = internal.ErrStockMovementRepositoryAllocatedStock
+		case errors.Is(err, internal.ErrProductRepositoryPriceForbidden):
+			err = internal.ErrProductRepositoryPriceForbidden
+		default:
+			err = internal.ErrInternalServerError
 		}
 		return
 	}
+	// return the product
 	return
 }
 
@@ -134,6 +547,10 @@ func validateProductFields(product *internal.Product) (err error) {
 	if product.Quantity == 0 {
 		return fmt.Errorf("%w: quantity", internal.ErrProductServiceInvalidField)
 	}
+	err = validateQuantity(product)
+	if err != nil {
+		return
+	}
 
 	// validate the product code_value
 	if product.CodeValue == "" {
@@ -157,3 +574,35 @@ func validateProductFields(product *internal.Product) (err error) {
 
 	return nil
 }
+
+// validateQuantity validates the stock of the product is not negative, as the stock movements keep it
+func validateQuantity(product *internal.Product) (err error) {
+	if product.Quantity < 0 {
+		return fmt.Errorf("%w: quantity must not be negative", internal.ErrProductServiceInvalidField)
+	}
+	return nil
+}
+
+// validateNotExpired validates a published product is not expired, when blocking expired products is enabled
+func (s *ProductDefault) validateNotExpired(product *internal.Product) (err error) {
+	if !s.blockExpired || product.IsPublished != "1" {
+		return nil
+	}
+
+	// the expiration may come from the database with a time part
+	expiration, err := time.Parse(expirationLayout, product.Expiration)
+	if err != nil {
+		expiration, err = time.Parse(time.RFC3339, product.Expiration)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: expiration", internal.ErrProductServiceInvalidField)
+	}
+
+	// compare against the start of today
+	today, _ := time.Parse(expirationLayout, time.Now().Format(expirationLayout))
+	if expiration.Before(today) {
+		return fmt.Errorf("%w: expired on %s", internal.ErrProductServiceExpired, expiration.Format(expirationLayout))
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"storage/internal"
+	"time"
+)
+
+const (
+	// DefaultReservationTTL is the time units are held when no ttl is given
+	DefaultReservationTTL = 15 * time.Minute
+	// MaxReservationTTL is the maximum time units can be held
+	MaxReservationTTL = 24 * time.Hour
+)
+
+// NewReservationDefault creates a new instance of the reservation service
+func NewReservationDefault(rp internal.ReservationRepository) *ReservationDefault {
+	return &ReservationDefault{
+		rp: rp,
+	}
+}
+
+// ReservationDefault is the default implementation of the reservation service
+type ReservationDefault struct {
+	// rp is the repository used by the service
+	rp internal.ReservationRepository
+}
+
+// Create holds units of a product during ttl, DefaultReservationTTL if zero
+func (s *ReservationDefault) Create(reservation *internal.Reservation, ttl time.Duration) (err error) {
+
+	// validate the reservation fields
+	if reservation.ProductID <= 0 {
+		return fmt.Errorf("%w: product_id", internal.ErrReservationServiceInvalidField)
+	}
+	if reservation.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity", internal.ErrReservationServiceInvalidField)
+	}
+	if ttl == 0 {
+		ttl = DefaultReservationTTL
+	}
+	if ttl < 0 || ttl > MaxReservationTTL {
+		return fmt.Errorf("%w: ttl", internal.ErrReservationServiceInvalidField)
+	}
+
+	// set the state of the reservation
+	now := time.Now().UTC()
+	reservation.Status = internal.ReservationActive
+	reservation.CreatedAt = now
+	reservation.ExpiresAt = now.Add(ttl)
+
+	// create the reservation in the repository
+	err = s.rp.Create(reservation, now)
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrProductRepositoryNotFound):
+			err = internal.ErrProductRepositoryNotFound
+		case errors.Is(err, internal.ErrReservationRepositoryInsufficientStock):
+			err = internal.ErrReservationRepositoryInsufficientStock
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+	return
+}
+
+// FindByID returns a reservation
+func (s *ReservationDefault) FindByID(id int) (reservation internal.Reservation, err error) {
+
+	// get the reservation from the repository
+	reservation, err = s.rp.FindByID(id)
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrReservationRepositoryNotFound):
+			err = internal.ErrReservationRepositoryNotFound
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+
+	// report active reservations past their ttl as expired
+	if reservation.Status == internal.ReservationActive && !reservation.ExpiresAt.After(time.Now().UTC()) {
+		reservation.Status = internal.ReservationExpired
+	}
+	return
+}
+
+// Confirm takes the reserved units from the product quantity
+func (s *ReservationDefault) Confirm(id int) (reservation internal.Reservation, err error) {
+
+	// confirm the reservation in the repository
+	reservation, err = s.rp.Confirm(id, time.Now().UTC())
+
+	// check for errors
+	if err != nil {
+		err = reservationError(err)
+		return
+	}
+	return
+}
+
+// Release gives back the reserved units
+func (s *ReservationDefault) Release(id int) (reservation internal.Reservation, err error) {
+
+	// release the reservation in the repository
+	reservation, err = s.rp.Release(id, time.Now().UTC())
+
+	// check for errors
+	if err != nil {
+		err = reservationError(err)
+		return
+	}
+	return
+}
+
+// ExpireStale marks the reservations past their ttl as expired
+func (s *ReservationDefault) ExpireStale() (affected int, err error) {
+
+	// expire the reservations in the repository
+	affected, err = s.rp.ExpireBefore(time.Now().UTC())
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// Availability returns the available stock of a product
+func (s *ReservationDefault) Availability(productID int) (availability internal.ProductAvailability, err error) {
+
+	// get the availability from the repository
+	availability, err = s.rp.Availability(productID, time.Now().UTC())
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrProductRepositoryNotFound):
+			err = internal.ErrProductRepositoryNotFound
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+	return
+}
+
+// reservationError maps the repository errors of the reservation transitions
+func reservationError(err error) error {
+	switch {
+	case errors.Is(err, internal.ErrReservationRepositoryNotFound):
+		return internal.ErrReservationRepositoryNotFound
+	case errors.Is(err, internal.ErrReservationRepositoryNotActive):
+		return internal.ErrReservationRepositoryNotActive
+	case errors.Is(err, internal.ErrReservationRepositoryInsufficientStock):
+		return internal.ErrReservationRepositoryInsufficientStock
+	case errors.Is(err, internal.ErrStockMovementRepositoryAllocatedStock):
+		return internal.ErrStockMovementRepositoryAllocatedStock
+	case errors.Is(err, internal.ErrProductRepositoryNotFound):
+		return internal.ErrProductRepositoryNotFound
+	case errors.Is(err, internal.ErrProductRepositoryExpired):
+		return internal.ErrProductRepositoryExpired
+	default:
+		return internal.ErrInternalServerError
+	}
+}
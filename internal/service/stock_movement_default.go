@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"storage/internal"
+	"time"
+)
+
+// NewStockMovementDefault creates a new instance of the stock movement service
+func NewStockMovementDefault(rp internal.StockMovementRepository) *StockMovementDefault {
+	return &StockMovementDefault{
+		rp: rp,
+	}
+}
+
+// StockMovementDefault is the default implementation of the stock movement service
+type StockMovementDefault struct {
+	// rp is the repository used by the service
+	rp internal.StockMovementRepository
+}
+
+// Create records a stock movement
+// - receipts and returns add the quantity, sales and write-offs remove it, adjustments apply it signed
+func (s *StockMovementDefault) Create(movement *internal.StockMovement) (err error) {
+
+	// validate the movement fields and sign the quantity
+	err = validateStockMovementFields(movement)
+
+	// check for errors
+	if err != nil {
+		return
+	}
+	(*movement).CreatedAt = time.Now().UTC()
+
+	// create the movement in the repository
+	err = s.rp.Create(movement)
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrProductRepositoryNotFound):
+			err = internal.ErrProductRepositoryNotFound
+		case errors.Is(err, internal.ErrStockMovementRepositoryNegativeStock):
+			err = internal.ErrStockMovementRepositoryNegativeStock
+		case errors.Is(err, internal.ErrStockMovementRepositoryAllocatedStock):
+			err = internal.ErrStockMovementRepositoryAllocatedStock
+		case errors.Is(err, internal.ErrProductRepositoryExpired):
+			err = internal.ErrProductRepositoryExpired
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+	return
+}
+
+// FindByProductID returns the movements of a product
+func (s *StockMovementDefault) FindByProductID(productID int) (movements []internal.StockMovement, err error) {
+
+	// get the movements from the repository
+	movements, err = s.rp.FindByProductID(productID)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// validateStockMovementFields validates the movement fields and sets the sign of the quantity
+func validateStockMovementFields(movement *internal.StockMovement) (err error) {
+
+	// validate the product id
+	if movement.ProductID <= 0 {
+		return fmt.Errorf("%w: product_id", internal.ErrStockMovementServiceInvalidField)
+	}
+
+	// validate the actor
+	if movement.Actor == "" {
+		return fmt.Errorf("%w: actor", internal.ErrStockMovementServiceInvalidField)
+	}
+
+	// validate the quantity according to the type
+	switch movement.Type {
+	case internal.StockMovementReceipt, internal.StockMovementReturn:
+		if movement.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity", internal.ErrStockMovementServiceInvalidField)
+		}
+	case internal.StockMovementSale, internal.StockMovementWriteOff:
+		if movement.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity", internal.ErrStockMovementServiceInvalidField)
+		}
+		movement.Quantity = -movement.Quantity
+	case internal.StockMovementAdjustment:
+		if movement.Quantity == 0 {
+			return fmt.Errorf("%w: quantity", internal.ErrStockMovementServiceInvalidField)
+		}
+		// adjustments must be explained
+		if movement.Reason == "" {
+			return fmt.Errorf("%w: reason", internal.ErrStockMovementServiceInvalidField)
+		}
+	default:
+		return fmt.Errorf("%w: type", internal.ErrStockMovementServiceInvalidField)
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"storage/internal"
+)
+
+// NewSupplierDefault creates a new instance of the supplier service
+func NewSupplierDefault(rp internal.SupplierRepository) *SupplierDefault {
+	return &SupplierDefault{
+		rp: rp,
+	}
+}
+
+// SupplierDefault is the default implementation of the supplier service
+type SupplierDefault struct {
+	// rp is the repository used by the service
+	rp internal.SupplierRepository
+}
+
+// FindAll returns all suppliers
+func (s *SupplierDefault) FindAll() (suppliers []internal.Supplier, err error) {
+
+	// get the suppliers from the repository
+	suppliers, err = s.rp.FindAll()
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// FindByID returns a supplier
+func (s *SupplierDefault) FindByID(id int) (supplier internal.Supplier, err error) {
+
+	// get the supplier from the repository
+	supplier, err = s.rp.FindByID(id)
+
+	// check for errors
+	if err != nil {
+		err = supplierError(err)
+		return
+	}
+	return
+}
+
+// Create creates a new supplier
+func (s *SupplierDefault) Create(supplier *internal.Supplier) (err error) {
+
+	// validate the supplier fields
+	if supplier.Name == "" {
+		return fmt.Errorf("%w: name", internal.ErrSupplierServiceInvalidField)
+	}
+
+	// create the supplier in the repository
+	err = s.rp.Create(supplier)
+
+	// check for errors
+	if err != nil {
+		err = supplierError(err)
+		return
+	}
+	return
+}
+
+// Update updates a supplier
+func (s *SupplierDefault) Update(supplier *internal.Supplier) (err error) {
+
+	// validate the supplier fields
+	if supplier.Name == "" {
+		return fmt.Errorf("%w: name", internal.ErrSupplierServiceInvalidField)
+	}
+
+	// update the supplier in the repository
+	err = s.rp.Update(supplier)
+
+	// check for errors
+	if err != nil {
+		err = supplierError(err)
+		return
+	}
+	return
+}
+
+// Delete deletes a supplier
+func (s *SupplierDefault) Delete(id int) (err error) {
+
+	// delete the supplier from the repository
+	err = s.rp.Delete(id)
+
+	// check for errors
+	if err != nil {
+		err = supplierError(err)
+		return
+	}
+	return
+}
+
+// FindByProductID returns the suppliers of a product
+func (s *SupplierDefault) FindByProductID(productID int) (links []internal.ProductSupplier, err error) {
+
+	// get the links from the repository
+	links, err = s.rp.FindByProductID(productID)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// SetProductSupplier creates or updates the conditions a supplier sells a product at
+func (s *SupplierDefault) SetProductSupplier(link *internal.ProductSupplier) (err error) {
+
+	// validate the link fields
+	if link.CostPrice <= 0 {
+		return fmt.Errorf("%w: cost_price", internal.ErrSupplierServiceInvalidField)
+	}
+	if link.LeadTimeDays < 0 {
+		return fmt.Errorf("%w: lead_time_days", internal.ErrSupplierServiceInvalidField)
+	}
+
+	// set the link in the repository
+	err = s.rp.SetProductSupplier(link)
+
+	// check for errors
+	if err != nil {
+		err = supplierError(err)
+		return
+	}
+	return
+}
+
+// DeleteProductSupplier deletes the link between a product and a supplier
+func (s *SupplierDefault) DeleteProductSupplier(productID, supplierID int) (err error) {
+
+	// delete the link from the repository
+	err = s.rp.DeleteProductSupplier(productID, supplierID)
+
+	// check for errors
+	if err != nil {
+		err = supplierError(err)
+		return
+	}
+	return
+}
+
+// FindMargins returns the margin of every product with suppliers
+func (s *SupplierDefault) FindMargins() (margins []internal.ProductMargin, err error) {
+
+	// get the prices and costs from the repository
+	margins, err = s.rp.FindMargins()
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+
+	// compute the margins
+	for i := range margins {
+		computeMargin(&margins[i])
+	}
+	return
+}
+
+// FindMarginByProductID returns the margin of a product
+func (s *SupplierDefault) FindMarginByProductID(productID int) (margin internal.ProductMargin, err error) {
+
+	// get the price and cost from the repository
+	margin, err = s.rp.FindMarginByProductID(productID)
+
+	// check for errors
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrProductSupplierRepositoryNotFound):
+			err = internal.ErrProductSupplierRepositoryNotFound
+		default:
+			err = internal.ErrInternalServerError
+		}
+		return
+	}
+
+	// compute the margin
+	computeMargin(&margin)
+	return
+}
+
+// computeMargin sets the margin fields from the price and cost, rounded to cents
+func computeMargin(margin *internal.ProductMargin) {
+	margin.Margin = math.Round((margin.Price-margin.CostPrice)*100) / 100
+	if margin.Price != 0 {
+		margin.MarginPercent = math.Round((margin.Price-margin.CostPrice)/margin.Price*10000) / 100
+	}
+}
+
+// supplierError maps the repository errors of the suppliers
+func supplierError(err error) error {
+	switch {
+	case errors.Is(err, internal.ErrSupplierRepositoryNotFound):
+		return internal.ErrSupplierRepositoryNotFound
+	case errors.Is(err, internal.ErrSupplierRepositoryDuplicated):
+		return internal.ErrSupplierRepositoryDuplicated
+	case errors.Is(err, internal.ErrProductSupplierRepositoryNotFound):
+		return internal.ErrProductSupplierRepositoryNotFound
+	case errors.Is(err, internal.ErrProductRepositoryNotFound):
+		return internal.ErrProductRepositoryNotFound
+	default:
+		return internal.ErrInternalServerError
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"storage/internal"
+)
+
+// NewWarehouseDefault creates a new instance of the warehouse service
+func NewWarehouseDefault(rp internal.WarehouseRepository) *WarehouseDefault {
+	return &WarehouseDefault{
+		rp: rp,
+	}
+}
+
+// WarehouseDefault is the default implementation of the warehouse service
+type WarehouseDefault struct {
+	// rp is the repository used by the service
+	rp internal.WarehouseRepository
+}
+
+// FindAll returns all warehouses
+func (s *WarehouseDefault) FindAll() (warehouses []internal.Warehouse, err error) {
+
+	// get the warehouses from the repository
+	warehouses, err = s.rp.FindAll()
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// FindByID returns a warehouse
+func (s *WarehouseDefault) FindByID(id int) (warehouse internal.Warehouse, err error) {
+
+	// get the warehouse from the repository
+	warehouse, err = s.rp.FindByID(id)
+
+	// check for errors
+	if err != nil {
+		err = warehouseError(err)
+		return
+	}
+	return
+}
+
+// Create creates a new warehouse
+func (s *WarehouseDefault) Create(warehouse *internal.Warehouse) (err error) {
+
+	// validate the warehouse fields
+	if warehouse.Name == "" {
+		return fmt.Errorf("%w: name", internal.ErrWarehouseServiceInvalidField)
+	}
+
+	// create the warehouse in the repository
+	err = s.rp.Create(warehouse)
+
+	// check for errors
+	if err != nil {
+		err = warehouseError(err)
+		return
+	}
+	return
+}
+
+// Update updates a warehouse
+func (s *WarehouseDefault) Update(warehouse *internal.Warehouse) (err error) {
+
+	// validate the warehouse fields
+	if warehouse.Name == "" {
+		return fmt.Errorf("%w: name", internal.ErrWarehouseServiceInvalidField)
+	}
+
+	// update the warehouse in the repository
+	err = s.rp.Update(warehouse)
+
+	// check for errors
+	if err != nil {
+		err = warehouseError(err)
+		return
+	}
+	return
+}
+
+// Delete deletes a warehouse without stock
+func (s *WarehouseDefault) Delete(id int) (err error) {
+
+	// delete the warehouse from the repository
+	err = s.rp.Delete(id)
+
+	// check for errors
+	if err != nil {
+		err = warehouseError(err)
+		return
+	}
+	return
+}
+
+// FindStockByWarehouseID returns the stock of the products in a warehouse
+func (s *WarehouseDefault) FindStockByWarehouseID(warehouseID int) (stocks []internal.WarehouseStock, err error) {
+
+	// get the stock from the repository
+	stocks, err = s.rp.FindStockByWarehouseID(warehouseID)
+
+	// check for errors
+	if err != nil {
+		err = warehouseError(err)
+		return
+	}
+	return
+}
+
+// FindStockByProductID returns the stock of a product per warehouse
+func (s *WarehouseDefault) FindStockByProductID(productID int) (stock internal.ProductStock, err error) {
+
+	// get the stock from the repository
+	stock, err = s.rp.FindStockByProductID(productID)
+
+	// check for errors
+	if err != nil {
+		err = warehouseError(err)
+		return
+	}
+	return
+}
+
+// SetStock sets the stock of a product in a warehouse
+func (s *WarehouseDefault) SetStock(stock *internal.WarehouseStock) (err error) {
+
+	// validate the stock fields
+	if stock.Quantity < 0 {
+		return fmt.Errorf("%w: quantity", internal.ErrWarehouseServiceInvalidField)
+	}
+
+	// set the stock in the repository
+	err = s.rp.SetStock(stock)
+
+	// check for errors
+	if err != nil {
+		err = warehouseError(err)
+		return
+	}
+	return
+}
+
+// Transfer moves units of a product between warehouses
+func (s *WarehouseDefault) Transfer(productID, fromWarehouseID, toWarehouseID, quantity int) (err error) {
+
+	// validate the transfer fields
+	if quantity <= 0 {
+		return fmt.Errorf("%w: quantity", internal.ErrWarehouseServiceInvalidField)
+	}
+	if fromWarehouseID == toWarehouseID {
+		return fmt.Errorf("%w: to_warehouse_id", internal.ErrWarehouseServiceInvalidField)
+	}
+
+	// transfer the stock in the repository
+	err = s.rp.Transfer(productID, fromWarehouseID, toWarehouseID, quantity)
+
+	// check for errors
+	if err != nil {
+		err = warehouseError(err)
+		return
+	}
+	return
+}
+
+// warehouseError maps the repository errors of the warehouses
+func warehouseError(err error) error {
+	switch {
+	case errors.Is(err, internal.ErrWarehouseRepositoryNotFound):
+		return internal.ErrWarehouseRepositoryNotFound
+	case errors.Is(err, internal.ErrWarehouseRepositoryDuplicated):
+		return internal.ErrWarehouseRepositoryDuplicated
+	case errors.Is(err, internal.ErrWarehouseRepositoryNotEmpty):
+		return internal.ErrWarehouseRepositoryNotEmpty
+	case errors.Is(err, internal.ErrWarehouseRepositoryInsufficientStock):
+		return internal.ErrWarehouseRepositoryInsufficientStock
+	case errors.Is(err, internal.ErrProductRepositoryNotFound):
+		return internal.ErrProductRepositoryNotFound
+	default:
+		return internal.ErrInternalServerError
+	}
+}
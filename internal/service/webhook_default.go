@@ -0,0 +1,417 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"storage/internal"
+	"storage/internal/sink"
+	"storage/internal/webhook"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxWebhookAttempts is the amount of attempts of a delivery before it fails
+	MaxWebhookAttempts = 10
+	// WebhookDeliveryBatchSize is the maximum amount of deliveries attempted by a run
+	WebhookDeliveryBatchSize = 50
+	// WebhookDeliveryConcurrency is the maximum amount of webhooks posted to at the same time by a run
+	WebhookDeliveryConcurrency = 8
+	// WebhookClaimDuration is how long the deliveries claimed by a run are skipped by the others, longer than a run
+	WebhookClaimDuration = 10 * time.Minute
+	// MaxWebhookDeliveries is the maximum amount of deliveries listed
+	MaxWebhookDeliveries = 200
+	// webhookMinBackoff is the wait before the first retry of a delivery, doubled on every failure
+	webhookMinBackoff = 10 * time.Second
+	// webhookMaxBackoff is the maximum wait before retrying a delivery
+	webhookMaxBackoff = 6 * time.Hour
+)
+
+// NewWebhookDefault creates a new instance of the webhook service posting with the sender
+func NewWebhookDefault(rp internal.WebhookRepository, sender *webhook.Sender) *WebhookDefault {
+	return &WebhookDefault{
+		rp:     rp,
+		sender: sender,
+	}
+}
+
+// WebhookDefault is the default implementation of the webhook service
+// - as a sink of the outbox relay it creates a delivery of every event for each subscribed webhook
+// - Deliver posts the due deliveries, retrying the failed ones with exponential backoff up to MaxWebhookAttempts
+type WebhookDefault struct {
+	// rp is the repository used by the service
+	rp internal.WebhookRepository
+	// sender posts the deliveries
+	sender *webhook.Sender
+}
+
+// FindAll returns all the webhooks
+func (s *WebhookDefault) FindAll() (webhooks []internal.Webhook, err error) {
+
+	// get the webhooks from the repository
+	webhooks, err = s.rp.FindAll()
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// FindByID returns a webhook
+func (s *WebhookDefault) FindByID(id int) (wh internal.Webhook, err error) {
+
+	// get the webhook from the repository
+	wh, err = s.rp.FindByID(id)
+
+	// check for errors
+	if err != nil {
+		err = webhookError(err)
+		return
+	}
+	return
+}
+
+// Create creates a webhook, generating its secret when not given
+func (s *WebhookDefault) Create(wh *internal.Webhook) (err error) {
+
+	// validate the webhook fields
+	err = validateWebhookFields(wh)
+	if err != nil {
+		return
+	}
+	if wh.Secret == "" {
+		var random string
+		random, err = randomHex(24)
+		if err != nil {
+			err = internal.ErrInternalServerError
+			return
+		}
+		wh.Secret = "whsec_" + random
+	}
+	wh.CreatedAt = time.Now().UTC()
+
+	// create the webhook in the repository
+	err = s.rp.Create(wh)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// Update updates the url, event types and active flag of a webhook
+func (s *WebhookDefault) Update(wh *internal.Webhook) (err error) {
+
+	// validate the webhook fields
+	err = validateWebhookFields(wh)
+	if err != nil {
+		return
+	}
+
+	// update the webhook in the repository
+	err = s.rp.Update(wh)
+
+	// check for errors
+	if err != nil {
+		err = webhookError(err)
+		return
+	}
+	return
+}
+
+// Delete deletes a webhook and its deliveries
+func (s *WebhookDefault) Delete(id int) (err error) {
+
+	// delete the webhook in the repository
+	err = s.rp.Delete(id)
+
+	// check for errors
+	if err != nil {
+		err = webhookError(err)
+		return
+	}
+	return
+}
+
+// FindDeliveries returns the latest deliveries of a webhook, of the status when given
+func (s *WebhookDefault) FindDeliveries(webhookID int, status internal.WebhookDeliveryStatus) (deliveries []internal.WebhookDelivery, err error) {
+
+	// validate the status
+	switch status {
+	case "", internal.WebhookDeliveryPending, internal.WebhookDeliverySucceeded, internal.WebhookDeliveryFailed:
+	default:
+		err = fmt.Errorf("%w: status", internal.ErrWebhookServiceInvalidField)
+		return
+	}
+
+	// check the webhook exists
+	_, err = s.rp.FindByID(webhookID)
+	if err != nil {
+		err = webhookError(err)
+		return
+	}
+
+	// get the deliveries from the repository
+	deliveries, err = s.rp.FindDeliveries(webhookID, status, MaxWebhookDeliveries)
+
+	// check for errors
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+	return
+}
+
+// FindDelivery returns a delivery of a webhook with its attempts
+func (s *WebhookDefault) FindDelivery(webhookID, id int) (delivery internal.WebhookDelivery, err error) {
+
+	// get the delivery from the repository
+	delivery, err = s.rp.FindDelivery(webhookID, id)
+
+	// check for errors
+	if err != nil {
+		err = webhookError(err)
+		return
+	}
+	return
+}
+
+// Replay attempts a failed delivery again on the next run, with a new series of attempts
+func (s *WebhookDefault) Replay(webhookID, id int) (err error) {
+
+	// get the delivery from the repository
+	delivery, err := s.rp.FindDelivery(webhookID, id)
+	if err != nil {
+		err = webhookError(err)
+		return
+	}
+	if delivery.Status != internal.WebhookDeliveryFailed {
+		err = internal.ErrWebhookServiceNotReplayable
+		return
+	}
+
+	// reschedule the delivery in the repository
+	err = s.rp.Reschedule(webhookID, id, time.Now().UTC())
+
+	// check for errors
+	if err != nil {
+		err = webhookError(err)
+		return
+	}
+	return
+}
+
+// Name returns the name of the service as a sink of the outbox relay
+func (s *WebhookDefault) Name() string {
+	return "webhooks"
+}
+
+// Publish creates the deliveries of the event for the active webhooks subscribed to it
+func (s *WebhookDefault) Publish(ctx context.Context, event internal.Event) (err error) {
+
+	// get the active webhooks from the repository
+	webhooks, err := s.rp.FindActive()
+	if err != nil {
+		return
+	}
+
+	// the body is the same for every webhook and attempt
+	body, err := json.Marshal(sink.EventJSON(event))
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	var deliveries []internal.WebhookDelivery
+	for _, wh := range webhooks {
+		if !wh.Subscribed(event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, internal.WebhookDelivery{
+			WebhookID:     wh.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Body:          body,
+			Status:        internal.WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+
+	// create the deliveries in the repository
+	err = s.rp.CreateDeliveries(deliveries)
+	return
+}
+
+// Deliver posts the due deliveries and returns the amount accepted by the receivers
+func (s *WebhookDefault) Deliver(ctx context.Context) (delivered int, err error) {
+
+	// claim the due deliveries from the repository
+	now := time.Now().UTC()
+	deliveries, err := s.rp.ClaimDueDeliveries(now, now.Add(WebhookClaimDuration), WebhookDeliveryBatchSize)
+	if err != nil {
+		err = internal.ErrInternalServerError
+		return
+	}
+
+	// group the deliveries by webhook, keeping their order
+	var webhookIDs []int
+	queues := make(map[int][]internal.WebhookDelivery)
+	for _, delivery := range deliveries {
+		if _, ok := queues[delivery.WebhookID]; !ok {
+			webhookIDs = append(webhookIDs, delivery.WebhookID)
+		}
+		queues[delivery.WebhookID] = append(queues[delivery.WebhookID], delivery)
+	}
+
+	// post to the webhooks concurrently, so a slow one does not delay the others
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	slots := make(chan struct{}, WebhookDeliveryConcurrency)
+	for _, webhookID := range webhookIDs {
+		wg.Add(1)
+		go func(queue []internal.WebhookDelivery) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+
+			n, e := s.deliverWebhook(ctx, queue)
+			mu.Lock()
+			defer mu.Unlock()
+			delivered += n
+			if e != nil && err == nil {
+				err = e
+			}
+		}(queues[webhookID])
+	}
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+// deliverWebhook posts the deliveries of a webhook one after another
+// - the deliveries of a webhook deleted or disabled since they were claimed are not posted
+func (s *WebhookDefault) deliverWebhook(ctx context.Context, deliveries []internal.WebhookDelivery) (delivered int, err error) {
+
+	// get the webhook of the deliveries
+	wh, err := s.rp.FindByID(deliveries[0].WebhookID)
+	if err != nil {
+		if errors.Is(err, internal.ErrWebhookRepositoryNotFound) {
+			err = nil
+			return
+		}
+		err = internal.ErrInternalServerError
+		return
+	}
+	if !wh.Active {
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// post the delivery and record the attempt
+		attempt := s.attempt(ctx, wh, &delivery)
+		err = s.rp.RecordAttempt(&delivery, &attempt)
+		if err != nil {
+			err = internal.ErrInternalServerError
+			return
+		}
+		if delivery.Status == internal.WebhookDeliverySucceeded {
+			delivered++
+		}
+	}
+	return
+}
+
+// attempt posts the delivery to the webhook and updates its state with the result
+func (s *WebhookDefault) attempt(ctx context.Context, wh internal.Webhook, delivery *internal.WebhookDelivery) (attempt internal.WebhookAttempt) {
+	attempt = internal.WebhookAttempt{
+		DeliveryID:  delivery.ID,
+		AttemptedAt: time.Now().UTC(),
+	}
+	statusCode, err := s.sender.Send(ctx, wh.URL, wh.Secret, delivery.ID, string(delivery.EventType), delivery.Body)
+	attempt.Duration = time.Since(attempt.AttemptedAt)
+	attempt.StatusCode = statusCode
+
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	switch {
+	case err == nil:
+		delivery.Status = internal.WebhookDeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &attempt.AttemptedAt
+	case delivery.Attempts >= MaxWebhookAttempts:
+		attempt.Error = err.Error()
+		delivery.Status = internal.WebhookDeliveryFailed
+		delivery.LastError = attempt.Error
+	default:
+		attempt.Error = err.Error()
+		delivery.LastError = attempt.Error
+		delivery.NextAttemptAt = attempt.AttemptedAt.Add(webhookBackoff(delivery.Attempts))
+	}
+	return
+}
+
+// webhookBackoff returns the wait after the attempt-th failed attempt of a delivery
+func webhookBackoff(attempt int) time.Duration {
+	backoff := webhookMinBackoff
+	for i := 1; i < attempt && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, webhookMaxBackoff)
+}
+
+// validateWebhookFields validates the url and event types of a webhook
+func validateWebhookFields(wh *internal.Webhook) (err error) {
+
+	// validate the url
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https url", internal.ErrWebhookServiceInvalidField)
+	}
+
+	// validate the event types
+	for _, eventType := range wh.EventTypes {
+		known := false
+		for _, t := range internal.EventTypes {
+			known = known || t == eventType
+		}
+		if !known {
+			return fmt.Errorf("%w: event_types: unknown %q", internal.ErrWebhookServiceInvalidField, eventType)
+		}
+	}
+
+	return nil
+}
+
+// webhookError maps the errors of the webhook repository
+func webhookError(err error) error {
+	switch {
+	case errors.Is(err, internal.ErrWebhookRepositoryNotFound):
+		return internal.ErrWebhookRepositoryNotFound
+	default:
+		return internal.ErrInternalServerError
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"storage/internal"
+	"storage/internal/webhook"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookRepositoryFake is an in-memory webhook repository implementing what the deliveries use
+type webhookRepositoryFake struct {
+	internal.WebhookRepository
+
+	mu         sync.Mutex
+	webhooks   map[int]internal.Webhook
+	deliveries map[int]*internal.WebhookDelivery
+	attempts   []internal.WebhookAttempt
+}
+
+func newWebhookRepositoryFake() *webhookRepositoryFake {
+	return &webhookRepositoryFake{
+		webhooks:   make(map[int]internal.Webhook),
+		deliveries: make(map[int]*internal.WebhookDelivery),
+	}
+}
+
+// add adds the webhook and a pending due delivery for each of the events
+func (f *webhookRepositoryFake) add(wh internal.Webhook, eventIDs ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.webhooks[wh.ID] = wh
+	for _, eventID := range eventIDs {
+		id := len(f.deliveries) + 1
+		f.deliveries[id] = &internal.WebhookDelivery{
+			ID:            id,
+			WebhookID:     wh.ID,
+			EventID:       eventID,
+			EventType:     internal.EventProductUpdated,
+			Body:          []byte(`{}`),
+			Status:        internal.WebhookDeliveryPending,
+			NextAttemptAt: time.Now().UTC().Add(-time.Second),
+		}
+	}
+}
+
+func (f *webhookRepositoryFake) FindByID(id int) (wh internal.Webhook, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	wh, ok := f.webhooks[id]
+	if !ok {
+		err = internal.ErrWebhookRepositoryNotFound
+	}
+	return
+}
+
+// ClaimDueDeliveries returns the due deliveries of any webhook, the service must skip the inactive ones
+func (f *webhookRepositoryFake) ClaimDueDeliveries(now, until time.Time, limit int) (deliveries []internal.WebhookDelivery, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id := 1; id <= len(f.deliveries) && len(deliveries) < limit; id++ {
+		delivery := f.deliveries[id]
+		if delivery.Status != internal.WebhookDeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		delivery.NextAttemptAt = until
+		deliveries = append(deliveries, *delivery)
+	}
+	return
+}
+
+func (f *webhookRepositoryFake) RecordAttempt(delivery *internal.WebhookDelivery, attempt *internal.WebhookAttempt) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored := *delivery
+	f.deliveries[delivery.ID] = &stored
+	f.attempts = append(f.attempts, *attempt)
+	return
+}
+
+func (f *webhookRepositoryFake) delivery(id int) internal.WebhookDelivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return *f.deliveries[id]
+}
+
+// receiver counts the deliveries posted to it, holding them until release is closed when set
+type receiver struct {
+	mu      sync.Mutex
+	posts   map[string]int
+	status  int
+	release chan struct{}
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if rc.release != nil {
+		<-rc.release
+	}
+	rc.mu.Lock()
+	if rc.posts == nil {
+		rc.posts = make(map[string]int)
+	}
+	rc.posts[r.Header.Get(webhook.DeliveryHeader)]++
+	status := rc.status
+	rc.mu.Unlock()
+	if status == 0 {
+		status = http.StatusNoContent
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) count() (total int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, n := range rc.posts {
+		total += n
+	}
+	return
+}
+
+func TestWebhookDefault_Deliver_SlowWebhookDoesNotDelayTheOthers(t *testing.T) {
+	slow := &receiver{release: make(chan struct{})}
+	slowServer := httptest.NewServer(slow)
+	defer slowServer.Close()
+	fast := &receiver{}
+	fastServer := httptest.NewServer(fast)
+	defer fastServer.Close()
+
+	rp := newWebhookRepositoryFake()
+	rp.add(internal.Webhook{ID: 1, URL: slowServer.URL, Secret: "secret", Active: true}, 1, 2)
+	rp.add(internal.Webhook{ID: 2, URL: fastServer.URL, Secret: "secret", Active: true}, 1, 2, 3)
+	sv := NewWebhookDefault(rp, webhook.NewSender(nil))
+
+	type result struct {
+		delivered int
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		delivered, err := sv.Deliver(context.Background())
+		done <- result{delivered, err}
+	}()
+
+	// the fast webhook receives its deliveries while the slow one holds the first of its own
+	deadline := time.Now().Add(5 * time.Second)
+	for fast.count() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 posts to the fast webhook, got %d", fast.count())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(slow.release)
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.delivered != 5 {
+		t.Fatalf("expected 5 deliveries, got %d", res.delivered)
+	}
+	for id := 1; id <= 5; id++ {
+		if delivery := rp.delivery(id); delivery.Status != internal.WebhookDeliverySucceeded || delivery.Attempts != 1 {
+			t.Fatalf("expected delivery %d succeeded at the first attempt, got %s after %d", id, delivery.Status, delivery.Attempts)
+		}
+	}
+}
+
+func TestWebhookDefault_Deliver_SkipsInactiveWebhooks(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	rp := newWebhookRepositoryFake()
+	rp.add(internal.Webhook{ID: 1, URL: server.URL, Secret: "secret", Active: false}, 1)
+	rp.add(internal.Webhook{ID: 2, URL: server.URL, Secret: "secret", Active: true}, 1)
+	sv := NewWebhookDefault(rp, webhook.NewSender(nil))
+
+	delivered, err := sv.Deliver(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 1 || rc.count() != 1 {
+		t.Fatalf("expected only the active webhook posted, got %d deliveries and %d posts", delivered, rc.count())
+	}
+	if delivery := rp.delivery(1); delivery.Status != internal.WebhookDeliveryPending || delivery.Attempts != 0 {
+		t.Fatalf("expected the delivery of the inactive webhook kept pending, got %s after %d attempts", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestWebhookDefault_Deliver_ConcurrentRunsPostOnce(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	rp := newWebhookRepositoryFake()
+	rp.add(internal.Webhook{ID: 1, URL: server.URL, Secret: "secret", Active: true}, 1, 2, 3, 4, 5)
+	rp.add(internal.Webhook{ID: 2, URL: server.URL, Secret: "secret", Active: true}, 1, 2, 3, 4, 5)
+	sv := NewWebhookDefault(rp, webhook.NewSender(nil))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sv.Deliver(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.posts) != 10 {
+		t.Fatalf("expected 10 deliveries posted, got %d", len(rc.posts))
+	}
+	for id, n := range rc.posts {
+		if n != 1 {
+			t.Fatalf("expected delivery %s posted once, got %d", id, n)
+		}
+	}
+}
+
+func TestWebhookDefault_Deliver_RetriesFailures(t *testing.T) {
+	rc := &receiver{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	rp := newWebhookRepositoryFake()
+	rp.add(internal.Webhook{ID: 1, URL: server.URL, Secret: "secret", Active: true}, 1)
+	sv := NewWebhookDefault(rp, webhook.NewSender(nil))
+
+	before := time.Now().UTC()
+	delivered, err := sv.Deliver(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 0 {
+		t.Fatalf("expected no deliveries, got %d", delivered)
+	}
+
+	delivery := rp.delivery(1)
+	if delivery.Status != internal.WebhookDeliveryPending || delivery.Attempts != 1 || delivery.LastStatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a pending delivery after a 503, got %+v", delivery)
+	}
+	if next := delivery.NextAttemptAt.Sub(before); next < webhookMinBackoff || next > webhookMinBackoff+time.Minute {
+		t.Fatalf("expected the next attempt after %s, got %s", webhookMinBackoff, next)
+	}
+}
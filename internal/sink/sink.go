@@ -0,0 +1,67 @@
+// Package sink contains destinations of the domain events relayed from the outbox
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"storage/internal"
+	"sync"
+	"time"
+)
+
+// Func adapts a function to an event sink
+type Func struct {
+	// SinkName is the name of the sink
+	SinkName string
+	// Fn delivers the event
+	Fn func(ctx context.Context, event internal.Event) error
+}
+
+// Name returns the name of the sink
+func (f Func) Name() string {
+	return f.SinkName
+}
+
+// Publish delivers the event calling the function
+func (f Func) Publish(ctx context.Context, event internal.Event) error {
+	return f.Fn(ctx, event)
+}
+
+// NewLog creates a sink writing the events to w as JSON lines
+func NewLog(w io.Writer) *Log {
+	return &Log{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Log is a sink writing the events as JSON lines, e.g. to be shipped by a log collector
+type Log struct {
+	// mu serializes the writes
+	mu sync.Mutex
+	// enc writes the lines
+	enc *json.Encoder
+}
+
+// Name returns the name of the sink
+func (l *Log) Name() string {
+	return "log"
+}
+
+// Publish writes the event as a JSON line
+func (l *Log) Publish(ctx context.Context, event internal.Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(EventJSON(event))
+}
+
+// EventJSON returns the JSON representation of an event shared by the sinks
+func EventJSON(event internal.Event) map[string]any {
+	return map[string]any{
+		"id":          event.ID,
+		"type":        event.Type,
+		"product_id":  event.ProductID,
+		"occurred_at": event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		"data":        event.Payload,
+	}
+}
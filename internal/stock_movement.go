@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+// StockMovementType is the kind of a stock movement
+type StockMovementType string
+
+const (
+	// StockMovementReceipt adds the units received from a supplier
+	StockMovementReceipt StockMovementType = "receipt"
+	// StockMovementSale removes the units sold
+	StockMovementSale StockMovementType = "sale"
+	// StockMovementAdjustment adds or removes units after a count
+	StockMovementAdjustment StockMovementType = "adjustment"
+	// StockMovementReturn adds the units returned by a customer
+	StockMovementReturn StockMovementType = "return"
+	// StockMovementWriteOff removes the units damaged, lost or expired
+	StockMovementWriteOff StockMovementType = "write_off"
+)
+
+// StockMovement is a struct that contains a change of the stock of a product
+type StockMovement struct {
+	// ID is the unique identifier of the movement
+	ID int
+	// ProductID is the identifier of the product
+	ProductID int
+	// Type is the kind of movement
+	Type StockMovementType
+	// Quantity is the signed change of the stock
+	Quantity int
+	// QuantityAfter is the stock of the product after the movement
+	QuantityAfter int
+	// Reason is the explanation of the movement
+	Reason string
+	// Actor is who performed the movement
+	Actor string
+	// CreatedAt is the moment of the movement
+	CreatedAt time.Time
+	// Lots are the units taken from each lot when the movement removes stock, first expired first out
+	Lots []LotAllocation
+}
+
+var (
+	// ErrStockMovementRepositoryNegativeStock is the error returned when a movement leaves the stock below zero or below the units reserved
+	ErrStockMovementRepositoryNegativeStock = errors.New("repository: not enough stock")
+	// ErrStockMovementRepositoryAllocatedStock is the error returned when a movement leaves the stock below the units allocated to the warehouses
+	ErrStockMovementRepositoryAllocatedStock = errors.New("repository: quantity below the stock allocated to the warehouses")
+	// ErrStockMovementServiceInvalidField is the error returned when the movement has an invalid field
+	ErrStockMovementServiceInvalidField = errors.New("service: invalid field")
+)
+
+// StockMovementRepository is an interface that contains the methods that the stock movement repository should support
+type StockMovementRepository interface {
+	// Create records the movement and applies it to the product quantity atomically
+	// - it sets the ID and QuantityAfter of the movement
+	// - a sale of an expired product returns ErrProductRepositoryExpired when selling them is blocked
+	Create(movement *StockMovement) error
+	// FindByProductID returns the movements of the product ordered from oldest to newest
+	FindByProductID(productID int) ([]StockMovement, error)
+}
+
+// StockMovementService is an interface that contains the methods that the stock movement service should support
+type StockMovementService interface {
+	// Create records a movement, the quantity is positive except for adjustments which are signed
+	Create(movement *StockMovement) error
+	// FindByProductID returns the movements of the product
+	FindByProductID(productID int) ([]StockMovement, error)
+}
@@ -0,0 +1,297 @@
+// Package stream fans the domain events of the outbox out to the live subscribers of this instance
+package stream
+
+import (
+	"context"
+	"errors"
+	"storage/internal"
+	"sync"
+	"time"
+)
+
+const (
+	// pageSize is the amount of events read from the source at once
+	pageSize = 500
+	// DefaultBuffer is the amount of events a subscriber may fall behind before it is dropped
+	DefaultBuffer = 256
+	// maxStartBackoff is the maximum wait between the attempts to read the position of the source on start
+	maxStartBackoff = 30 * time.Second
+)
+
+var (
+	// ErrSlowSubscriber is the error returned when the subscriber fell too far behind and was dropped
+	ErrSlowSubscriber = errors.New("stream: subscriber too slow")
+)
+
+// Source is where the hub reads the events from, the outbox
+type Source interface {
+	// FindAfter returns up to limit events with an ID greater than the given one, in emission order
+	FindAfter(id int, limit int) ([]internal.Event, error)
+	// LastID returns the ID of the latest event, 0 when there is none
+	LastID() (int, error)
+	// FirstID returns the ID of the oldest event retained, 0 when there is none
+	FirstID() (int, error)
+}
+
+// NewHub creates a hub polling the source every interval
+// - the ids are allocated when the changes are written but the transactions commit in any order,
+// so a missing id is waited for during grace before it is skipped as rolled back
+func NewHub(source Source, interval, grace time.Duration) *Hub {
+	return &Hub{
+		source:   source,
+		interval: interval,
+		grace:    grace,
+		subs:     make(map[*Subscription]struct{}),
+		started:  make(chan struct{}),
+	}
+}
+
+// Hub tails the outbox and broadcasts the events in order to the subscribers
+// - every instance runs its own hub, so the subscribers of any instance receive all the events
+type Hub struct {
+	// source is where the events are read from
+	source Source
+	// interval is the wait between polls
+	interval time.Duration
+	// grace is how long a missing id is waited for
+	grace time.Duration
+	// started is closed once the cursor is set to the position of the source
+	started chan struct{}
+
+	// mu guards the fields below
+	mu sync.Mutex
+	// subs are the live subscribers
+	subs map[*Subscription]struct{}
+	// cursor is the ID of the last event broadcast
+	cursor int
+	// gapSince is when the missing id after the cursor was first noticed, zero when there is no gap
+	gapSince time.Time
+}
+
+// Subscription is a subscriber of the hub
+type Subscription struct {
+	// events receives the events broadcast, it is closed when the subscriber is dropped
+	events chan internal.Event
+	// cursor is the ID of the last event broadcast when it subscribed
+	cursor int
+	// dropped tells the subscriber was dropped for being too slow
+	dropped bool
+}
+
+// Start polls the source until the context is done
+// - the position of the source is read again with exponential backoff until it succeeds, the streams wait for it
+func (h *Hub) Start(ctx context.Context) (err error) {
+	cursor, err := h.source.LastID()
+	for backoff := h.interval; err != nil; backoff = min(backoff*2, maxStartBackoff) {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		cursor, err = h.source.LastID()
+	}
+	h.mu.Lock()
+	h.cursor = cursor
+	h.mu.Unlock()
+	close(h.started)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			// a failed poll is retried on the next tick
+			h.poll(time.Now())
+		}
+	}
+}
+
+// poll reads the events after the cursor and broadcasts them while they are contiguous
+func (h *Hub) poll(now time.Time) (err error) {
+	for {
+		h.mu.Lock()
+		cursor := h.cursor
+		h.mu.Unlock()
+
+		var events []internal.Event
+		events, err = h.source.FindAfter(cursor, pageSize)
+		if err != nil {
+			return
+		}
+
+		h.mu.Lock()
+		for _, event := range events {
+			if event.ID != h.cursor+1 {
+				if h.gapSince.IsZero() {
+					h.gapSince = now
+				}
+				if now.Sub(h.gapSince) < h.grace {
+					h.mu.Unlock()
+					return
+				}
+			}
+			h.gapSince = time.Time{}
+			h.cursor = event.ID
+			h.broadcast(event)
+		}
+		h.mu.Unlock()
+
+		if len(events) < pageSize {
+			return
+		}
+	}
+}
+
+// broadcast sends the event to the subscribers, dropping the ones whose buffer is full
+// - it is called with the lock held
+func (h *Hub) broadcast(event internal.Event) {
+	for sub := range h.subs {
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped = true
+			close(sub.events)
+			delete(h.subs, sub)
+		}
+	}
+}
+
+// Position returns the ID of the last event broadcast, the changes of the events up to it are committed
+func (h *Hub) Position() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cursor
+}
+
+// Subscribe registers a subscriber that may fall buffer events behind
+func (h *Hub) Subscribe(buffer int) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscription{
+		events: make(chan internal.Event, buffer),
+		cursor: h.cursor,
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes the subscriber
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		close(sub.events)
+		delete(h.subs, sub)
+	}
+}
+
+// Stream sends to the subscriber the events after the given id read from the source, when after is not negative,
+// and then the live ones, until the context is done, the subscriber is dropped or send fails
+// - only the events accepted by the filter are sent, ping is called every heartbeat without events
+// - when the events after the given id are no longer retained, reset is called instead and only the live ones are sent,
+// so the subscriber reloads its state; reset may be nil when after is negative
+func (h *Hub) Stream(ctx context.Context, after int, filter func(internal.Event) bool, heartbeat time.Duration, send func(internal.Event) error, ping func() error, reset func() error) (err error) {
+	// the catch up needs the position the hub started from
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+waitStart:
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-h.started:
+			break waitStart
+		case <-ticker.C:
+			if err = ping(); err != nil {
+				return
+			}
+		}
+	}
+
+	sub := h.Subscribe(DefaultBuffer)
+	defer h.Unsubscribe(sub)
+
+	// the events after the given id may have been pruned, the subscriber then continues from the subscription
+	if after >= 0 && after < sub.cursor {
+		var first int
+		first, err = h.source.FirstID()
+		if err != nil {
+			return
+		}
+		if first > after+1 {
+			if err = reset(); err != nil {
+				return
+			}
+			after = sub.cursor
+		}
+	}
+
+	// catch up from the source up to the point the subscription started
+	last := after
+catchUp:
+	for last >= 0 && last < sub.cursor {
+		var events []internal.Event
+		events, err = h.source.FindAfter(last, pageSize)
+		if err != nil {
+			return
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, event := range events {
+			if event.ID > sub.cursor {
+				break catchUp
+			}
+			last = event.ID
+			if filter(event) {
+				if err = send(event); err != nil {
+					return
+				}
+			}
+		}
+		if len(events) < pageSize {
+			break
+		}
+	}
+
+	// follow the live events
+	ticker.Reset(heartbeat)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-sub.events:
+			if !ok {
+				return h.closeReason(sub)
+			}
+			if event.ID <= last || !filter(event) {
+				continue
+			}
+			last = event.ID
+			if err = send(event); err != nil {
+				return
+			}
+			ticker.Reset(heartbeat)
+		case <-ticker.C:
+			if err = ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// closeReason returns why the events of the subscriber were closed
+func (h *Hub) closeReason(sub *Subscription) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if sub.dropped {
+		return ErrSlowSubscriber
+	}
+	return context.Canceled
+}
@@ -0,0 +1,107 @@
+package internal
+
+import "errors"
+
+// Supplier is a struct that contains the supplier's information
+type Supplier struct {
+	// ID is the unique identifier of the supplier
+	ID int
+	// Name is the name of the supplier
+	Name string
+	// Email is the contact email of the supplier
+	Email string
+	// Phone is the contact phone of the supplier
+	Phone string
+}
+
+// ProductSupplier is a struct that contains the conditions a supplier sells a product at
+type ProductSupplier struct {
+	// ProductID is the identifier of the product
+	ProductID int
+	// SupplierID is the identifier of the supplier
+	SupplierID int
+	// SupplierSKU is the code of the product for the supplier
+	SupplierSKU string
+	// CostPrice is the price the supplier charges for a unit
+	CostPrice float64
+	// LeadTimeDays is the amount of days the supplier takes to deliver
+	LeadTimeDays int
+	// Preferred tells whether the supplier is the preferred one for the product
+	Preferred bool
+}
+
+// ProductMargin is a struct that contains the margin of a product against its supplier cost
+type ProductMargin struct {
+	// ProductID is the identifier of the product
+	ProductID int
+	// SupplierID is the supplier the cost is taken from, the preferred one or else the cheapest
+	SupplierID int
+	// Price is the price of the product
+	Price float64
+	// CostPrice is the cost of the product from the supplier
+	CostPrice float64
+	// Margin is the price minus the cost
+	Margin float64
+	// MarginPercent is the margin over the price, in percent
+	MarginPercent float64
+}
+
+var (
+	// ErrSupplierRepositoryNotFound is the error returned when the supplier is not found
+	ErrSupplierRepositoryNotFound = errors.New("repository: supplier not found")
+	// ErrSupplierRepositoryDuplicated is the error returned when the supplier already exists
+	ErrSupplierRepositoryDuplicated = errors.New("repository: supplier already exists")
+	// ErrProductSupplierRepositoryNotFound is the error returned when the product is not supplied by the supplier
+	ErrProductSupplierRepositoryNotFound = errors.New("repository: product supplier not found")
+	// ErrSupplierServiceInvalidField is the error returned when the supplier has an invalid field
+	ErrSupplierServiceInvalidField = errors.New("service: invalid field")
+)
+
+// SupplierRepository is an interface that contains the methods that the supplier repository should support
+type SupplierRepository interface {
+	// FindAll returns all the suppliers
+	FindAll() ([]Supplier, error)
+	// FindByID returns the supplier with the given ID
+	FindByID(id int) (Supplier, error)
+	// Create creates a new supplier
+	Create(supplier *Supplier) error
+	// Update updates the supplier with the given ID
+	Update(supplier *Supplier) error
+	// Delete deletes the supplier with the given ID and its product links
+	Delete(id int) error
+	// FindByProductID returns the suppliers of the product
+	FindByProductID(productID int) ([]ProductSupplier, error)
+	// SetProductSupplier creates or updates the link between a product and a supplier
+	// - when preferred, the other suppliers of the product stop being preferred
+	SetProductSupplier(link *ProductSupplier) error
+	// DeleteProductSupplier deletes the link between a product and a supplier
+	DeleteProductSupplier(productID, supplierID int) error
+	// FindMargins returns the price and cost of the products with suppliers, Margin fields are not set
+	FindMargins() ([]ProductMargin, error)
+	// FindMarginByProductID returns the price and cost of the product, Margin fields are not set
+	FindMarginByProductID(productID int) (ProductMargin, error)
+}
+
+// SupplierService is an interface that contains the methods that the supplier service should support
+type SupplierService interface {
+	// FindAll returns all the suppliers
+	FindAll() ([]Supplier, error)
+	// FindByID returns the supplier with the given ID
+	FindByID(id int) (Supplier, error)
+	// Create creates a new supplier
+	Create(supplier *Supplier) error
+	// Update updates the supplier with the given ID
+	Update(supplier *Supplier) error
+	// Delete deletes the supplier with the given ID
+	Delete(id int) error
+	// FindByProductID returns the suppliers of the product
+	FindByProductID(productID int) ([]ProductSupplier, error)
+	// SetProductSupplier creates or updates the link between a product and a supplier
+	SetProductSupplier(link *ProductSupplier) error
+	// DeleteProductSupplier deletes the link between a product and a supplier
+	DeleteProductSupplier(productID, supplierID int) error
+	// FindMargins returns the margin of every product with suppliers
+	FindMargins() ([]ProductMargin, error)
+	// FindMarginByProductID returns the margin of the product
+	FindMarginByProductID(productID int) (ProductMargin, error)
+}
@@ -0,0 +1,94 @@
+package internal
+
+import "errors"
+
+// Warehouse is a struct that contains the warehouse's information
+type Warehouse struct {
+	// ID is the unique identifier of the warehouse
+	ID int
+	// Name is the name of the warehouse
+	Name string
+	// Address is the address of the warehouse
+	Address string
+}
+
+// WarehouseStock is a struct that contains the stock of a product in a warehouse
+type WarehouseStock struct {
+	// ProductID is the identifier of the product
+	ProductID int
+	// WarehouseID is the identifier of the warehouse
+	WarehouseID int
+	// Quantity is the amount of units of the product in the warehouse
+	Quantity int
+}
+
+// ProductStock is a struct that contains the stock of a product across the warehouses
+type ProductStock struct {
+	// ProductID is the identifier of the product
+	ProductID int
+	// Quantity is the total stock of the product
+	Quantity int
+	// Allocated is the stock of the product allocated to warehouses
+	Allocated int
+	// Unallocated is the stock of the product not allocated to any warehouse
+	Unallocated int
+	// Warehouses is the stock of the product per warehouse
+	Warehouses []WarehouseStock
+}
+
+var (
+	// ErrWarehouseRepositoryNotFound is the error returned when the warehouse is not found
+	ErrWarehouseRepositoryNotFound = errors.New("repository: warehouse not found")
+	// ErrWarehouseRepositoryDuplicated is the error returned when the warehouse already exists
+	ErrWarehouseRepositoryDuplicated = errors.New("repository: warehouse already exists")
+	// ErrWarehouseRepositoryNotEmpty is the error returned when deleting a warehouse that still has stock
+	ErrWarehouseRepositoryNotEmpty = errors.New("repository: warehouse has stock")
+	// ErrWarehouseRepositoryInsufficientStock is the error returned when there are not enough units to allocate or transfer
+	ErrWarehouseRepositoryInsufficientStock = errors.New("repository: insufficient stock")
+	// ErrWarehouseServiceInvalidField is the error returned when the warehouse or stock has an invalid field
+	ErrWarehouseServiceInvalidField = errors.New("service: invalid field")
+)
+
+// WarehouseRepository is an interface that contains the methods that the warehouse repository should support
+type WarehouseRepository interface {
+	// FindAll returns all the warehouses
+	FindAll() ([]Warehouse, error)
+	// FindByID returns the warehouse with the given ID
+	FindByID(id int) (Warehouse, error)
+	// Create creates a new warehouse
+	Create(warehouse *Warehouse) error
+	// Update updates the warehouse with the given ID
+	Update(warehouse *Warehouse) error
+	// Delete deletes the warehouse with the given ID
+	Delete(id int) error
+	// FindStockByWarehouseID returns the stock of the products in the warehouse
+	FindStockByWarehouseID(warehouseID int) ([]WarehouseStock, error)
+	// FindStockByProductID returns the stock of the product per warehouse
+	FindStockByProductID(productID int) (ProductStock, error)
+	// SetStock sets the stock of a product in a warehouse, the allocated stock can not exceed the product quantity
+	SetStock(stock *WarehouseStock) error
+	// Transfer moves units of a product from a warehouse to another atomically
+	Transfer(productID, fromWarehouseID, toWarehouseID, quantity int) error
+}
+
+// WarehouseService is an interface that contains the methods that the warehouse service should support
+type WarehouseService interface {
+	// FindAll returns all the warehouses
+	FindAll() ([]Warehouse, error)
+	// FindByID returns the warehouse with the given ID
+	FindByID(id int) (Warehouse, error)
+	// Create creates a new warehouse
+	Create(warehouse *Warehouse) error
+	// Update updates the warehouse with the given ID
+	Update(warehouse *Warehouse) error
+	// Delete deletes the warehouse with the given ID
+	Delete(id int) error
+	// FindStockByWarehouseID returns the stock of the products in the warehouse
+	FindStockByWarehouseID(warehouseID int) ([]WarehouseStock, error)
+	// FindStockByProductID returns the stock of the product per warehouse
+	FindStockByProductID(productID int) (ProductStock, error)
+	// SetStock sets the stock of a product in a warehouse
+	SetStock(stock *WarehouseStock) error
+	// Transfer moves units of a product from a warehouse to another
+	Transfer(productID, fromWarehouseID, toWarehouseID, quantity int) error
+}
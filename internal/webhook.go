@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+// WebhookDeliveryStatus is the state of the delivery of an event to a webhook
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending is a delivery waiting for its next attempt
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliverySucceeded is a delivery accepted by the receiver with a 2xx response
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryFailed is a delivery that exhausted its attempts, it can be replayed
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// Webhook is a struct that contains a subscription of a url to the product change events
+type Webhook struct {
+	// ID is the unique identifier of the webhook
+	ID int
+	// URL is where the events are posted
+	URL string
+	// EventTypes are the kinds of events delivered, all of them when empty
+	EventTypes []EventType
+	// Secret is the key signing the deliveries with HMAC-SHA256
+	Secret string
+	// Active tells whether new events are delivered to the webhook
+	Active bool
+	// CreatedAt is when the webhook was created
+	CreatedAt time.Time
+}
+
+// Subscribed reports whether the webhook receives the kind of event
+func (w Webhook) Subscribed(eventType EventType) bool {
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range w.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is a struct that contains the delivery of an event to a webhook
+type WebhookDelivery struct {
+	// ID is the unique identifier of the delivery
+	ID int
+	// WebhookID is the webhook receiving the event
+	WebhookID int
+	// EventID is the event of the outbox delivered
+	EventID int
+	// EventType is the kind of event delivered
+	EventType EventType
+	// Body is the JSON posted, the same on every attempt
+	Body []byte
+	// Status is the state of the delivery
+	Status WebhookDeliveryStatus
+	// Attempts is the amount of attempts since it was created or replayed
+	Attempts int
+	// NextAttemptAt is when the delivery is attempted while pending
+	NextAttemptAt time.Time
+	// LastStatusCode is the http status of the last attempt, 0 when there was no response
+	LastStatusCode int
+	// LastError is the error of the last attempt, empty if it succeeded
+	LastError string
+	// CreatedAt is when the delivery was created
+	CreatedAt time.Time
+	// DeliveredAt is when the receiver accepted the delivery, nil until then
+	DeliveredAt *time.Time
+	// History are the attempts of the delivery, only loaded for a single delivery
+	History []WebhookAttempt
+}
+
+// WebhookAttempt is a struct that contains an attempt of a webhook delivery
+type WebhookAttempt struct {
+	// ID is the unique identifier of the attempt
+	ID int
+	// DeliveryID is the delivery attempted
+	DeliveryID int
+	// StatusCode is the http status of the response, 0 when there was no response
+	StatusCode int
+	// Error is why the attempt failed, empty if it succeeded
+	Error string
+	// Duration is how long the attempt took
+	Duration time.Duration
+	// AttemptedAt is when the attempt started
+	AttemptedAt time.Time
+}
+
+var (
+	// ErrWebhookRepositoryNotFound is the error returned when the webhook or delivery is not found
+	ErrWebhookRepositoryNotFound = errors.New("repository: webhook not found")
+	// ErrWebhookServiceInvalidField is the error returned when the webhook has an invalid field
+	ErrWebhookServiceInvalidField = errors.New("service: invalid field")
+	// ErrWebhookServiceNotReplayable is the error returned when replaying a delivery that did not fail
+	ErrWebhookServiceNotReplayable = errors.New("service: only failed deliveries can be replayed")
+)
+
+// WebhookRepository is an interface that contains the methods that the webhook repository should support
+type WebhookRepository interface {
+	// FindAll returns all the webhooks
+	FindAll() ([]Webhook, error)
+	// FindByID returns the webhook with the given ID
+	FindByID(id int) (Webhook, error)
+	// FindActive returns the active webhooks
+	FindActive() ([]Webhook, error)
+	// Create creates a new webhook
+	Create(webhook *Webhook) error
+	// Update updates the url, event types and active flag of the webhook
+	Update(webhook *Webhook) error
+	// Delete deletes the webhook and its deliveries
+	Delete(id int) error
+	// CreateDeliveries creates the deliveries, ignoring the ones of an event already delivered to the webhook
+	CreateDeliveries(deliveries []WebhookDelivery) error
+	// ClaimDueDeliveries returns up to limit pending deliveries of active webhooks whose next attempt is due, the
+	// oldest first, and postpones their next attempt until the given time so concurrent runs skip them
+	// - RecordAttempt sets their next attempt, a delivery not attempted is due again once the claim expires
+	ClaimDueDeliveries(now, until time.Time, limit int) ([]WebhookDelivery, error)
+	// RecordAttempt stores the attempt and the resulting state of the delivery
+	RecordAttempt(delivery *WebhookDelivery, attempt *WebhookAttempt) error
+	// FindDeliveries returns the deliveries of the webhook, of the status when given, the newest first
+	FindDeliveries(webhookID int, status WebhookDeliveryStatus, limit int) ([]WebhookDelivery, error)
+	// FindDelivery returns the delivery of the webhook with its attempts
+	FindDelivery(webhookID, id int) (WebhookDelivery, error)
+	// Reschedule sets the delivery pending again from the given time, resetting its attempts
+	Reschedule(webhookID, id int, at time.Time) error
+}
+
+// WebhookService is an interface that contains the methods that the webhook service should support
+type WebhookService interface {
+	// FindAll returns all the webhooks
+	FindAll() ([]Webhook, error)
+	// FindByID returns the webhook with the given ID
+	FindByID(id int) (Webhook, error)
+	// Create creates a new webhook, generating its secret when not given
+	Create(webhook *Webhook) error
+	// Update updates the url, event types and active flag of the webhook
+	Update(webhook *Webhook) error
+	// Delete deletes the webhook and its deliveries
+	Delete(id int) error
+	// FindDeliveries returns the deliveries of the webhook, of the status when given, the newest first
+	FindDeliveries(webhookID int, status WebhookDeliveryStatus) ([]WebhookDelivery, error)
+	// FindDelivery returns the delivery of the webhook with its attempts
+	FindDelivery(webhookID, id int) (WebhookDelivery, error)
+	// Replay attempts a failed delivery again
+	Replay(webhookID, id int) error
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewSender creates a sender posting with the client, a client with a 10 seconds timeout when nil
+func NewSender(client *http.Client) *Sender {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Sender{
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// Sender posts the signed deliveries
+type Sender struct {
+	// client posts the requests
+	client *http.Client
+	// now returns the time of the signatures
+	now func() time.Time
+}
+
+// Send posts the body signed with the secret to the url
+// - err is not nil when there is no response or the response is not 2xx, statusCode is 0 when there is no response
+func (s *Sender) Send(ctx context.Context, url, secret string, deliveryID int, eventType string, body []byte) (statusCode int, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "storage-webhooks/1")
+	req.Header.Set(DeliveryHeader, strconv.Itoa(deliveryID))
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(SignatureHeader, Sign(secret, body, s.now()))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	// drain a bit of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	statusCode = res.StatusCode
+	if statusCode < 200 || statusCode > 299 {
+		err = fmt.Errorf("webhook: unexpected status %d", statusCode)
+	}
+	return
+}
@@ -0,0 +1,76 @@
+// Package webhook signs and posts the webhook deliveries, and verifies them on the receiver side
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header carrying the signature, t=<unix timestamp>,v1=<hex hmac>
+	SignatureHeader = "X-Webhook-Signature"
+	// DeliveryHeader is the header carrying the id of the delivery, the same on every attempt
+	DeliveryHeader = "X-Webhook-Delivery"
+	// EventHeader is the header carrying the type of the event
+	EventHeader = "X-Webhook-Event"
+)
+
+var (
+	// ErrInvalidSignature is the error returned when the signature is malformed or does not match
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrExpiredSignature is the error returned when the timestamp of the signature is out of the tolerance
+	ErrExpiredSignature = errors.New("webhook: expired signature")
+)
+
+// Sign returns the signature header value of the body at the time
+// - the HMAC-SHA256 with the secret is computed over "<unix timestamp>.<body>", so a captured request cannot be replayed later
+func Sign(secret string, body []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, mac(secret, timestamp, body))
+}
+
+// Verify checks the signature header value of the body, rejecting timestamps further than tolerance from now
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) (err error) {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrExpiredSignature
+	}
+
+	// any of the signatures may match, to rotate secrets
+	expected := []byte(mac(secret, timestamp, body))
+	for _, signature := range signatures {
+		if hmac.Equal(expected, []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// mac returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+func mac(secret, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
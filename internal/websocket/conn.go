@@ -0,0 +1,357 @@
+// Package websocket is a minimal server side implementation of the WebSocket protocol (RFC 6455)
+// - it supports text and binary messages, fragmentation, ping/pong and the closing handshake, without extensions
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opcodes of the frames
+const (
+	opContinuation = 0x0
+	// OpText is the opcode of the text messages
+	OpText = 0x1
+	// OpBinary is the opcode of the binary messages
+	OpBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xA
+)
+
+// Status codes of the close frames
+const (
+	// CloseNormal is the normal closure
+	CloseNormal = 1000
+	// CloseGoingAway is the closure of a server shutting down or a client leaving
+	CloseGoingAway = 1001
+	// CloseProtocolError is the closure after a protocol violation
+	CloseProtocolError = 1002
+	// ClosePolicyViolation is the closure after a message against the rules of the application
+	ClosePolicyViolation = 1008
+	// CloseMessageTooBig is the closure after a message bigger than the limit
+	CloseMessageTooBig = 1009
+	// CloseTryAgainLater is the closure of an overloaded server
+	CloseTryAgainLater = 1013
+)
+
+// acceptGUID is appended to the key of the client to compute the accept header
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// AuthorizationProtocol prefixes the subprotocol carrying the Authorization header of a browser, which can not set
+// headers on the handshake, e.g. authorization.<base64url of "Bearer eyJ...">; it is never selected
+const AuthorizationProtocol = "authorization."
+
+var (
+	// ErrBadHandshake is the error returned when the request is not a valid WebSocket handshake
+	ErrBadHandshake = errors.New("websocket: bad handshake")
+	// ErrClosed is the error returned when the connection was closed by the peer
+	ErrClosed = errors.New("websocket: connection closed")
+	// ErrMessageTooBig is the error returned when a message exceeds the read limit
+	ErrMessageTooBig = errors.New("websocket: message too big")
+	// ErrProtocol is the error returned when the peer violated the protocol
+	ErrProtocol = errors.New("websocket: protocol error")
+)
+
+// IsUpgrade reports whether the request asks to upgrade the connection to WebSocket
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// Authorization returns the Authorization header carried by the subprotocols of the handshake, if any
+// - the subprotocols are headers, so unlike the url they are not recorded by the access logs and proxies
+func Authorization(r *http.Request) (authorization string, ok bool) {
+	for _, protocol := range protocols(r) {
+		encoded, found := strings.CutPrefix(protocol, AuthorizationProtocol)
+		if !found {
+			continue
+		}
+		decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+		if err != nil {
+			return
+		}
+		return string(decoded), true
+	}
+	return
+}
+
+// protocols returns the subprotocols requested by the client, in its order of preference
+func protocols(r *http.Request) (list []string) {
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return
+}
+
+// Upgrade completes the handshake of the request and takes over its connection
+// - on a bad handshake it responds 400 and returns ErrBadHandshake
+// - the first subprotocol requested by the client among the supported ones is selected, browsers require one when
+// they request any, so the clients authenticating by subprotocol must request a supported one too
+func Upgrade(w http.ResponseWriter, r *http.Request, readLimit int64, supported ...string) (c *Conn, err error) {
+	// validate the handshake
+	key := r.Header.Get("Sec-WebSocket-Key")
+	decoded, keyErr := base64.StdEncoding.DecodeString(key)
+	if r.Method != http.MethodGet || !IsUpgrade(r) || r.Header.Get("Sec-WebSocket-Version") != "13" || keyErr != nil || len(decoded) != 16 {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "bad websocket handshake", http.StatusBadRequest)
+		return nil, ErrBadHandshake
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, ErrBadHandshake
+	}
+
+	// take over the connection
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	protocol := ""
+selectProtocol:
+	for _, requested := range protocols(r) {
+		for _, s := range supported {
+			if requested == s && !strings.HasPrefix(s, AuthorizationProtocol) {
+				protocol = "Sec-WebSocket-Protocol: " + s + "\r\n"
+				break selectProtocol
+			}
+		}
+	}
+	_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n%s\r\n", base64.StdEncoding.EncodeToString(sum[:]), protocol)
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		netConn.Close()
+		return
+	}
+
+	c = &Conn{
+		conn:      netConn,
+		r:         rw.Reader,
+		readLimit: readLimit,
+	}
+	return
+}
+
+// Conn is a server side WebSocket connection
+// - a goroutine may read while another writes, the writes are serialized
+type Conn struct {
+	// conn is the underlying connection
+	conn net.Conn
+	// r buffers the reads, it may hold data read along the handshake
+	r *bufio.Reader
+	// readLimit is the maximum size of a message
+	readLimit int64
+
+	// wmu serializes the writes
+	wmu sync.Mutex
+	// closeSent tells a close frame was written
+	closeSent bool
+}
+
+// ReadMessage returns the next data message, answering the pings and the close handshake on the way
+// - it returns ErrClosed once the peer closed the connection
+func (c *Conn) ReadMessage() (opcode int, data []byte, err error) {
+	opcode = -1
+	for {
+		var fin bool
+		var op int
+		var payload []byte
+		fin, op, payload, err = c.readFrame()
+		if err != nil {
+			return
+		}
+
+		switch op {
+		case opPing:
+			err = c.writeFrame(opPong, payload, time.Now().Add(5*time.Second))
+			if err != nil {
+				return
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.WriteClose(code, "")
+			err = ErrClosed
+			return
+		case OpText, OpBinary:
+			if opcode != -1 {
+				c.WriteClose(CloseProtocolError, "expected continuation")
+				err = ErrProtocol
+				return
+			}
+			opcode = op
+		case opContinuation:
+			if opcode == -1 {
+				c.WriteClose(CloseProtocolError, "unexpected continuation")
+				err = ErrProtocol
+				return
+			}
+		default:
+			c.WriteClose(CloseProtocolError, "unknown opcode")
+			err = ErrProtocol
+			return
+		}
+
+		if int64(len(data)+len(payload)) > c.readLimit {
+			c.WriteClose(CloseMessageTooBig, "")
+			err = ErrMessageTooBig
+			return
+		}
+		data = append(data, payload...)
+		if fin {
+			return
+		}
+	}
+}
+
+// readFrame reads a frame of the client, which must be masked
+func (c *Conn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var header [2]byte
+	_, err = io.ReadFull(c.r, header[:])
+	if err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = int(header[0] & 0x0F)
+	if header[0]&0x70 != 0 || header[1]&0x80 == 0 {
+		// no extension was negotiated and the client frames must be masked
+		c.WriteClose(CloseProtocolError, "")
+		err = ErrProtocol
+		return
+	}
+
+	// get the length of the payload
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(c.r, ext[:])
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(c.r, ext[:])
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if err != nil {
+		return
+	}
+	if opcode >= opClose && (length > 125 || !fin) {
+		c.WriteClose(CloseProtocolError, "")
+		err = ErrProtocol
+		return
+	}
+	if length < 0 || length > c.readLimit {
+		c.WriteClose(CloseMessageTooBig, "")
+		err = ErrMessageTooBig
+		return
+	}
+
+	// read and unmask the payload
+	var mask [4]byte
+	_, err = io.ReadFull(c.r, mask[:])
+	if err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(c.r, payload)
+	if err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// WriteText writes a text message, failing when it is not written before the deadline
+func (c *Conn) WriteText(data []byte, deadline time.Time) error {
+	return c.writeFrame(OpText, data, deadline)
+}
+
+// WritePing writes a ping, the client answers with a pong
+func (c *Conn) WritePing(deadline time.Time) error {
+	return c.writeFrame(opPing, nil, deadline)
+}
+
+// WriteClose starts or answers the closing handshake, it writes a single close frame
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > 125 {
+		payload = payload[:125]
+	}
+	return c.writeFrame(opClose, payload, time.Now().Add(5*time.Second))
+}
+
+// writeFrame writes an unmasked final frame
+func (c *Conn) writeFrame(opcode int, payload []byte, deadline time.Time) (err error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|byte(opcode))
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+
+	err = c.conn.SetWriteDeadline(deadline)
+	if err != nil {
+		return
+	}
+	_, err = c.conn.Write(frame)
+	return
+}
+
+// Close closes the underlying connection
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// headerContains reports whether the comma separated values of the header contain the token, case insensitive
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}